@@ -0,0 +1,143 @@
+package nfs4go
+
+import (
+	"os"
+
+	"github.com/kuleuven/nfs4go/acl"
+	"github.com/kuleuven/nfs4go/auth"
+	"github.com/kuleuven/nfs4go/msg"
+	"github.com/kuleuven/vfs"
+)
+
+// accessFor evaluates which ACCESS4 bits are granted to the given credentials.
+// It returns the bits the server can evaluate for the file type, and the granted bits.
+func accessFor(fi vfs.FileInfo, creds *auth.Creds) (uint32, uint32) {
+	perm := permissionsFor(fi, creds)
+
+	var supported, granted uint32
+
+	if fi.IsDir() {
+		supported = msg.ACCESS4_READ | msg.ACCESS4_LOOKUP | msg.ACCESS4_MODIFY | msg.ACCESS4_EXTEND | msg.ACCESS4_DELETE
+
+		if perm&acl.Read != 0 {
+			granted |= msg.ACCESS4_READ
+		}
+
+		if perm&acl.Execute != 0 {
+			granted |= msg.ACCESS4_LOOKUP
+		}
+
+		// Adding or removing entries requires search permission as well
+		if perm&(acl.Write|acl.Execute) == acl.Write|acl.Execute {
+			granted |= msg.ACCESS4_MODIFY | msg.ACCESS4_EXTEND | msg.ACCESS4_DELETE
+		}
+	} else {
+		supported = msg.ACCESS4_READ | msg.ACCESS4_MODIFY | msg.ACCESS4_EXTEND | msg.ACCESS4_EXECUTE
+
+		if perm&acl.Read != 0 {
+			granted |= msg.ACCESS4_READ
+		}
+
+		if perm&acl.Write != 0 {
+			granted |= msg.ACCESS4_MODIFY | msg.ACCESS4_EXTEND
+		}
+
+		if perm&acl.Execute != 0 {
+			granted |= msg.ACCESS4_EXECUTE
+		}
+	}
+
+	if xattrSupported(fi) {
+		supported |= msg.ACCESS4_XAREAD | msg.ACCESS4_XALIST | msg.ACCESS4_XAWRITE
+	}
+
+	if perm&acl.Read != 0 {
+		granted |= msg.ACCESS4_XAREAD | msg.ACCESS4_XALIST
+	}
+
+	if perm&acl.Write != 0 && xattrWritable(fi, creds) {
+		granted |= msg.ACCESS4_XAWRITE
+	}
+
+	// The backend might know better, e.g. if it enforces its own policy
+	if p, err := fi.Permissions(); err == nil && p != nil {
+		if !p.Read {
+			granted &^= msg.ACCESS4_READ
+		}
+
+		if !p.Write {
+			granted &^= msg.ACCESS4_MODIFY | msg.ACCESS4_EXTEND | msg.ACCESS4_DELETE
+		}
+
+		if !p.GetExtendedAttrs {
+			granted &^= msg.ACCESS4_XAREAD | msg.ACCESS4_XALIST
+		}
+
+		if !p.SetExtendedAttrs {
+			granted &^= msg.ACCESS4_XAWRITE
+		}
+	}
+
+	return supported, granted & supported
+}
+
+// xattrWritable returns whether linux allows to set extended attributes in the user namespace
+// of the file, besides write permission: only regular files and directories have them, and
+// only the owner can set them on directories with the sticky bit.
+func xattrWritable(fi vfs.FileInfo, creds *auth.Creds) bool {
+	switch {
+	case fi.Mode().IsRegular():
+		return true
+	case fi.IsDir():
+		return fi.Mode()&os.ModeSticky == 0 || creds.UID == 0 || creds.UID == fi.Uid()
+	default:
+		return false
+	}
+}
+
+// permissionsFor returns the read, write and execute permissions of the given credentials,
+// using the POSIX ACL of the file if it has one, and the mode bits otherwise.
+func permissionsFor(fi vfs.FileInfo, creds *auth.Creds) acl.Perm {
+	if creds.UID == 0 {
+		// Root can read and write everything, but only execute files
+		// if they are executable by someone, like CAP_DAC_OVERRIDE.
+		perm := acl.Read | acl.Write
+
+		if fi.IsDir() || fi.Mode()&0o111 != 0 {
+			perm |= acl.Execute
+		}
+
+		return perm
+	}
+
+	access, _ := posixACLs(fi)
+
+	gids := append([]uint32{creds.GID}, creds.AdditionalGroups...)
+
+	return access.Permissions(creds.UID, gids, fi.Uid(), fi.Gid())
+}
+
+// posixACLs returns the access ACL and default ACL of a file. If the file
+// has no access ACL, the ACL equivalent to its mode is returned.
+func posixACLs(fi vfs.FileInfo) (acl.ACL, acl.ACL) {
+	access := acl.FromMode(fi.Mode())
+
+	attrs, err := fi.Extended()
+	if err != nil {
+		return access, nil
+	}
+
+	if value, ok := attrs[acl.XattrAccess]; ok {
+		if parsed, err := acl.Parse(value); err == nil {
+			access = parsed
+		}
+	}
+
+	var def acl.ACL
+
+	if value, ok := attrs[acl.XattrDefault]; ok && fi.IsDir() {
+		def, _ = acl.Parse(value) //nolint:errcheck
+	}
+
+	return access, def
+}
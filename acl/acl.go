@@ -0,0 +1,209 @@
+// Package acl handles POSIX access control lists, as stored by linux
+// in the system.posix_acl_access and system.posix_acl_default extended attributes.
+package acl
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"slices"
+)
+
+const (
+	// XattrAccess is the extended attribute that contains the access ACL.
+	XattrAccess = "system.posix_acl_access"
+
+	// XattrDefault is the extended attribute that contains the default ACL of a directory.
+	XattrDefault = "system.posix_acl_default"
+)
+
+// Tag is the type of an ACL entry.
+type Tag uint16
+
+const (
+	TagUserObj  Tag = 0x01 // Owner of the file
+	TagUser     Tag = 0x02 // User with the given id
+	TagGroupObj Tag = 0x04 // Owning group of the file
+	TagGroup    Tag = 0x08 // Group with the given id
+	TagMask     Tag = 0x10 // Upper bound for the group class
+	TagOther    Tag = 0x20 // Everyone else
+)
+
+// Perm is a combination of read, write and execute permissions.
+type Perm uint16
+
+const (
+	Execute Perm = 0x01
+	Write   Perm = 0x02
+	Read    Perm = 0x04
+)
+
+// UndefinedID is the id of entries that do not refer to a specific user or group.
+const UndefinedID = ^uint32(0)
+
+// Entry is a single entry of an ACL.
+type Entry struct {
+	Tag  Tag
+	Perm Perm
+	ID   uint32 // Only used for TagUser and TagGroup
+}
+
+// ACL is a POSIX access control list.
+type ACL []Entry
+
+const (
+	xattrVersion   = 2
+	xattrEntrySize = 8
+)
+
+// ErrInvalid is returned when an extended attribute cannot be parsed.
+var ErrInvalid = errors.New("invalid posix acl")
+
+// Parse decodes the value of a POSIX ACL extended attribute.
+func Parse(value []byte) (ACL, error) {
+	if len(value) < 4 || (len(value)-4)%xattrEntrySize != 0 {
+		return nil, ErrInvalid
+	}
+
+	if binary.LittleEndian.Uint32(value) != xattrVersion {
+		return nil, ErrInvalid
+	}
+
+	var acl ACL
+
+	for b := value[4:]; len(b) > 0; b = b[xattrEntrySize:] {
+		acl = append(acl, Entry{
+			Tag:  Tag(binary.LittleEndian.Uint16(b[0:2])),
+			Perm: Perm(binary.LittleEndian.Uint16(b[2:4])),
+			ID:   binary.LittleEndian.Uint32(b[4:8]),
+		})
+	}
+
+	return acl, nil
+}
+
+// FromMode returns the minimal ACL that is equivalent to the given file mode.
+func FromMode(mode os.FileMode) ACL {
+	return ACL{
+		{Tag: TagUserObj, Perm: Perm(mode>>6) & 7, ID: UndefinedID},
+		{Tag: TagGroupObj, Perm: Perm(mode>>3) & 7, ID: UndefinedID},
+		{Tag: TagOther, Perm: Perm(mode) & 7, ID: UndefinedID},
+	}
+}
+
+// Permissions returns the permissions that the ACL grants to the given user,
+// for a file owned by owner and group, following the POSIX.1e access check algorithm.
+func (acl ACL) Permissions(uid uint32, gids []uint32, owner, group uint32) Perm {
+	mask := Read | Write | Execute
+
+	for _, e := range acl {
+		if e.Tag == TagMask {
+			mask = e.Perm
+		}
+	}
+
+	// The owner and named users are matched first
+	for _, e := range acl {
+		switch {
+		case e.Tag == TagUserObj && uid == owner:
+			return e.Perm
+		case e.Tag == TagUser && uid == e.ID && uid != owner:
+			return e.Perm & mask
+		}
+	}
+
+	// Then all matching group entries are combined
+	var (
+		perm    Perm
+		matched bool
+	)
+
+	for _, e := range acl {
+		switch {
+		case e.Tag == TagGroupObj && slices.Contains(gids, group):
+			perm |= e.Perm
+			matched = true
+		case e.Tag == TagGroup && slices.Contains(gids, e.ID):
+			perm |= e.Perm
+			matched = true
+		}
+	}
+
+	if matched {
+		return perm & mask
+	}
+
+	for _, e := range acl {
+		if e.Tag == TagOther {
+			return e.Perm
+		}
+	}
+
+	return 0
+}
+
+// Bytes encodes the ACL as the value of a POSIX ACL extended attribute.
+func (acl ACL) Bytes() []byte {
+	buf := make([]byte, 4, 4+xattrEntrySize*len(acl))
+
+	binary.LittleEndian.PutUint32(buf, xattrVersion)
+
+	for _, e := range acl {
+		buf = binary.LittleEndian.AppendUint16(buf, uint16(e.Tag))
+		buf = binary.LittleEndian.AppendUint16(buf, uint16(e.Perm))
+		buf = binary.LittleEndian.AppendUint32(buf, e.ID)
+	}
+
+	return buf
+}
+
+// Mode returns the permission bits that correspond to the ACL, and whether
+// the ACL is fully represented by them, i.e. whether it has no named entries.
+func (acl ACL) Mode() (os.FileMode, bool) {
+	var (
+		mode       os.FileMode
+		group      Perm
+		mask       *Perm
+		equivalent = true
+	)
+
+	for _, e := range acl {
+		switch e.Tag {
+		case TagUserObj:
+			mode |= os.FileMode(e.Perm) << 6
+		case TagGroupObj:
+			group = e.Perm
+		case TagMask:
+			mask = &e.Perm
+		case TagOther:
+			mode |= os.FileMode(e.Perm)
+		case TagUser, TagGroup:
+			equivalent = false
+		}
+	}
+
+	// With a mask entry, the group bits of the mode reflect the mask
+	if mask != nil {
+		group = *mask
+	}
+
+	return mode | os.FileMode(group)<<3, equivalent
+}
+
+// Sort sorts the entries in the canonical order that is expected by the kernel.
+func (acl ACL) Sort() {
+	slices.SortStableFunc(acl, func(a, b Entry) int {
+		if a.Tag != b.Tag {
+			return int(a.Tag) - int(b.Tag)
+		}
+
+		switch {
+		case a.ID < b.ID:
+			return -1
+		case a.ID > b.ID:
+			return 1
+		default:
+			return 0
+		}
+	})
+}
@@ -0,0 +1,432 @@
+package acl
+
+import (
+	"github.com/kuleuven/nfs4go/idmap"
+	"github.com/kuleuven/nfs4go/msg"
+)
+
+// The mapping between POSIX ACLs and NFSv4 ACLs follows the approach of the linux
+// kernel server (fs/nfsd/nfs4acl.c), so that clients see the same ACLs for the same files.
+
+const (
+	WhoOwner    = "OWNER@"
+	WhoGroup    = "GROUP@"
+	WhoEveryone = "EVERYONE@"
+)
+
+const (
+	anyoneMode  = msg.ACE4_READ_ATTRIBUTES | msg.ACE4_READ_ACL | msg.ACE4_SYNCHRONIZE
+	ownerMode   = msg.ACE4_WRITE_ATTRIBUTES | msg.ACE4_WRITE_ACL
+	readMode    = msg.ACE4_READ_DATA
+	writeMode   = msg.ACE4_WRITE_DATA | msg.ACE4_APPEND_DATA
+	executeMode = msg.ACE4_EXECUTE
+
+	inheritFlags   = msg.ACE4_FILE_INHERIT_ACE | msg.ACE4_DIRECTORY_INHERIT_ACE | msg.ACE4_INHERIT_ONLY_ACE
+	supportedFlags = inheritFlags | msg.ACE4_IDENTIFIER_GROUP
+)
+
+// Support is the value of the aclsupport attribute: only ALLOW and DENY ACEs can be stored.
+const Support = uint32(0x00000001 | 0x00000002) // ACL4_SUPPORT_ALLOW_ACL | ACL4_SUPPORT_DENY_ACL
+
+var (
+	// ErrNotSupported is returned for ACLs that cannot be stored as POSIX ACL.
+	ErrNotSupported = msg.Error(msg.NFS4ERR_ATTRNOTSUPP)
+
+	// ErrBadWho is returned for ACEs with an unknown special who.
+	ErrBadWho = msg.Error(msg.NFS4ERR_BADOWNER)
+)
+
+// ToNFS4 converts an access ACL and an optional default ACL to NFSv4 ACEs.
+func ToNFS4(access, def ACL, dir bool, ids *idmap.Mapper) []msg.NfsAce4 {
+	var aces []msg.NfsAce4
+
+	aces = appendNFS4(aces, access, 0, dir, ids)
+
+	if dir && len(def) > 0 {
+		aces = appendNFS4(aces, def, inheritFlags, dir, ids)
+	}
+
+	return aces
+}
+
+type summary struct {
+	owner, users, group, groups, other, mask Perm
+}
+
+func summarize(acl ACL) summary {
+	s := summary{mask: Read | Write | Execute}
+
+	for _, e := range acl {
+		switch e.Tag {
+		case TagUserObj:
+			s.owner = e.Perm
+		case TagUser:
+			s.users |= e.Perm
+		case TagGroupObj:
+			s.group = e.Perm
+		case TagGroup:
+			s.groups |= e.Perm
+		case TagMask:
+			s.mask = e.Perm
+		case TagOther:
+			s.other = e.Perm
+		}
+	}
+
+	// Only the effective permissions matter
+	s.users &= s.mask
+	s.group &= s.mask
+	s.groups &= s.mask
+
+	return s
+}
+
+func appendNFS4(aces []msg.NfsAce4, acl ACL, flags uint32, dir bool, ids *idmap.Mapper) []msg.NfsAce4 { //nolint:funlen
+	acl = append(ACL{}, acl...)
+	acl.Sort()
+
+	s := summarize(acl)
+
+	add := func(typ, flag, mask uint32, who string) {
+		aces = append(aces, msg.NfsAce4{
+			Type:       typ,
+			Flag:       flags | flag,
+			AccessMask: mask,
+			Who:        who,
+		})
+	}
+
+	for _, e := range acl {
+		switch e.Tag {
+		case TagUserObj:
+			// Deny only what is granted by later entries
+			if deny := ^e.Perm & (s.users | s.group | s.groups | s.other); deny != 0 {
+				add(msg.ACE4_ACCESS_DENIED_ACE_TYPE, 0, denyMask(deny, dir), WhoOwner)
+			}
+
+			add(msg.ACE4_ACCESS_ALLOWED_ACE_TYPE, 0, allowMask(e.Perm, dir)|ownerMode, WhoOwner)
+
+		case TagUser:
+			who := ids.Owner(e.ID)
+
+			if deny := ^(e.Perm & s.mask) & (s.groups | s.group | s.other); deny != 0 {
+				add(msg.ACE4_ACCESS_DENIED_ACE_TYPE, 0, denyMask(deny, dir), who)
+			}
+
+			add(msg.ACE4_ACCESS_ALLOWED_ACE_TYPE, 0, allowMask(e.Perm&s.mask, dir), who)
+		}
+	}
+
+	// A user can be member of multiple groups, so all allow ACEs come before the deny ACEs
+	for _, e := range acl {
+		switch e.Tag {
+		case TagGroupObj:
+			add(msg.ACE4_ACCESS_ALLOWED_ACE_TYPE, msg.ACE4_IDENTIFIER_GROUP, allowMask(s.group, dir), WhoGroup)
+		case TagGroup:
+			add(msg.ACE4_ACCESS_ALLOWED_ACE_TYPE, msg.ACE4_IDENTIFIER_GROUP, allowMask(e.Perm&s.mask, dir), ids.Group(e.ID))
+		}
+	}
+
+	for _, e := range acl {
+		switch e.Tag {
+		case TagGroupObj:
+			if deny := ^s.group & s.other; deny != 0 {
+				add(msg.ACE4_ACCESS_DENIED_ACE_TYPE, msg.ACE4_IDENTIFIER_GROUP, denyMask(deny, dir), WhoGroup)
+			}
+		case TagGroup:
+			if deny := ^(e.Perm & s.mask) & s.other; deny != 0 {
+				add(msg.ACE4_ACCESS_DENIED_ACE_TYPE, msg.ACE4_IDENTIFIER_GROUP, denyMask(deny, dir), ids.Group(e.ID))
+			}
+		}
+	}
+
+	add(msg.ACE4_ACCESS_ALLOWED_ACE_TYPE, 0, allowMask(s.other, dir), WhoEveryone)
+
+	return aces
+}
+
+func denyMask(perm Perm, dir bool) uint32 {
+	var mask uint32
+
+	if perm&Read != 0 {
+		mask |= readMode
+	}
+
+	if perm&Write != 0 {
+		mask |= writeMode
+
+		if dir {
+			mask |= msg.ACE4_DELETE_CHILD
+		}
+	}
+
+	if perm&Execute != 0 {
+		mask |= executeMode
+	}
+
+	return mask
+}
+
+func allowMask(perm Perm, dir bool) uint32 {
+	return anyoneMode | denyMask(perm, dir)
+}
+
+// FromNFS4 converts NFSv4 ACEs to an access ACL and a default ACL. The default ACL is
+// nil if there are no inheritable ACEs. It returns ErrNotSupported if the ACEs
+// cannot be represented as POSIX ACL, and ErrBadWho if a principal cannot be translated.
+func FromNFS4(aces []msg.NfsAce4, dir bool, ids *idmap.Mapper) (ACL, ACL, error) {
+	access := newState()
+	def := newState()
+
+	for _, ace := range aces {
+		if ace.Type != msg.ACE4_ACCESS_ALLOWED_ACE_TYPE && ace.Type != msg.ACE4_ACCESS_DENIED_ACE_TYPE {
+			return nil, nil, ErrNotSupported
+		}
+
+		if ace.Flag&^supportedFlags != 0 {
+			return nil, nil, ErrNotSupported
+		}
+
+		tag, id, err := parseWho(ace, ids)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if ace.Flag&inheritFlags == 0 {
+			access.process(ace, tag, id)
+
+			continue
+		}
+
+		if !dir {
+			return nil, nil, ErrNotSupported
+		}
+
+		// Only one of FILE_INHERIT and DIRECTORY_INHERIT effectively turns on both
+		def.process(ace, tag, id)
+
+		if ace.Flag&msg.ACE4_INHERIT_ONLY_ACE == 0 {
+			access.process(ace, tag, id)
+		}
+	}
+
+	var defACL ACL
+
+	if !def.empty {
+		defACL = def.acl(dir)
+	}
+
+	return access.acl(dir), defACL, nil
+}
+
+func parseWho(ace msg.NfsAce4, ids *idmap.Mapper) (Tag, uint32, error) {
+	switch ace.Who {
+	case WhoOwner:
+		return TagUserObj, UndefinedID, nil
+	case WhoGroup:
+		return TagGroupObj, UndefinedID, nil
+	case WhoEveryone:
+		return TagOther, UndefinedID, nil
+	}
+
+	if len(ace.Who) > 0 && ace.Who[len(ace.Who)-1] == '@' {
+		return 0, 0, ErrBadWho
+	}
+
+	if ace.Flag&msg.ACE4_IDENTIFIER_GROUP != 0 {
+		gid, err := ids.ParseGroup(ace.Who)
+
+		return TagGroup, gid, err
+	}
+
+	uid, err := ids.ParseOwner(ace.Who)
+
+	return TagUser, uid, err
+}
+
+// bits tracks the allowed and denied bits for an entry while processing ACEs in order.
+type bits struct {
+	allow, deny uint32
+}
+
+func (b *bits) allowBits(mask uint32) {
+	b.allow |= mask &^ b.deny
+}
+
+func (b *bits) denyBits(mask uint32) {
+	b.deny |= mask &^ b.allow
+}
+
+type named struct {
+	id   uint32
+	bits bits
+}
+
+type state struct {
+	empty                         bool
+	owner, group, other, everyone bits
+	users, groups                 []*named
+}
+
+func newState() *state {
+	return &state{empty: true}
+}
+
+func (s *state) find(list *[]*named, id uint32) *named {
+	for _, n := range *list {
+		if n.id == id {
+			return n
+		}
+	}
+
+	// A new entry gets the bits that were granted to everyone so far
+	n := &named{id: id, bits: s.everyone}
+
+	*list = append(*list, n)
+
+	return n
+}
+
+func (s *state) process(ace msg.NfsAce4, tag Tag, id uint32) { //nolint:funlen
+	allow := ace.Type == msg.ACE4_ACCESS_ALLOWED_ACE_TYPE
+	mask := ace.AccessMask
+
+	s.empty = false
+
+	switch tag {
+	case TagUserObj:
+		if allow {
+			s.owner.allowBits(mask)
+		} else {
+			s.owner.denyBits(mask)
+		}
+
+	case TagUser:
+		n := s.find(&s.users, id)
+
+		if allow {
+			n.bits.allowBits(mask)
+		} else {
+			n.bits.denyBits(mask)
+			s.owner.denyBits(n.bits.deny)
+		}
+
+	case TagGroupObj:
+		if allow {
+			s.group.allowBits(mask)
+		} else {
+			s.group.denyBits(mask)
+			s.denyAll(s.group.deny, false)
+		}
+
+	case TagGroup:
+		n := s.find(&s.groups, id)
+
+		if allow {
+			n.bits.allowBits(mask)
+		} else {
+			n.bits.denyBits(mask)
+			s.denyAll(n.bits.deny, true)
+		}
+
+	case TagOther:
+		if allow {
+			for _, b := range s.all() {
+				b.allowBits(mask)
+			}
+		} else {
+			for _, b := range s.all() {
+				b.denyBits(mask)
+			}
+		}
+	}
+}
+
+// denyAll denies the mask for the owner, everyone and all named entries,
+// and for the owning group if group is set.
+func (s *state) denyAll(mask uint32, group bool) {
+	s.owner.denyBits(mask)
+	s.everyone.denyBits(mask)
+
+	if group {
+		s.group.denyBits(mask)
+	}
+
+	for _, n := range s.users {
+		n.bits.denyBits(mask)
+	}
+
+	for _, n := range s.groups {
+		n.bits.denyBits(mask)
+	}
+}
+
+func (s *state) all() []*bits {
+	list := []*bits{&s.owner, &s.group, &s.other, &s.everyone}
+
+	for _, n := range s.users {
+		list = append(list, &n.bits)
+	}
+
+	for _, n := range s.groups {
+		list = append(list, &n.bits)
+	}
+
+	return list
+}
+
+func (s *state) acl(dir bool) ACL {
+	acl := ACL{
+		{Tag: TagUserObj, Perm: fromNFS4(s.owner.allow, dir), ID: UndefinedID},
+		{Tag: TagGroupObj, Perm: fromNFS4(s.group.allow, dir), ID: UndefinedID},
+		{Tag: TagOther, Perm: fromNFS4(s.other.allow, dir), ID: UndefinedID},
+	}
+
+	if len(s.users) == 0 && len(s.groups) == 0 {
+		acl.Sort()
+
+		return acl
+	}
+
+	mask := s.group.allow
+
+	for _, n := range s.users {
+		acl = append(acl, Entry{Tag: TagUser, Perm: fromNFS4(n.bits.allow, dir), ID: n.id})
+		mask |= n.bits.allow
+	}
+
+	for _, n := range s.groups {
+		acl = append(acl, Entry{Tag: TagGroup, Perm: fromNFS4(n.bits.allow, dir), ID: n.id})
+		mask |= n.bits.allow
+	}
+
+	acl = append(acl, Entry{Tag: TagMask, Perm: fromNFS4(mask, dir), ID: UndefinedID})
+
+	acl.Sort()
+
+	return acl
+}
+
+func fromNFS4(mask uint32, dir bool) Perm {
+	var perm Perm
+
+	write := writeMode
+
+	if dir {
+		write |= msg.ACE4_DELETE_CHILD
+	}
+
+	if mask&readMode == readMode {
+		perm |= Read
+	}
+
+	if mask&write == write {
+		perm |= Write
+	}
+
+	if mask&executeMode == executeMode {
+		perm |= Execute
+	}
+
+	return perm
+}
@@ -0,0 +1,177 @@
+package acl
+
+import (
+	"os"
+
+	"github.com/kuleuven/nfs4go/idmap"
+	"github.com/kuleuven/nfs4go/msg"
+)
+
+// Values of the acl_trueform and acl_trueform_scope attributes,
+// defined by the POSIX ACL extension for NFSv4.2.
+const (
+	ModelNFS4       = uint32(1)
+	ModelPosixDraft = uint32(2)
+	ModelNone       = uint32(3)
+
+	ScopeFileObject = uint32(1)
+	ScopeFileSystem = uint32(2)
+	ScopeServer     = uint32(3)
+)
+
+// Tags used by the posix_access_acl and posix_default_acl attributes.
+const (
+	posixTagUserObj  = uint32(1)
+	posixTagUser     = uint32(2)
+	posixTagGroupObj = uint32(3)
+	posixTagGroup    = uint32(4)
+	posixTagMask     = uint32(5)
+	posixTagOther    = uint32(6)
+)
+
+var posixTags = map[Tag]uint32{
+	TagUserObj:  posixTagUserObj,
+	TagUser:     posixTagUser,
+	TagGroupObj: posixTagGroupObj,
+	TagGroup:    posixTagGroup,
+	TagMask:     posixTagMask,
+	TagOther:    posixTagOther,
+}
+
+// ErrInvalidPosix is returned for POSIX ACEs that do not form a valid ACL.
+var ErrInvalidPosix = msg.Error(msg.NFS4ERR_INVAL)
+
+// ToPosix converts the ACL to POSIX ACEs as used by the posix_access_acl and posix_default_acl attributes.
+func ToPosix(list ACL, ids *idmap.Mapper) []msg.NfsPosixAce4 {
+	list = append(ACL{}, list...)
+	list.Sort()
+
+	aces := []msg.NfsPosixAce4{}
+
+	for _, e := range list {
+		ace := msg.NfsPosixAce4{
+			Tag:  posixTags[e.Tag],
+			Perm: uint32(e.Perm),
+		}
+
+		switch e.Tag {
+		case TagUser:
+			ace.Who = ids.Owner(e.ID)
+		case TagGroup:
+			ace.Who = ids.Group(e.ID)
+		}
+
+		aces = append(aces, ace)
+	}
+
+	return aces
+}
+
+// FromPosix converts POSIX ACEs to an ACL. It returns ErrInvalidPosix if the
+// ACEs do not form a valid ACL, and ErrBadOwner if a principal cannot be translated.
+func FromPosix(aces []msg.NfsPosixAce4, ids *idmap.Mapper) (ACL, error) {
+	var (
+		list ACL
+		seen = map[Entry]bool{}
+	)
+
+	for _, ace := range aces {
+		if ace.Perm&^uint32(Read|Write|Execute) != 0 {
+			return nil, ErrInvalidPosix
+		}
+
+		e := Entry{
+			Perm: Perm(ace.Perm),
+			ID:   UndefinedID,
+		}
+
+		var err error
+
+		switch ace.Tag {
+		case posixTagUserObj:
+			e.Tag = TagUserObj
+		case posixTagUser:
+			e.Tag = TagUser
+			e.ID, err = ids.ParseOwner(ace.Who)
+		case posixTagGroupObj:
+			e.Tag = TagGroupObj
+		case posixTagGroup:
+			e.Tag = TagGroup
+			e.ID, err = ids.ParseGroup(ace.Who)
+		case posixTagMask:
+			e.Tag = TagMask
+		case posixTagOther:
+			e.Tag = TagOther
+		default:
+			return nil, ErrInvalidPosix
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		key := Entry{Tag: e.Tag, ID: e.ID}
+
+		if seen[key] {
+			return nil, ErrInvalidPosix
+		}
+
+		seen[key] = true
+
+		list = append(list, e)
+	}
+
+	if !list.Valid() {
+		return nil, ErrInvalidPosix
+	}
+
+	list.Sort()
+
+	return list, nil
+}
+
+// Valid returns whether the ACL has the required entries: one owner, owning group and
+// other entry, and a mask entry if and only if there are named users or groups.
+func (acl ACL) Valid() bool {
+	count := map[Tag]int{}
+
+	for _, e := range acl {
+		count[e.Tag]++
+	}
+
+	if count[TagUserObj] != 1 || count[TagGroupObj] != 1 || count[TagOther] != 1 || count[TagMask] > 1 {
+		return false
+	}
+
+	named := count[TagUser]+count[TagGroup] > 0
+
+	return named == (count[TagMask] == 1)
+}
+
+// Inherit returns the access ACL of a new file, created with the given mode in a
+// directory with this default ACL. The permissions are limited by the mode, like
+// posix_acl_create does in the linux kernel.
+func (acl ACL) Inherit(mode os.FileMode) ACL {
+	inherited := append(ACL{}, acl...)
+
+	hasMask := false
+
+	for _, e := range inherited {
+		if e.Tag == TagMask {
+			hasMask = true
+		}
+	}
+
+	for i, e := range inherited {
+		switch {
+		case e.Tag == TagUserObj:
+			inherited[i].Perm &= Perm(mode>>6) & 7
+		case e.Tag == TagMask, e.Tag == TagGroupObj && !hasMask:
+			inherited[i].Perm &= Perm(mode>>3) & 7
+		case e.Tag == TagOther:
+			inherited[i].Perm &= Perm(mode) & 7
+		}
+	}
+
+	return inherited
+}
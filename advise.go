@@ -0,0 +1,112 @@
+package nfs4go
+
+import (
+	"errors"
+	"io"
+
+	"github.com/kuleuven/nfs4go/logger"
+	"github.com/kuleuven/nfs4go/msg"
+	"github.com/kuleuven/vfs"
+)
+
+// Advice describes how a range of an open file is going to be accessed.
+type Advice int
+
+const (
+	AdviseNormal     Advice = iota // No particular access pattern
+	AdviseSequential               // Accessed sequentially, from lower to higher offsets
+	AdviseRandom                   // Accessed in random order
+	AdviseWillNeed                 // Accessed in the near future
+	AdviseDontNeed                 // Not accessed in the near future
+	AdviseNoReuse                  // Accessed only once
+)
+
+// AdviseFile can be implemented by open files to receive hints about how a range of the file
+// is going to be accessed, like posix_fadvise(2). A length of zero means up to the end of the file.
+// Hints for which an error is returned are not reported to the client as honoured.
+type AdviseFile interface {
+	Advise(offset, length int64, advice Advice) error
+}
+
+// adviseFile returns the AdviseFile implementation of an open file, if any.
+// Hints for files that opt in as NativeFile are passed to posix_fadvise(2) on linux.
+func adviseFile(f vfs.WriterAtReaderAt) (AdviseFile, bool) {
+	if a, ok := findFile[AdviseFile](f); ok {
+		return a, true
+	}
+
+	return findNative[AdviseFile](f)
+}
+
+// adviceHints maps the hints of IO_ADVISE to the advice passed to AdviseFile.
+var adviceHints = []struct {
+	Hint   int
+	Advice Advice
+}{
+	{msg.IO_ADVISE4_NORMAL, AdviseNormal},
+	{msg.IO_ADVISE4_SEQUENTIAL, AdviseSequential},
+	{msg.IO_ADVISE4_RANDOM, AdviseRandom},
+	{msg.IO_ADVISE4_WILLNEED, AdviseWillNeed},
+	{msg.IO_ADVISE4_DONTNEED, AdviseDontNeed},
+	{msg.IO_ADVISE4_NOREUSE, AdviseNoReuse},
+}
+
+// accessPatternHints are the hints of IO_ADVISE that exclude each other.
+const accessPatternHints = 1<<msg.IO_ADVISE4_NORMAL | 1<<msg.IO_ADVISE4_SEQUENTIAL | 1<<msg.IO_ADVISE4_SEQUENTIAL_BACKWARDS | 1<<msg.IO_ADVISE4_RANDOM
+
+// adviseHints passes the hints of IO_ADVISE, as a bitmap, to the open file.
+// It returns the hints that were accepted.
+func adviseHints(f vfs.WriterAtReaderAt, offset, length int64, hints uint32) uint32 {
+	a, ok := adviseFile(f)
+	if !ok {
+		// Without hints, the file is accessed as usual
+		return hints & (1 << msg.IO_ADVISE4_NORMAL)
+	}
+
+	var honoured uint32
+
+	for _, h := range adviceHints {
+		if hints&(1<<h.Hint) == 0 {
+			continue
+		}
+
+		if err := a.Advise(offset, length, h.Advice); err != nil {
+			logger.Logger.Debugf("advice %d not accepted: %v", h.Advice, err)
+
+			continue
+		}
+
+		honoured |= 1 << h.Hint
+	}
+
+	return honoured
+}
+
+// ReadAheadLimit is the maximum amount of data that is read in the background for
+// IO_ADVISE4_WILLNEED, if the open file does not accept the hint itself. This warms
+// the caches of the underlying file system. Set to zero to disable.
+var ReadAheadLimit = int64(16 * 1024 * 1024)
+
+// readAheadChunk is the amount of data read at once in the background.
+const readAheadChunk = 1024 * 1024
+
+// readAhead reads length bytes of an open file, or up to the end of the file, and discards them.
+func readAhead(f vfs.WriterAtReaderAt, offset, length int64) {
+	buf := make([]byte, min(readAheadChunk, length))
+
+	for read := int64(0); read < length; {
+		n, err := f.ReadAt(buf[:min(int64(len(buf)), length-read)], offset+read)
+
+		read += int64(n)
+
+		if errors.Is(err, io.EOF) || n == 0 {
+			return
+		}
+
+		if err != nil {
+			logger.Logger.Debugf("read ahead stopped: %v", err)
+
+			return
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package nfs4go
+
+import (
+	"errors"
+	"syscall"
+
+	"github.com/kuleuven/vfs"
+)
+
+// AllocatorFile can be implemented by open files to manage the space of a file, like
+// fallocate(2). Allocate reserves space for the range, extending the file if needed.
+// Deallocate releases the space of the range, which reads as zeros afterwards, without
+// changing the file size. Both return syscall.EOPNOTSUPP if the file does not support it,
+// in which case the operation is emulated.
+type AllocatorFile interface {
+	Allocate(offset, length int64) error
+	Deallocate(offset, length int64) error
+}
+
+// allocatorFile returns the AllocatorFile implementation of an open file, if any.
+// Files that opt in as NativeFile use fallocate(2) on linux.
+func allocatorFile(f vfs.WriterAtReaderAt) (AllocatorFile, bool) {
+	if a, ok := findFile[AllocatorFile](f); ok {
+		return a, true
+	}
+
+	return findNative[AllocatorFile](f)
+}
+
+// zeroChunk is the size of the writes used to emulate Deallocate.
+const zeroChunk = 1024 * 1024
+
+// allocateFile reserves space for the given range of an open file of the given size.
+// If the file cannot reserve space, only its size is extended.
+func allocateFile(f vfs.WriterAtReaderAt, offset, length, size int64) error {
+	if a, ok := allocatorFile(f); ok {
+		if err := a.Allocate(offset, length); !errors.Is(err, syscall.EOPNOTSUPP) {
+			return err
+		}
+	}
+
+	if offset+length <= size {
+		return nil
+	}
+
+	// Writing the last byte extends the file without touching existing data
+	_, err := f.WriteAt([]byte{0}, offset+length-1)
+
+	return err
+}
+
+// deallocateFile releases the space of the given range of an open file of the given size.
+// If the file cannot punch holes, the range is overwritten with zeros.
+func deallocateFile(f vfs.WriterAtReaderAt, offset, length, size int64) error {
+	if a, ok := allocatorFile(f); ok {
+		if err := a.Deallocate(offset, length); !errors.Is(err, syscall.EOPNOTSUPP) {
+			return err
+		}
+	}
+
+	end := min(offset+length, size)
+	zeros := make([]byte, min(zeroChunk, max(end-offset, 0)))
+
+	for pos := offset; pos < end; {
+		n, err := f.WriteAt(zeros[:min(int64(len(zeros)), end-pos)], pos)
+		if err != nil {
+			return err
+		}
+
+		pos += int64(n)
+	}
+
+	return nil
+}
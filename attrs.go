@@ -8,9 +8,12 @@ import (
 	"math"
 	"os"
 
+	"github.com/kuleuven/nfs4go/acl"
 	"github.com/kuleuven/nfs4go/auth"
 	"github.com/kuleuven/nfs4go/clients"
 	"github.com/kuleuven/nfs4go/clock"
+	"github.com/kuleuven/nfs4go/handle"
+	"github.com/kuleuven/nfs4go/idmap"
 	"github.com/kuleuven/nfs4go/logger"
 	"github.com/kuleuven/nfs4go/msg"
 	"github.com/kuleuven/nfs4go/xdr"
@@ -52,7 +55,10 @@ const (
 	A_time_modify        = 53 // nfstime4, struct{uint64, uint32}
 	A_time_modify_set    = 54
 	A_mounted_on_fileid  = 55 // uint64
+	A_fs_layout_types    = 62 // (v4.1) layouttype4<>
+	A_layout_blksize     = 65 // (v4.1) uint32
 	A_suppattr_exclcreat = 75 // (v4.1) bitmap4
+	A_clone_blksize      = 77 // (v4.2) uint32
 	A_xattr_support      = 82
 	A_acl_trueform       = 88
 	A_acl_trueform_scope = 89
@@ -60,6 +66,10 @@ const (
 	A_posix_access_acl   = 91
 )
 
+// maxIO4 is the maximum size of READ and READ_PLUS, advertised in the maxread
+// and maxwrite attributes. Larger requests are shortened.
+const maxIO4 = 32 * 1024
+
 var AttrsDefaultSet = []int{
 	// A_supported_attrs,
 	A_type,
@@ -121,14 +131,16 @@ var AttrsSupported = []int{
 	A_time_metadata,
 	A_time_modify,
 	A_mounted_on_fileid,
+	A_fs_layout_types,
+	A_layout_blksize,
 	A_suppattr_exclcreat,
+	A_clone_blksize,
 	A_xattr_support,
 	A_acl,
-	A_aclsupport,
-	/*A_acl_trueform,
+	A_acl_trueform,
 	A_acl_trueform_scope,
 	A_posix_default_acl,
-	A_posix_access_acl,*/
+	A_posix_access_acl,
 }
 
 func GetAttrNameByID(id int) (string, bool) { //nolint:funlen,gocyclo
@@ -193,8 +205,14 @@ func GetAttrNameByID(id int) (string, bool) { //nolint:funlen,gocyclo
 		return "time_modify", true
 	case A_mounted_on_fileid:
 		return "mounted_on_fileid", true
+	case A_fs_layout_types:
+		return "fs_layout_types", true
+	case A_layout_blksize:
+		return "layout_blksize", true
 	case A_suppattr_exclcreat:
 		return "suppattr_exclcreat", true
+	case A_clone_blksize:
+		return "clone_blksize", true
 	case A_xattr_support:
 		return "xattr_support", true
 	case A_acl:
@@ -214,7 +232,73 @@ func GetAttrNameByID(id int) (string, bool) { //nolint:funlen,gocyclo
 	return fmt.Sprintf("%d", id), false
 }
 
-func fileInfoToAttrs(fh []byte, fi vfs.FileInfo, err error, attrsRequest map[int]bool, creds *auth.Creds, sessionID uint64) msg.FAttr4 { //nolint:funlen,gocognit,gocyclo
+// attrContext contains the request context needed to encode attributes.
+type attrContext struct {
+	Creds     *auth.Creds
+	SessionID uint64
+	IDMapper  *idmap.Mapper  // Translates owner and owner_group
+	Signer    *handle.Signer // Signs the filehandle attribute
+	Layout    *FlexLayout    // Layout reported in fs_layout_types, if set
+	Xattrs    *XattrMapping  // Extended attributes exposed as named attributes
+}
+
+// changeID returns the change attribute of a file.
+// This indicates the client whether the file handle has been modified (e.g. files in the folder have been added/removed)
+// However, the client assumes a global view across all uids, and we serve different views for different uids. So we must
+// enforce a value per uid. We also include the sessionID to force clients to revalidate their file handles.
+// The nanoseconds make sure that changes within the same second, e.g. to extended attributes, are visible.
+func changeID(fi vfs.FileInfo, ctx attrContext) uint64 {
+	mtime := fi.ModTime()
+
+	return uint64(mtime.Unix())*uint64(math.MaxUint32) + uint64(mtime.Nanosecond()) + uint64(ctx.Creds.UID) + ctx.SessionID
+}
+
+// nfsType returns the type attribute of a file. The values of NFSv3 and NFSv4 are the same.
+func nfsType(fi vfs.FileInfo) uint32 {
+	if t, ok := fi.(interface{ nfsType() uint32 }); ok {
+		return t.nfsType()
+	}
+
+	if fi.IsDir() {
+		return msg.NF4DIR
+	}
+
+	switch fi.Mode().Type() { //nolint:exhaustive
+	case os.ModeDir:
+		return msg.NF4DIR
+	case os.ModeSymlink:
+		return msg.NF4LNK
+	case os.ModeSocket:
+		return msg.NF4SOCK
+	default:
+		return msg.NF4REG
+	}
+}
+
+// fileIDOf derives the fileid attribute from the last non-zero bytes of a file handle.
+func fileIDOf(fh []byte) uint64 {
+	window := fh
+
+	if len(window) < 8 {
+		window = append(window, make([]byte, 8-len(window))...)
+	}
+
+	var fileid uint64
+
+	for fileid == 0 && len(window) >= 8 {
+		fileid = binary.LittleEndian.Uint64(window[len(window)-8:])
+
+		window = window[:len(window)-1]
+	}
+
+	if fileid == 0 {
+		fileid--
+	}
+
+	return fileid
+}
+
+func fileInfoToAttrs(fh []byte, fi vfs.FileInfo, err error, attrsRequest map[int]bool, ctx attrContext) msg.FAttr4 { //nolint:funlen,gocognit,gocyclo
 	idxSupport := map[int]bool{}
 
 	for _, a := range AttrsSupported {
@@ -292,34 +376,14 @@ func fileInfoToAttrs(fh []byte, fi vfs.FileInfo, err error, attrsRequest map[int
 			writeAny(a, v, 4+4*len(v))
 
 		case A_type:
-			v := msg.NF4REG
-
-			if fi.IsDir() {
-				v = msg.NF4DIR
-			} else {
-				switch fi.Mode().Type() { //nolint:exhaustive
-				case os.ModeDir:
-					v = msg.NF4DIR
-				case os.ModeSymlink:
-					v = msg.NF4LNK
-				case os.ModeSocket:
-					v = msg.NF4SOCK
-				default:
-				}
-			}
-
-			writeAny(a, v, 4)
+			writeAny(a, nfsType(fi), 4)
 
 		case A_fh_expire_type:
 			v := msg.FH4_VOLATILE_ANY | msg.FH4_NOEXPIRE_WITH_OPEN
 			writeAny(a, v, 4)
 
 		case A_change:
-			// This indicates the client whether the file handle has been modified (e.g. files in the folder have been added/removed)
-			// However, the client assumes a global view across all uids, and we serve different views for different uids. So we must
-			// enforce a value per uid. We also include the sessionID to force clients to revalidate their file handles.
-			changeid := uint64(fi.ModTime().Unix())*uint64(math.MaxUint32) + uint64(creds.UID) + sessionID
-			writeAny(a, changeid, 8)
+			writeAny(a, changeID(fi, ctx), 8)
 
 		case A_size:
 			size := uint64(fi.Size())
@@ -331,7 +395,10 @@ func fileInfoToAttrs(fh []byte, fi vfs.FileInfo, err error, attrsRequest map[int
 		case A_symlink_support:
 			writeAny(a, true, 4)
 
-		case A_named_attr, A_case_insensitive:
+		case A_named_attr:
+			writeAny(a, hasNamedAttrs(fi, ctx.Xattrs), 4)
+
+		case A_case_insensitive:
 			writeAny(a, false, 4)
 
 		case A_fsid:
@@ -350,60 +417,38 @@ func fileInfoToAttrs(fh []byte, fi vfs.FileInfo, err error, attrsRequest map[int
 			writeAny(a, status, 4)
 
 		case A_aclsupport:
-			writeAny(a, uint32(1), 4)
+			writeAny(a, acl.Support, 4)
 
 		case A_acl:
-			acl := []msg.NfsAce4{{
-				Type:       msg.ACE4_ACCESS_ALLOWED_ACE_TYPE,
-				Flag:       0,
-				AccessMask: msg.ACE4_WRITE_OWNER | msg.ACE4_WRITE_ACL | msg.ACE4_WRITE_DATA | msg.ACE4_READ_ACL | msg.ACE4_READ_DATA,
-				Who:        "OWNER@",
-			}, {
-				Type:       msg.ACE4_ACCESS_ALLOWED_ACE_TYPE,
-				Flag:       msg.ACE4_IDENTIFIER_GROUP,
-				AccessMask: msg.ACE4_READ_ACL | msg.ACE4_READ_DATA,
-				Who:        "GROUP@",
-			}, {
-				Type:       msg.ACE4_ACCESS_ALLOWED_ACE_TYPE,
-				Flag:       0,
-				AccessMask: 0,
-				Who:        "EVERYONE@",
-			}}
-
-			w.Write(acl) //nolint:errcheck
+			access, def := posixACLs(fi)
+
+			w.Write(acl.ToNFS4(access, def, fi.IsDir(), ctx.IDMapper)) //nolint:errcheck
 
 		case A_chown_restricted:
 			writeAny(a, true, 4) // TODO: check
 
 		case A_filehandle:
-			writeAny(a, fh, 4+len(fh)+xdr.Pad(len(fh)))
+			signed := ctx.Signer.Wrap(fh)
 
-		case A_fileid, A_mounted_on_fileid:
-			window := fh
+			// Leave out handles that are too long for nfs v4, as GETFH refuses them
+			if len(signed) > msg.NFS4_FHSIZE {
+				logger.Logger.Warnf("file handle of %d bytes is too long for nfs v4", len(signed))
 
-			if len(window) < 8 {
-				window = append(window, make([]byte, 8-len(window))...)
-			}
-
-			var fileid uint64
-
-			for fileid == 0 && len(window) >= 8 {
-				fileid = binary.LittleEndian.Uint64(window[len(window)-8:])
+				idxReturn[a] = false
 
-				window = window[:len(window)-1]
+				continue
 			}
 
-			if fileid == 0 {
-				fileid--
-			}
+			writeAny(a, signed, 4+len(signed)+xdr.Pad(len(signed)))
 
-			writeAny(a, fileid, 8)
+		case A_fileid, A_mounted_on_fileid:
+			writeAny(a, fileIDOf(fh), 8)
 
 		case A_maxname:
 			writeAny(a, 255, 4) // TODO: check
 
 		case A_maxread, A_maxwrite:
-			writeAny(a, uint64(32*1024), 8)
+			writeAny(a, uint64(maxIO4), 8)
 
 		case A_mode:
 			mask := (uint32(1) << 9) - 1
@@ -425,12 +470,12 @@ func fileInfoToAttrs(fh []byte, fi vfs.FileInfo, err error, attrsRequest map[int
 			writeAny(a, n, 4)
 
 		case A_owner:
-			owner := fmt.Sprintf("%d", fi.Uid())
+			owner := ctx.IDMapper.Owner(fi.Uid())
 
 			writeAny(a, owner, 4+len(owner)+xdr.Pad(len(owner)))
 
 		case A_owner_group:
-			group := fmt.Sprintf("%d", fi.Gid())
+			group := ctx.IDMapper.Group(fi.Gid())
 
 			writeAny(a, group, 4+len(group)+xdr.Pad(len(group)))
 
@@ -453,37 +498,34 @@ func fileInfoToAttrs(fh []byte, fi vfs.FileInfo, err error, attrsRequest map[int
 			v := bitmap4Encode(idxSupport)
 			writeAny(a, v, 4+4*len(v))
 
+		case A_fs_layout_types:
+			v := ctx.Layout.layoutTypes()
+			writeAny(a, v, 4+4*len(v))
+
+		case A_layout_blksize:
+			writeAny(a, ctx.Layout.stripeUnit(), 4)
+
+		case A_clone_blksize:
+			writeAny(a, CloneBlockSize, 4)
+
 		case A_xattr_support:
-			writeAny(a, uint32(1), 4)
+			writeAny(a, xattrSupported(fi), 4)
 
 		case A_acl_trueform:
-			writeAny(a, uint32(2), 4) // ACL_MODEL_POSIX_DRAFT
+			writeAny(a, acl.ModelPosixDraft, 4)
 
 		case A_acl_trueform_scope:
-			writeAny(a, uint32(1), 4) // ACL_SCOPE_FILE_OBJECT
+			writeAny(a, acl.ScopeFileSystem, 4)
 
 		case A_posix_default_acl:
-			acl := []msg.NfsPosixAce4{}
+			_, def := posixACLs(fi)
 
-			w.Write(acl) //nolint:errcheck
+			w.Write(acl.ToPosix(def, ctx.IDMapper)) //nolint:errcheck
 
 		case A_posix_access_acl:
-			acl := []msg.NfsPosixAce4{
-				{
-					Tag:  1,
-					Perm: 7,
-				},
-				{
-					Tag:  3,
-					Perm: 7,
-				},
-				{
-					Tag:  6,
-					Perm: 0,
-				},
-			}
+			access, _ := posixACLs(fi)
 
-			w.Write(acl) //nolint:errcheck
+			w.Write(acl.ToPosix(access, ctx.IDMapper)) //nolint:errcheck
 
 		default:
 			logger.Logger.Warnf("(!)requested attr %s not handled!", attrName)
@@ -32,7 +32,7 @@ func Authenticate(cred, verf msg.Auth) (msg.Auth, *Creds, error) {
 	var credentials Creds
 
 	if err := xdr.NewDecoder(bytes.NewBuffer(cred.Body)).Decode(&credentials); err != nil {
-		return msg.Auth{}, nil, err
+		return msg.Auth{}, nil, ErrBadCredentials
 	}
 
 	return msg.Auth{Flavor: msg.AUTH_FLAVOR_UNIX, Body: []byte{}}, &credentials, nil
@@ -89,6 +89,11 @@ func (c *Creds) Decode(decoder *xdr.Decoder) error {
 	return nil
 }
 
+// Encode is a specialized Encode function to avoid the use of the reflect package.
+func (c Creds) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(c.ExpirationValue, c.Hostname, c.UID, c.GID, c.AdditionalGroups)
+}
+
 func (c *Creds) Equal(other *Creds) bool {
 	if slices.Equal(c.AdditionalGroups, other.AdditionalGroups) {
 		return c.UID == other.UID
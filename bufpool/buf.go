@@ -1,5 +1,7 @@
 package bufpool
 
+import "io"
+
 type Bytes interface {
 	Bytes() []byte
 	Read(p []byte) (int, error)
@@ -31,6 +33,10 @@ func (b *Buf) Write(p []byte) (int, error) {
 }
 
 func (b *Buf) Read(p []byte) (int, error) {
+	if b.r == b.w && len(p) > 0 {
+		return 0, io.EOF
+	}
+
 	n := copy(p, b.buf[b.r:b.w])
 
 	b.r += n
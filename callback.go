@@ -0,0 +1,102 @@
+package nfs4go
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/kuleuven/nfs4go/bufpool"
+	"github.com/kuleuven/nfs4go/clients"
+	"github.com/kuleuven/nfs4go/msg"
+	"github.com/kuleuven/nfs4go/xdr"
+)
+
+// CallbackTimeout is the time to wait for a client to reply to a callback.
+var CallbackTimeout = 30 * time.Second
+
+// sendCallback sends a CB_COMPOUND with a CB_SEQUENCE and the given operation
+// to the client of a session, and returns the status of the compound.
+func sendCallback(cb *clients.Callback, sessionID [16]byte, op uint32, args interface{}) (uint32, error) {
+	cb.Lock()
+	defer cb.Unlock()
+
+	buf := bufpool.Get()
+
+	err := xdr.NewEncoder(buf).EncodeAll(
+		"",              // tag
+		cb.MinorVersion, // minorversion
+		uint32(0),       // callback_ident, not used in v4.1
+		uint32(2),       // number of operations
+		msg.OP4_CB_SEQUENCE,
+		msg.CB_SEQUENCE4args{
+			SessionID:  sessionID,
+			SequenceID: cb.SequenceID + 1,
+		},
+		op,
+		args,
+	)
+	if err != nil {
+		buf.Discard()
+
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), CallbackTimeout)
+	defer cancel()
+
+	reply, data, err := cb.Backchannel.Call(ctx, &msg.RPCMsgCall{
+		MsgType: msg.RPC_CALL,
+		RPCVer:  2,
+		Prog:    cb.Program,
+		Vers:    1,
+		Proc:    msg.PROC4_CB_COMPOUND,
+		Cred:    msg.Auth{Flavor: msg.AUTH_FLAVOR_NULL, Body: []byte{}},
+		Verf:    msg.Auth{Flavor: msg.AUTH_FLAVOR_NULL, Body: []byte{}},
+	}, buf)
+	if err != nil {
+		return 0, err
+	}
+
+	defer data.Discard()
+
+	if reply.ReplyStat != msg.MSG_ACCEPTED {
+		return 0, errors.New("callback denied by client")
+	}
+
+	var (
+		verf         msg.Auth
+		acceptStat   uint32
+		status       uint32
+		tag          string
+		count        uint32
+		seqOp, seqSt uint32
+	)
+
+	decoder := xdr.NewDecoder(data)
+
+	if err = decoder.DecodeAll(&verf, &acceptStat); err != nil {
+		return 0, err
+	}
+
+	if acceptStat != msg.ACCEPT_SUCCESS {
+		return 0, fmt.Errorf("callback not accepted by client: %d", acceptStat)
+	}
+
+	if err = decoder.DecodeAll(&status, &tag, &count); err != nil {
+		return 0, err
+	}
+
+	if count > 0 {
+		if err = decoder.DecodeAll(&seqOp, &seqSt); err != nil {
+			return 0, err
+		}
+
+		// The slot is only used if the client accepted the sequence id
+		if seqOp == msg.OP4_CB_SEQUENCE && seqSt == msg.NFS4_OK {
+			cb.SequenceID++
+		}
+	}
+
+	return status, nil
+}
@@ -0,0 +1,47 @@
+package clients
+
+import (
+	"context"
+	"sync"
+
+	"github.com/kuleuven/nfs4go/bufpool"
+	"github.com/kuleuven/nfs4go/msg"
+)
+
+// A Backchannel sends RPC calls to a client, over the connection
+// that the client bound to a session for callbacks.
+type Backchannel interface {
+	// Call sends the call and waits for the reply. It returns the reply header
+	// and the remaining data of the reply, starting with the verifier.
+	Call(ctx context.Context, header *msg.RPCMsgCall, args bufpool.Bytes) (*msg.RPCMsgReply, bufpool.Bytes, error)
+}
+
+// Callback holds the callback path of a session.
+type Callback struct {
+	Backchannel  Backchannel
+	Program      uint32 // Callback program number, chosen by the client
+	MinorVersion uint32 // Minor version of the session
+	SequenceID   uint32 // Sequence id of the single backchannel slot
+
+	sync.Mutex // Held during a callback, as there is only one slot
+}
+
+// SetCallback sets the callback path of a session.
+func (c *Client) SetCallback(sessionID [16]byte, callback *Callback) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.callbacks == nil {
+		c.callbacks = make(map[uint64]*Callback)
+	}
+
+	c.callbacks[CacheIDFromSessionID(sessionID)] = callback
+}
+
+// GetCallback returns the callback path of a session, or nil if there is none.
+func (c *Client) GetCallback(sessionID [16]byte) *Callback {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.callbacks[CacheIDFromSessionID(sessionID)]
+}
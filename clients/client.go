@@ -22,7 +22,8 @@ type Client struct {
 	busy         int
 	lock         *sync.Mutex
 
-	sessions map[uint64]Session
+	sessions  map[uint64]Session
+	callbacks map[uint64]*Callback
 }
 
 type Session []*Slot
@@ -128,6 +129,7 @@ func (c *Client) RemoveSession(sessionID [16]byte) {
 	}
 
 	delete(c.sessions, cacheID)
+	delete(c.callbacks, cacheID)
 }
 
 func ClientIDFromSessionID(sessionID [16]byte) uint64 {
@@ -0,0 +1,24 @@
+package nfs4go
+
+import "github.com/kuleuven/vfs"
+
+// CloneBlockSize is the alignment of ranges passed to CLONE, advertised in the clone_blksize
+// attribute. The default matches the block size of the common file systems that support reflinks.
+var CloneBlockSize = uint32(4096)
+
+// CloneRangeFile can be implemented by open files that can share data with another open
+// file using copy-on-write, like the FICLONERANGE ioctl. It returns syscall.EOPNOTSUPP if
+// the file system does not support it, and syscall.EXDEV if src is on another file system.
+type CloneRangeFile interface {
+	CloneRange(src vfs.WriterAtReaderAt, srcOffset, dstOffset, length int64) error
+}
+
+// cloneRangeFile returns the CloneRangeFile implementation of an open file, if any.
+// The FICLONERANGE ioctl is used on linux if both files opt in as NativeFile.
+func cloneRangeFile(f vfs.WriterAtReaderAt) (CloneRangeFile, bool) {
+	if c, ok := findFile[CloneRangeFile](f); ok {
+		return c, true
+	}
+
+	return findNative[CloneRangeFile](f)
+}
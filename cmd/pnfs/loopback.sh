@@ -0,0 +1,66 @@
+#!/bin/bash
+#
+# Runs a metadata server and two data servers on the loopback interface, all exporting
+# the same directory, mounts it with NFS v4.1 and checks that data written and read
+# through the mount goes through the data servers. Must be run as root.
+set -euo pipefail
+
+cd "$(dirname "$0")"
+
+MDS_PORT=${MDS_PORT:-2050}
+DS1_PORT=${DS1_PORT:-2051}
+DS2_PORT=${DS2_PORT:-2052}
+SECRET=${SECRET:-loopback}
+
+WORKDIR=$(mktemp -d)
+EXPORT=$WORKDIR/export
+MNT=$WORKDIR/mnt
+PIDS=()
+
+cleanup() {
+	umount -f "$MNT" 2>/dev/null || true
+
+	for pid in "${PIDS[@]}"; do
+		kill "$pid" 2>/dev/null || true
+	done
+
+	wait 2>/dev/null || true
+
+	rm -rf "$WORKDIR"
+}
+
+trap cleanup EXIT
+
+mkdir -p "$EXPORT" "$MNT"
+
+go build -o "$WORKDIR/pnfs" .
+
+start() {
+	"$WORKDIR/pnfs" -root "$EXPORT" -secret "$SECRET" -insecure "$@" &
+	PIDS+=($!)
+}
+
+start -listen "127.0.0.1:$DS1_PORT" -data-server
+start -listen "127.0.0.1:$DS2_PORT" -data-server
+start -listen "127.0.0.1:$MDS_PORT" -data-servers "127.0.0.1:$DS1_PORT,127.0.0.1:$DS2_PORT" -stripe-unit 65536
+
+sleep 1
+
+mount -t nfs -o "vers=4.1,port=$MDS_PORT" 127.0.0.1:/ "$MNT"
+
+head -c 8M /dev/urandom > "$WORKDIR/data"
+
+dd if="$WORKDIR/data" of="$MNT/data" bs=1M oflag=direct status=none
+dd if="$MNT/data" of="$WORKDIR/copy" bs=1M iflag=direct status=none
+
+cmp "$WORKDIR/data" "$WORKDIR/copy"
+cmp "$WORKDIR/data" "$EXPORT/data"
+
+LAYOUTGETS=$(awk -v mnt="$MNT" '$0 ~ "mounted on " mnt " " { found = 1 } found && $1 == "LAYOUTGET:" { print $2; exit }' /proc/self/mountstats)
+
+if [ "${LAYOUTGETS:-0}" -eq 0 ]; then
+	echo "no layouts were requested" >&2
+	exit 1
+fi
+
+echo "ok: $LAYOUTGETS layouts requested"
@@ -0,0 +1,95 @@
+// Command pnfs runs an nfs4go metadata server or data server for pNFS with the
+// flexible files layout. All servers must export the same directory with the same
+// secret, see loopback.sh for an example with a metadata server and two data servers.
+package main
+
+import (
+	"context"
+	"flag"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/kuleuven/nfs4go"
+	"github.com/kuleuven/nfs4go/auth"
+	"github.com/kuleuven/nfs4go/handle"
+	"github.com/kuleuven/vfs"
+	"github.com/kuleuven/vfs/fs/nativefs"
+	"github.com/kuleuven/vfs/fs/rootfs"
+	"github.com/kuleuven/vfs/runas"
+	"github.com/sirupsen/logrus"
+)
+
+func main() {
+	var (
+		listen      = flag.String("listen", ":2050", "Address to listen on")
+		root        = flag.String("root", "/srv", "Directory to export")
+		secret      = flag.String("secret", "", "Secret used to sign file handles, shared by all servers")
+		dataServers = flag.String("data-servers", "", "Comma-separated addresses of the data servers, to act as metadata server")
+		dataServer  = flag.Bool("data-server", false, "Act as data server")
+		stripeUnit  = flag.Uint("stripe-unit", nfs4go.DefaultStripeUnit, "Stripe unit in bytes")
+		insecure    = flag.Bool("insecure", false, "Allow clients on unprivileged ports")
+		debug       = flag.Bool("debug", false, "Enable debug logging")
+	)
+
+	flag.Parse()
+
+	if *debug {
+		logrus.SetLevel(logrus.DebugLevel)
+	}
+
+	signer, err := handle.New(1, []byte(*secret))
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	srv, err := nfs4go.Listen(*listen, loader(*root))
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	srv.Export.Insecure = *insecure
+	srv.Export.HandleSigner = signer
+	srv.Export.DataServer = *dataServer
+
+	if *dataServers != "" {
+		srv.Export.Layout = &nfs4go.FlexLayout{
+			DataServers: strings.Split(*dataServers, ","),
+			StripeUnit:  uint32(*stripeUnit),
+		}
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+
+	defer cancel()
+
+	err = srv.Serve(ctx)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+}
+
+func loader(root string) nfs4go.RootLoader {
+	return func(ctx context.Context, conn net.Conn, creds *auth.Creds) (vfs.AdvancedLinkFS, error) {
+		fs := rootfs.New(ctx)
+
+		runasContext, err := runas.RunAs(&runas.User{
+			UID:    creds.UID,
+			GID:    creds.GID,
+			Groups: creds.AdditionalGroups,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		err = fs.Mount("/", &nativefs.NativeServerInodeFS{
+			NativeFS: &nativefs.NativeFS{
+				Root:    root,
+				Context: runasContext,
+			},
+		}, 0)
+
+		return fs, err
+	}
+}
@@ -0,0 +1,85 @@
+#!/bin/bash
+#
+# Runs a source server and a destination server on the loopback interface, each exporting
+# its own directory, mounts both with NFS v4.2 and copies a file from the source mount to
+# the destination mount with copy_file_range(2). The client sends COPY_NOTIFY to the source
+# server and COPY to the destination server, which reads the file from the source server.
+# Must be run as root, with a kernel that supports inter-server copies.
+set -euo pipefail
+
+cd "$(dirname "$0")"
+
+SRC_PORT=${SRC_PORT:-2060}
+DST_PORT=${DST_PORT:-2061}
+
+WORKDIR=$(mktemp -d)
+SRC_EXPORT=$WORKDIR/src
+DST_EXPORT=$WORKDIR/dst
+SRC_MNT=$WORKDIR/src-mnt
+DST_MNT=$WORKDIR/dst-mnt
+PIDS=()
+
+cleanup() {
+	umount -f "$SRC_MNT" 2>/dev/null || true
+	umount -f "$DST_MNT" 2>/dev/null || true
+
+	for pid in "${PIDS[@]}"; do
+		kill "$pid" 2>/dev/null || true
+	done
+
+	wait 2>/dev/null || true
+
+	rm -rf "$WORKDIR"
+}
+
+trap cleanup EXIT
+
+mkdir -p "$SRC_EXPORT" "$DST_EXPORT" "$SRC_MNT" "$DST_MNT"
+
+go build -o "$WORKDIR/ssc" .
+
+start() {
+	"$WORKDIR/ssc" -insecure "$@" &
+	PIDS+=($!)
+}
+
+start -listen "127.0.0.1:$SRC_PORT" -root "$SRC_EXPORT"
+start -listen "127.0.0.1:$DST_PORT" -root "$DST_EXPORT" -copy-sources "127.0.0.1:$SRC_PORT"
+
+sleep 1
+
+mount -t nfs -o "vers=4.2,port=$SRC_PORT" 127.0.0.1:/ "$SRC_MNT"
+mount -t nfs -o "vers=4.2,port=$DST_PORT" 127.0.0.1:/ "$DST_MNT"
+
+head -c 8M /dev/urandom > "$SRC_EXPORT/data"
+
+python3 - "$SRC_MNT/data" "$DST_MNT/data" <<'PY'
+import os, sys
+
+src = os.open(sys.argv[1], os.O_RDONLY)
+dst = os.open(sys.argv[2], os.O_WRONLY | os.O_CREAT | os.O_TRUNC, 0o644)
+size = os.fstat(src).st_size
+copied = 0
+
+while copied < size:
+    n = os.copy_file_range(src, dst, size - copied)
+    if n == 0:
+        break
+    copied += n
+PY
+
+cmp "$SRC_EXPORT/data" "$DST_EXPORT/data"
+
+count() {
+	awk -v mnt="$1" -v op="$2:" '$0 ~ "mounted on " mnt " " { found = 1 } found && $1 == op { print $2; exit }' /proc/self/mountstats
+}
+
+COPY_NOTIFIES=$(count "$SRC_MNT" COPY_NOTIFY)
+COPIES=$(count "$DST_MNT" COPY)
+
+if [ "${COPY_NOTIFIES:-0}" -eq 0 ] || [ "${COPIES:-0}" -eq 0 ]; then
+	echo "no inter-server copy was performed" >&2
+	exit 1
+fi
+
+echo "ok: $COPY_NOTIFIES COPY_NOTIFY and $COPIES COPY"
@@ -0,0 +1,84 @@
+// Command ssc runs an nfs4go server that takes part in inter-server copies, as the
+// source server that hands out stateids with COPY_NOTIFY, as the destination server
+// that copies from the source servers listed with -copy-sources, or both.
+// See loopback.sh for an example with two servers.
+package main
+
+import (
+	"context"
+	"flag"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/kuleuven/nfs4go"
+	"github.com/kuleuven/nfs4go/auth"
+	"github.com/kuleuven/vfs"
+	"github.com/kuleuven/vfs/fs/nativefs"
+	"github.com/kuleuven/vfs/fs/rootfs"
+	"github.com/kuleuven/vfs/runas"
+	"github.com/sirupsen/logrus"
+)
+
+func main() {
+	var (
+		listen       = flag.String("listen", ":2049", "Address to listen on")
+		root         = flag.String("root", "/srv", "Directory to export")
+		copySources  = flag.String("copy-sources", "", "Comma-separated addresses of the servers to copy from, as destination server")
+		reservedPort = flag.Bool("reserved-port", false, "Connect to the source servers from a privileged port")
+		insecure     = flag.Bool("insecure", false, "Allow clients on unprivileged ports")
+		debug        = flag.Bool("debug", false, "Enable debug logging")
+	)
+
+	flag.Parse()
+
+	if *debug {
+		logrus.SetLevel(logrus.DebugLevel)
+	}
+
+	srv, err := nfs4go.Listen(*listen, loader(*root))
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	srv.Export.Insecure = *insecure
+	srv.Export.CopyReservedPort = *reservedPort
+
+	if *copySources != "" {
+		srv.Export.CopySources = strings.Split(*copySources, ",")
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+
+	defer cancel()
+
+	err = srv.Serve(ctx)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+}
+
+func loader(root string) nfs4go.RootLoader {
+	return func(ctx context.Context, conn net.Conn, creds *auth.Creds) (vfs.AdvancedLinkFS, error) {
+		fs := rootfs.New(ctx)
+
+		runasContext, err := runas.RunAs(&runas.User{
+			UID:    creds.UID,
+			GID:    creds.GID,
+			Groups: creds.AdditionalGroups,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		err = fs.Mount("/", &nativefs.NativeServerInodeFS{
+			NativeFS: &nativefs.NativeFS{
+				Root:    root,
+				Context: runasContext,
+			},
+		}, 0)
+
+		return fs, err
+	}
+}
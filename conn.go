@@ -3,20 +3,44 @@ package nfs4go
 import (
 	"bufio"
 	"context"
+	"errors"
 	"net"
 	"sync"
 
 	"github.com/kuleuven/nfs4go/auth"
+	"github.com/kuleuven/nfs4go/bufpool"
 	"github.com/kuleuven/nfs4go/clients"
+	"github.com/kuleuven/nfs4go/handle"
+	"github.com/kuleuven/nfs4go/idmap"
+	"github.com/kuleuven/nfs4go/locks"
 	"github.com/kuleuven/nfs4go/logger"
+	"github.com/kuleuven/nfs4go/msg"
 	"github.com/kuleuven/nfs4go/worker"
 	"go.uber.org/multierr"
 )
 
 // Conn represents an NFS connection
 type Conn struct {
-	Conn    net.Conn
-	Clients *clients.Clients
+	Conn     net.Conn
+	Clients  *clients.Clients
+	IDMapper *idmap.Mapper
+	Signer   *handle.Signer
+	Copies   *CopyEngine
+	Layout   *FlexLayout
+	Layouts  *Layouts
+
+	CopySources      []string // Source servers of inter-server copies
+	CopyReservedPort bool     // Connect to source servers from a privileged port
+
+	DataServer bool
+	Xattrs     *XattrMapping
+	Mounts     *Mounts
+	ExportPath string // Path of the export for the MOUNT protocol
+	Locks      *locks.Manager
+	Monitor    *Monitor
+
+	programs map[rpcProgram]Program // Programs served on the connection, the defaults if nil
+	linear   bool                   // Requests are handled one by one by ServeLinear
 
 	FS func(creds *auth.Creds, sessionID [16]byte) *worker.Worker
 
@@ -26,6 +50,18 @@ type Conn struct {
 	wg  sync.WaitGroup
 	err error
 	sync.Mutex
+
+	// Calls sent to the client over the backchannel
+	calls     map[uint32]chan callReply
+	callsDone bool
+	xid       uint32
+	callLock  sync.Mutex
+	sending   sync.WaitGroup // Calls that are being sent
+}
+
+type callReply struct {
+	Reply *msg.RPCMsgReply
+	Data  Bytes
 }
 
 func (c *Conn) Serve(ctx context.Context) error {
@@ -47,12 +83,9 @@ func (c *Conn) ServeLinear(ctx context.Context) error {
 
 	defer close(c.Request)
 
-	mux4 := &Muxv4{
-		Clients: c.Clients,
-		FS:      c.FS,
-		Logger:  logger.Logger.WithField("remote", c.Conn.RemoteAddr().String()),
-	}
-	muxOther := &MuxMismatch{}
+	c.linear = true
+
+	mux := newDispatcher(c)
 
 	response := make(chan Response, 1)
 
@@ -67,12 +100,7 @@ func (c *Conn) ServeLinear(ctx context.Context) error {
 			Data:   data,
 		}
 
-		switch header.Vers {
-		case 4:
-			mux4.Handle(request, response)
-		default:
-			muxOther.Handle(request, response)
-		}
+		mux.Handle(request, response)
 
 		resp := <-response
 
@@ -96,12 +124,9 @@ func (c *Conn) RunMux() {
 
 	defer close(c.Response)
 
-	mux4 := &Muxv4{
-		Clients: c.Clients,
-		FS:      c.FS,
-		Logger:  logger.Logger.WithField("remote", c.Conn.RemoteAddr().String()),
-	}
-	muxOther := &MuxMismatch{}
+	defer c.closeCalls()
+
+	mux := newDispatcher(c)
 
 	var muxwg sync.WaitGroup
 
@@ -111,18 +136,22 @@ func (c *Conn) RunMux() {
 		go func(request Request) {
 			defer muxwg.Done()
 
-			switch request.Header.Vers {
-			case 4:
-				mux4.Handle(request, c.Response)
-			default:
-				muxOther.Handle(request, c.Response)
-			}
+			mux.Handle(request, c.Response)
 		}(request)
 	}
 
 	muxwg.Wait()
 }
 
+// remoteHost returns the address of the client without port.
+func remoteHost(conn net.Conn) string {
+	if tcpAddr, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
+		return tcpAddr.IP.String()
+	}
+
+	return conn.RemoteAddr().String()
+}
+
 func (c *Conn) ReceiveRequests(ctx context.Context) {
 	defer c.wg.Done()
 
@@ -138,13 +167,19 @@ func (c *Conn) ReceiveRequests(ctx context.Context) {
 		defer close(intermediate)
 
 		for ctx.Err() == nil {
-			header, data, err := ReceiveCall(r)
+			header, reply, data, err := ReceiveMessage(r)
 			if err != nil {
 				c.appendError(err)
 
 				return
 			}
 
+			if reply != nil {
+				c.handleReply(reply, data)
+
+				continue
+			}
+
 			intermediate <- Request{
 				Header: header,
 				Data:   data,
@@ -194,10 +229,18 @@ func (c *Conn) SendReplies(cancel context.CancelFunc) {
 			continue
 		}
 
-		if err := SendReply(w, resp.Reply, resp.Data); err != nil {
+		var err error
+
+		if resp.Call != nil {
+			err = SendCall(w, resp.Call, resp.Data)
+		} else {
+			err = SendReply(w, resp.Reply, resp.Data)
+		}
+
+		if err != nil {
 			cancel()
 
-			c.appendError(resp.Error)
+			c.appendError(err)
 		}
 	}
 }
@@ -208,3 +251,100 @@ func (c *Conn) appendError(err error) {
 
 	c.err = multierr.Append(c.err, err)
 }
+
+// ErrBackchannelClosed is returned for calls over a connection that is closed.
+var ErrBackchannelClosed = errors.New("backchannel closed")
+
+// Call sends a call to the client over this connection and waits for the reply.
+// The xid of the header is assigned by Call.
+func (c *Conn) Call(ctx context.Context, header *msg.RPCMsgCall, args bufpool.Bytes) (*msg.RPCMsgReply, bufpool.Bytes, error) {
+	ch := make(chan callReply, 1)
+
+	c.callLock.Lock()
+
+	if c.callsDone {
+		c.callLock.Unlock()
+
+		args.Discard()
+
+		return nil, nil, ErrBackchannelClosed
+	}
+
+	if c.calls == nil {
+		c.calls = make(map[uint32]chan callReply)
+	}
+
+	c.xid++
+
+	header.Xid = c.xid
+	c.calls[header.Xid] = ch
+
+	c.sending.Add(1)
+
+	c.callLock.Unlock()
+
+	// Sent without holding the lock, so that replies are dispatched while the writer is stalled.
+	// closeCalls waits for the call to be sent, so that the channel is not closed in between.
+	c.Response <- Response{
+		Call: header,
+		Data: args,
+	}
+
+	c.sending.Done()
+
+	select {
+	case r, ok := <-ch:
+		if !ok {
+			return nil, nil, ErrBackchannelClosed
+		}
+
+		return r.Reply, r.Data, nil
+
+	case <-ctx.Done():
+		c.callLock.Lock()
+		delete(c.calls, header.Xid)
+		c.callLock.Unlock()
+
+		return nil, nil, ctx.Err()
+	}
+}
+
+// handleReply passes a reply received from the client to the waiting call.
+func (c *Conn) handleReply(reply *msg.RPCMsgReply, data Bytes) {
+	c.callLock.Lock()
+	defer c.callLock.Unlock()
+
+	ch, ok := c.calls[reply.Xid]
+	if !ok {
+		logger.Logger.Warnf("received reply for unknown call %d", reply.Xid)
+
+		data.Discard()
+
+		return
+	}
+
+	delete(c.calls, reply.Xid)
+
+	ch <- callReply{
+		Reply: reply,
+		Data:  data,
+	}
+}
+
+// closeCalls aborts the pending calls, and makes sure that no new calls are sent.
+// It returns once the calls that are being sent have been passed to the response channel.
+func (c *Conn) closeCalls() {
+	c.callLock.Lock()
+
+	c.callsDone = true
+
+	for _, ch := range c.calls {
+		close(ch)
+	}
+
+	c.calls = nil
+
+	c.callLock.Unlock()
+
+	c.sending.Wait()
+}
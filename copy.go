@@ -0,0 +1,374 @@
+package nfs4go
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/kuleuven/nfs4go/auth"
+	"github.com/kuleuven/nfs4go/clock"
+	"github.com/kuleuven/nfs4go/msg"
+	"github.com/kuleuven/nfs4go/worker"
+	"github.com/kuleuven/vfs"
+)
+
+// CopyRangeFile can be implemented by open files to copy data from another open file
+// without passing it through the server, like copy_file_range(2). It returns the number
+// of bytes copied, which is less than length only at the end of the source file.
+// It returns syscall.EOPNOTSUPP or syscall.EXDEV if the data cannot be copied this way,
+// in which case the data is copied by reading and writing.
+type CopyRangeFile interface {
+	CopyRange(src vfs.WriterAtReaderAt, srcOffset, dstOffset, length int64) (int64, error)
+}
+
+// copyRangeFile returns the CopyRangeFile implementation of an open file, if any.
+// On linux, copy_file_range(2) is used if both files opt in as NativeFile.
+func copyRangeFile(f vfs.WriterAtReaderAt) (CopyRangeFile, bool) {
+	if c, ok := findFile[CopyRangeFile](f); ok {
+		return c, true
+	}
+
+	return findNative[CopyRangeFile](f)
+}
+
+// copyChunk is the amount of data copied at once, between checks for cancellation.
+const copyChunk = 4 * 1024 * 1024
+
+// copyFile copies length bytes from src to dst, or less if the end of src is reached.
+// The number of bytes copied so far is stored in progress.
+func copyFile(ctx context.Context, dst, src vfs.WriterAtReaderAt, srcOffset, dstOffset, length int64, progress *atomic.Int64) (int64, error) {
+	c, native := copyRangeFile(dst)
+
+	var (
+		buf    []byte
+		copied int64
+	)
+
+	for copied < length {
+		if err := ctx.Err(); err != nil {
+			return copied, err
+		}
+
+		var (
+			chunk = min(copyChunk, length-copied)
+			n     int64
+			err   error
+		)
+
+		if native {
+			n, err = c.CopyRange(src, srcOffset+copied, dstOffset+copied, chunk)
+			if n == 0 && (errors.Is(err, syscall.EOPNOTSUPP) || errors.Is(err, syscall.EXDEV)) {
+				native = false
+
+				continue
+			}
+		} else {
+			if buf == nil {
+				buf = make([]byte, min(copyChunk, length))
+			}
+
+			n, err = copyChunkRW(dst, src, buf[:chunk], srcOffset+copied, dstOffset+copied)
+		}
+
+		copied += n
+
+		progress.Store(copied)
+
+		if err != nil || n < chunk {
+			return copied, err
+		}
+	}
+
+	return copied, nil
+}
+
+// copyChunkRW copies len(buf) bytes from src to dst using buf, or less at the end of src.
+func copyChunkRW(dst, src vfs.WriterAtReaderAt, buf []byte, srcOffset, dstOffset int64) (int64, error) {
+	n, err := src.ReadAt(buf, srcOffset)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return 0, err
+	}
+
+	m, err := dst.WriteAt(buf[:n], dstOffset)
+
+	return int64(m), err
+}
+
+// AsyncCopyThreshold is the minimum size of a copy that is performed asynchronously,
+// if the client allows it. Smaller copies are completed before COPY returns.
+var AsyncCopyThreshold = uint64(64 * 1024 * 1024)
+
+// CopyRetention is the time that the result of an asynchronous copy is kept
+// after it has completed, so that clients can query it with OFFLOAD_STATUS.
+var CopyRetention = 5 * time.Minute
+
+// CopyEngine runs the asynchronous copies of a server, and keeps track of their
+// progress so that clients can query and cancel them with OFFLOAD_STATUS and OFFLOAD_CANCEL.
+// It also keeps the stateids handed out by COPY_NOTIFY, which allow destination servers
+// of inter-server copies to read the source file.
+type CopyEngine struct {
+	copies   map[[3]uint32]*AsyncCopy
+	notified map[[3]uint32]*notifiedFile
+	sync.Mutex
+}
+
+// NewCopyEngine returns an empty CopyEngine.
+func NewCopyEngine() *CopyEngine {
+	return &CopyEngine{
+		copies:   map[[3]uint32]*AsyncCopy{},
+		notified: map[[3]uint32]*notifiedFile{},
+	}
+}
+
+// AsyncCopy is a copy that runs in the background.
+type AsyncCopy struct {
+	StateID  msg.StateId4 // Identifies the copy in OFFLOAD_STATUS, OFFLOAD_CANCEL and CB_OFFLOAD
+	ClientID uint64       // Client that started the copy, the only one that may query or cancel it
+	Handle   []byte       // Handle of the destination file
+	Copied   atomic.Int64 // Number of bytes copied so far
+
+	cancel context.CancelFunc
+	done   bool
+	err    error
+	lock   sync.Mutex
+}
+
+// Start registers a new copy of the given client to the destination file with the given handle,
+// and runs it in the background. After run has returned, notify is called unless the copy was cancelled.
+func (e *CopyEngine) Start(clientID uint64, handle []byte, run func(ctx context.Context, c *AsyncCopy) error, notify func(c *AsyncCopy)) *AsyncCopy {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	c := &AsyncCopy{
+		StateID: msg.StateId4{
+			SeqId: 1,
+			Other: randOther(),
+		},
+		ClientID: clientID,
+		Handle:   handle,
+		cancel:   cancel,
+	}
+
+	e.Lock()
+
+	for e.exists(c.StateID.Other) {
+		c.StateID.Other = randOther()
+	}
+
+	e.copies[c.StateID.Other] = c
+
+	e.Unlock()
+
+	go func() {
+		defer cancel()
+
+		err := run(ctx, c)
+
+		c.lock.Lock()
+		c.done = true
+		c.err = err
+		c.lock.Unlock()
+
+		if errors.Is(err, context.Canceled) {
+			return
+		}
+
+		time.AfterFunc(CopyRetention, func() {
+			e.remove(c.StateID)
+		})
+
+		notify(c)
+	}()
+
+	return c
+}
+
+// Get returns the copy with the given stateid.
+func (e *CopyEngine) Get(stateID msg.StateId4) (*AsyncCopy, bool) {
+	e.Lock()
+	defer e.Unlock()
+
+	c, ok := e.copies[stateID.Other]
+
+	return c, ok
+}
+
+// Cancel stops the copy with the given stateid, and forgets about it.
+func (e *CopyEngine) Cancel(stateID msg.StateId4) bool {
+	c, ok := e.remove(stateID)
+	if ok {
+		c.cancel()
+	}
+
+	return ok
+}
+
+func (e *CopyEngine) remove(stateID msg.StateId4) (*AsyncCopy, bool) {
+	e.Lock()
+	defer e.Unlock()
+
+	c, ok := e.copies[stateID.Other]
+
+	delete(e.copies, stateID.Other)
+
+	return c, ok
+}
+
+// exists returns whether a copy or notified file with the given stateid exists.
+// The caller must hold the lock.
+func (e *CopyEngine) exists(other [3]uint32) bool {
+	_, copying := e.copies[other]
+	_, notified := e.notified[other]
+
+	return copying || notified
+}
+
+// Result returns whether the copy has completed, and if so, its error.
+func (c *AsyncCopy) Result() (bool, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.done, c.err
+}
+
+// notifiedFile is an open file that may be read by the destination server of an
+// inter-server copy, using the stateid that was returned by COPY_NOTIFY.
+type notifiedFile struct {
+	handle       []byte
+	destinations []net.IP // Addresses of the destination server
+	fileID       uint64
+	fs           *worker.Worker
+	expires      time.Time
+	timer        *time.Timer
+}
+
+// Notify registers the open file with the given id of the worker for an inter-server copy,
+// and returns the stateid that the destination server, which connects from one of the
+// given addresses, uses to read it. The stateid remains
+// valid until it has not been used for the given lease time. The caller must have called
+// fs.Use(), the worker is released when the stateid expires.
+func (e *CopyEngine) Notify(fs *worker.Worker, fileID uint64, handle []byte, destinations []net.IP, lease time.Duration) msg.StateId4 {
+	stateID := msg.StateId4{
+		SeqId: 1,
+		Other: randOther(),
+	}
+
+	n := &notifiedFile{
+		handle:       handle,
+		destinations: destinations,
+		fileID:       fileID,
+		fs:           fs,
+		expires:      clock.Now().Add(lease),
+	}
+
+	e.Lock()
+	defer e.Unlock()
+
+	for e.exists(stateID.Other) {
+		stateID.Other = randOther()
+	}
+
+	e.notified[stateID.Other] = n
+
+	n.timer = time.AfterFunc(lease, func() {
+		e.expire(stateID, n)
+	})
+
+	return stateID
+}
+
+// Notified returns the open file for a stateid returned by COPY_NOTIFY, if it belongs
+// to the given handle and credentials, and the caller connects from the address of the
+// destination server. The lease of the stateid is renewed.
+func (e *CopyEngine) Notified(stateID msg.StateId4, handle []byte, creds *auth.Creds, remote net.Addr, lease time.Duration) (*worker.File, bool) {
+	e.Lock()
+
+	n, ok := e.notified[stateID.Other]
+	if ok {
+		n.expires = clock.Now().Add(lease)
+	}
+
+	e.Unlock()
+
+	if !ok || !bytes.Equal(n.handle, handle) || !n.fs.Creds.Equal(creds) || !n.allows(remote) {
+		return nil, false
+	}
+
+	f, ok := n.fs.GetFile(n.fileID)
+	if !ok || !bytes.Equal(f.Handle, handle) {
+		return nil, false
+	}
+
+	return f, true
+}
+
+// allows returns whether the destination server connects from the given address.
+func (n *notifiedFile) allows(remote net.Addr) bool {
+	tcp, ok := remote.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+
+	return slices.ContainsFunc(n.destinations, tcp.IP.Equal)
+}
+
+// Revoke invalidates a stateid returned by COPY_NOTIFY, if it belongs to the given handle.
+func (e *CopyEngine) Revoke(stateID msg.StateId4, handle []byte) bool {
+	e.Lock()
+
+	n, ok := e.notified[stateID.Other]
+	if ok && bytes.Equal(n.handle, handle) {
+		delete(e.notified, stateID.Other)
+	} else {
+		ok = false
+	}
+
+	e.Unlock()
+
+	if ok && n.timer.Stop() {
+		n.fs.Close()
+	}
+
+	return ok
+}
+
+// expire removes a notified file once its lease has passed, or waits for the renewed lease.
+func (e *CopyEngine) expire(stateID msg.StateId4, n *notifiedFile) {
+	e.Lock()
+
+	if remaining := n.expires.Sub(clock.Now()); remaining > 0 {
+		n.timer.Reset(remaining)
+
+		e.Unlock()
+
+		return
+	}
+
+	if e.notified[stateID.Other] == n {
+		delete(e.notified, stateID.Other)
+	}
+
+	e.Unlock()
+
+	n.fs.Close()
+}
+
+func randOther() [3]uint32 {
+	var b [12]byte
+
+	rand.Read(b[:]) //nolint:errcheck
+
+	return [3]uint32{
+		binary.BigEndian.Uint32(b[0:4]),
+		binary.BigEndian.Uint32(b[4:8]),
+		binary.BigEndian.Uint32(b[8:12]),
+	}
+}
@@ -0,0 +1,208 @@
+package nfs4go
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/kuleuven/nfs4go/logger"
+	"github.com/kuleuven/nfs4go/msg"
+	"github.com/sirupsen/logrus"
+)
+
+// Program creates the Mux that serves a version of an RPC program on a connection.
+// It is called once for each connection.
+type Program func(c *Conn) Mux
+
+// rpcProgram is a version of an RPC program served by the Server.
+type rpcProgram struct {
+	Prog uint32
+	Vers uint32
+}
+
+// defaultPrograms returns the programs that are served by a new Server.
+func defaultPrograms() map[rpcProgram]Program {
+	return map[rpcProgram]Program{
+		{msg.NFS3_PROGRAM, msg.NFS3_VERSION}: newMuxv3,
+		{msg.NFS4_PROGRAM, 4}:                newMuxv4,
+		{msg.MOUNT_PROGRAM, msg.MOUNT_V3}:    newMuxMount,
+		{msg.NLM_PROGRAM, msg.NLM_V4}:        newMuxNLM,
+		{msg.SM_PROGRAM, msg.SM_VERS}:        newMuxNSM,
+	}
+}
+
+// Register serves a version of an RPC program on the port of the server, next to NFS, MOUNT,
+// NLM and NSM, e.g. a custom administration program. Calls with the given program and version
+// are passed to the Mux that is created by program for each connection. An existing registration
+// of the same program and version, including the default ones, is replaced.
+// Registered programs are also advertised by the portmapper. Register should be called before
+// Serve and ServePortmapper; connections that are already established are not affected.
+func (s *Server) Register(prog, vers uint32, program Program) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	// Copy on write, so that connections can use the map without locking
+	programs := make(map[rpcProgram]Program, len(s.programs)+1)
+
+	for key, p := range s.programs {
+		programs[key] = p
+	}
+
+	programs[rpcProgram{prog, vers}] = program
+
+	s.programs = programs
+}
+
+// getPrograms returns the programs to serve on a new connection.
+func (s *Server) getPrograms() map[rpcProgram]Program {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.programs
+}
+
+// registered returns the programs that are served by the Server, sorted by program and version.
+func (s *Server) registered() []rpcProgram {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	programs := make([]rpcProgram, 0, len(s.programs))
+
+	for key := range s.programs {
+		programs = append(programs, key)
+	}
+
+	slices.SortFunc(programs, func(a, b rpcProgram) int {
+		if c := cmp.Compare(a.Prog, b.Prog); c != 0 {
+			return c
+		}
+
+		return cmp.Compare(a.Vers, b.Vers)
+	})
+
+	return programs
+}
+
+// dispatcher passes the calls on a connection to the Mux of their program and version.
+// Calls to programs that are not served receive PROG_UNAVAIL, and calls to versions
+// that are not served receive PROG_MISMATCH with the lowest and highest served version.
+type dispatcher struct {
+	muxes map[rpcProgram]Mux
+}
+
+func newDispatcher(c *Conn) *dispatcher {
+	programs := c.programs
+	if programs == nil {
+		programs = defaultPrograms()
+	}
+
+	d := &dispatcher{
+		muxes: make(map[rpcProgram]Mux, len(programs)),
+	}
+
+	for key, program := range programs {
+		d.muxes[key] = program(c)
+	}
+
+	return d
+}
+
+func (d *dispatcher) Handle(request Request, response chan<- Response) {
+	if mux, ok := d.muxes[rpcProgram{request.Header.Prog, request.Header.Vers}]; ok {
+		mux.Handle(request, response)
+
+		return
+	}
+
+	var versions []uint32
+
+	for key := range d.muxes {
+		if key.Prog == request.Header.Prog {
+			versions = append(versions, key.Vers)
+		}
+	}
+
+	if len(versions) == 0 {
+		logger.Logger.Debugf("call to program %d that is not served", request.Header.Prog)
+
+		(&MuxUnavailable{}).Handle(request, response)
+
+		return
+	}
+
+	(&MuxMismatch{
+		Low:  slices.Min(versions),
+		High: slices.Max(versions),
+	}).Handle(request, response)
+}
+
+// logger returns the logger for the muxes of the connection.
+func (c *Conn) logger() *logrus.Entry {
+	return logger.Logger.WithField("remote", c.Conn.RemoteAddr().String())
+}
+
+func newMuxv4(c *Conn) Mux {
+	mux := &Muxv4{
+		Clients:  c.Clients,
+		FS:       c.FS,
+		Logger:   c.logger(),
+		IDMapper: c.IDMapper,
+		Signer:   c.Signer,
+		Copies:   c.Copies,
+		Addr:     c.Conn.LocalAddr(),
+		Remote:   c.Conn.RemoteAddr(),
+		Layout:   c.Layout,
+		Layouts:  c.Layouts,
+		Locks:    c.Locks,
+
+		CopySources:      c.CopySources,
+		CopyReservedPort: c.CopyReservedPort,
+
+		DataServer: c.DataServer,
+		Xattrs:     c.Xattrs,
+	}
+
+	// Replies to callbacks are only received when the requests are handled concurrently
+	if !c.linear {
+		mux.Backchannel = c
+	}
+
+	return mux
+}
+
+func newMuxv3(c *Conn) Mux {
+	return &Muxv3{
+		FS:     c.FS,
+		Logger: c.logger(),
+		Signer: c.Signer,
+	}
+}
+
+func newMuxMount(c *Conn) Mux {
+	return &MuxMount{
+		FS:     c.FS,
+		Logger: c.logger(),
+		Signer: c.Signer,
+		Mounts: c.Mounts,
+		Export: c.ExportPath,
+		Host:   remoteHost(c.Conn),
+	}
+}
+
+func newMuxNLM(c *Conn) Mux {
+	return &MuxNLM{
+		Logger:  c.logger(),
+		Signer:  c.Signer,
+		Locks:   c.Locks,
+		Monitor: c.Monitor,
+		Host:    remoteHost(c.Conn),
+	}
+}
+
+func newMuxNSM(c *Conn) Mux {
+	return &MuxNSM{
+		Logger:  c.logger(),
+		Locks:   c.Locks,
+		Monitor: c.Monitor,
+		Host:    remoteHost(c.Conn),
+	}
+}
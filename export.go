@@ -0,0 +1,86 @@
+package nfs4go
+
+import (
+	"net"
+	"path"
+
+	"github.com/kuleuven/nfs4go/handle"
+)
+
+// Export contains the policy that is applied to the file system exposed by a Server.
+type Export struct {
+	// Insecure allows clients to connect from unprivileged source ports (>= 1024),
+	// similar to the "insecure" export option of the linux kernel server.
+	// This is needed for userspace clients such as libnfs or NFS-Ganesha proxies.
+	Insecure bool
+
+	// InsecureNetworks lists the networks from which unprivileged source ports
+	// are accepted, even if Insecure is false.
+	InsecureNetworks []*net.IPNet
+
+	// HandleSigner signs the file handles that are handed out, so that
+	// forged handles or handles of other exports are rejected with
+	// NFS4ERR_BADHANDLE. If nil, the handles of the file system are used as is.
+	HandleSigner *handle.Signer
+
+	// Layout offers pNFS with the flexible files layout to v4.1 and v4.2 clients,
+	// which then read and write files on the listed data servers. If nil, clients
+	// perform all I/O through this server.
+	Layout *FlexLayout
+
+	// DataServer makes the server act as a pNFS data server for the clients of
+	// a server with Layout set, accepting READ, WRITE and COMMIT with the anonymous
+	// stateid. The data servers and the metadata server must serve the same file
+	// system with the same HandleSigner.
+	DataServer bool
+
+	// Xattrs determines which extended attributes of the file system are exposed
+	// to clients as extended attributes and named attributes, and under which names.
+	// If nil, the extended attributes in the user namespace are exposed.
+	Xattrs *XattrMapping
+
+	// CopySources lists the addresses (host:port) of the servers from which files
+	// are copied when this server is the destination of an inter-server copy.
+	// COPY requests that name other source servers are refused, so that clients
+	// cannot make the server connect to arbitrary addresses. If empty,
+	// inter-server copies to this server are disabled.
+	CopySources []string
+
+	// CopyReservedPort makes the server connect to the source servers of
+	// inter-server copies from a privileged source port, for source servers that
+	// only accept those. The credentials asserted by the client are passed on,
+	// so this should only be set if the source servers trust this server.
+	CopyReservedPort bool
+
+	// Path is the directory under which NFSv3 clients find the export with the
+	// MOUNT protocol, e.g. with showmount -e. Clients can mount Path or any
+	// directory below it. If empty, the export is listed as "/".
+	Path string
+}
+
+// DefaultExport is the export policy used by New.
+var DefaultExport = Export{}
+
+// MountPath returns the path under which the export is listed by the MOUNT protocol.
+func (e *Export) MountPath() string {
+	if e.Path == "" {
+		return "/"
+	}
+
+	return path.Clean("/" + e.Path)
+}
+
+// AllowPort returns whether a connection from the given address is accepted.
+func (e *Export) AllowPort(addr *net.TCPAddr) bool {
+	if addr.Port < 1024 || e.Insecure {
+		return true
+	}
+
+	for _, network := range e.InsecureNetworks {
+		if network.Contains(addr.IP) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -7,3 +7,16 @@ func FileOther(fileID uint64, clientSeqID uint32) [3]uint32 {
 func FileID(fileOther [3]uint32) uint64 {
 	return uint64(fileOther[0])<<32 + uint64(fileOther[1])
 }
+
+// lockOwnerBit marks the stateids of lock owners, which refer to the open file
+// through which the file was locked and the index of the lock owner.
+const lockOwnerBit = uint32(1 << 31)
+
+func LockOther(fileID uint64, index uint32) [3]uint32 {
+	return FileOther(fileID, lockOwnerBit|index)
+}
+
+// LockOwnerIndex returns the index of the lock owner of a lock stateid.
+func LockOwnerIndex(other [3]uint32) (uint32, bool) {
+	return other[2] &^ lockOwnerBit, other[2]&lockOwnerBit != 0
+}
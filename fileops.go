@@ -0,0 +1,34 @@
+package nfs4go
+
+// findFile returns the implementation of T of the open file f. Optional interfaces are
+// only used if the file of the backend implements them itself: wrappers of the backend
+// are not looked through, as calling the wrapped file directly would bypass whatever
+// the wrapper enforces. Only the read-only and write-only wrappers of this package,
+// see NopWriterAt and NopReaderAt, are unwrapped, as the operations check the access
+// mode of the open file themselves.
+func findFile[T any](f any) (T, bool) {
+	switch w := f.(type) {
+	case *nopWriterAt:
+		f = w.ReaderAt
+	case *nopReaderAt:
+		f = w.WriterAt
+	}
+
+	t, ok := f.(T)
+
+	return t, ok
+}
+
+// NativeFile can be implemented by open files of the backend that are backed by a local
+// file, to opt in to the native implementation of SparseFile, AllocatorFile, CopyRangeFile,
+// CloneRangeFile and AdviseFile on linux. The system calls are made on the returned file
+// descriptor, so the open file must not enforce anything on these operations, and must
+// not depend on the file offset.
+type NativeFile interface {
+	NativeFd() uintptr
+}
+
+// syncer is implemented by files that can be flushed to stable storage, such as *os.File.
+type syncer interface {
+	Sync() error
+}
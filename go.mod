@@ -6,6 +6,7 @@ require (
 	github.com/kuleuven/vfs v0.0.5
 	github.com/sirupsen/logrus v1.9.3
 	go.uber.org/multierr v1.11.0
+	golang.org/x/sys v0.36.0
 )
 
 require (
@@ -13,5 +14,4 @@ require (
 	github.com/kuleuven/iron v0.4.4 // indirect
 	github.com/pkg/xattr v0.4.12 // indirect
 	github.com/stretchr/testify v1.8.0 // indirect
-	golang.org/x/sys v0.36.0 // indirect
 )
@@ -0,0 +1,136 @@
+package idmap
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kuleuven/nfs4go/clock"
+	"github.com/kuleuven/nfs4go/logger"
+)
+
+// Files is a Resolver backed by files in the passwd(5) and group(5) format.
+// The files are read again when they are modified.
+type Files struct {
+	PasswdFile string
+	GroupFile  string
+
+	static      *Static
+	lastCheck   time.Time
+	passwdMtime time.Time
+	groupMtime  time.Time
+	sync.Mutex
+}
+
+// RefreshInterval is the minimal interval between checks whether the files are modified.
+var RefreshInterval = 30 * time.Second
+
+// NewFiles returns a Resolver for the given passwd and group files.
+func NewFiles(passwdFile, groupFile string) *Files {
+	return &Files{
+		PasswdFile: passwdFile,
+		GroupFile:  groupFile,
+	}
+}
+
+func (f *Files) UserName(uid uint32) (string, bool) {
+	return f.get().UserName(uid)
+}
+
+func (f *Files) GroupName(gid uint32) (string, bool) {
+	return f.get().GroupName(gid)
+}
+
+func (f *Files) UID(name string) (uint32, bool) {
+	return f.get().UID(name)
+}
+
+func (f *Files) GID(name string) (uint32, bool) {
+	return f.get().GID(name)
+}
+
+func (f *Files) get() *Static {
+	f.Lock()
+	defer f.Unlock()
+
+	if f.static != nil && clock.Since(f.lastCheck) < RefreshInterval {
+		return f.static
+	}
+
+	f.lastCheck = clock.Now()
+
+	passwdMtime := mtime(f.PasswdFile)
+	groupMtime := mtime(f.GroupFile)
+
+	if f.static != nil && passwdMtime.Equal(f.passwdMtime) && groupMtime.Equal(f.groupMtime) {
+		return f.static
+	}
+
+	users, err := parseFile(f.PasswdFile, 2)
+	if err != nil {
+		logger.Logger.Errorf("failed to read %s: %v", f.PasswdFile, err)
+	}
+
+	groups, err := parseFile(f.GroupFile, 2)
+	if err != nil {
+		logger.Logger.Errorf("failed to read %s: %v", f.GroupFile, err)
+	}
+
+	f.static = NewStatic(users, groups)
+	f.passwdMtime = passwdMtime
+	f.groupMtime = groupMtime
+
+	return f.static
+}
+
+func mtime(path string) time.Time {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return fi.ModTime()
+}
+
+// parseFile parses a colon separated file, with the name in the
+// first column and the numeric id in the given column.
+func parseFile(path string, idColumn int) (map[uint32]string, error) {
+	result := map[uint32]string{}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return result, err
+	}
+
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, ":")
+		if len(fields) <= idColumn || fields[0] == "" {
+			continue
+		}
+
+		id, err := strconv.ParseUint(fields[idColumn], 10, 32)
+		if err != nil {
+			continue
+		}
+
+		// Keep the first entry, as getpwuid(3) does
+		if _, ok := result[uint32(id)]; !ok {
+			result[uint32(id)] = fields[0]
+		}
+	}
+
+	return result, scanner.Err()
+}
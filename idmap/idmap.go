@@ -0,0 +1,136 @@
+// Package idmap translates between numeric user and group ids and the
+// "user@domain" strings used by NFSv4 for the owner and owner_group attributes.
+package idmap
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/kuleuven/nfs4go/msg"
+)
+
+// Resolver resolves user and group names, without domain.
+type Resolver interface {
+	UserName(uid uint32) (string, bool)
+	GroupName(gid uint32) (string, bool)
+	UID(name string) (uint32, bool)
+	GID(name string) (uint32, bool)
+}
+
+// ErrBadOwner is returned when an owner string cannot be translated.
+var ErrBadOwner = msg.Error(msg.NFS4ERR_BADOWNER)
+
+// Mapper renders and parses owner strings using a Resolver.
+// A nil Mapper, or a Mapper without Resolver, uses numeric strings only.
+type Mapper struct {
+	// Domain is appended to all names, it should match the Domain
+	// configured in idmapd.conf on the clients.
+	Domain string
+
+	// Resolver used to look up names.
+	Resolver Resolver
+
+	// Numeric forces numeric strings to be sent to clients, for clients
+	// that are configured with nfs4_disable_idmapping. Incoming numeric
+	// strings are always accepted.
+	Numeric bool
+}
+
+// Owner returns the owner string for the given uid.
+// If the uid cannot be resolved, a numeric string is returned.
+func (m *Mapper) Owner(uid uint32) string {
+	if m.numeric() {
+		return strconv.FormatUint(uint64(uid), 10)
+	}
+
+	name, ok := m.Resolver.UserName(uid)
+	if !ok {
+		return strconv.FormatUint(uint64(uid), 10)
+	}
+
+	return m.qualify(name)
+}
+
+// Group returns the owner_group string for the given gid.
+// If the gid cannot be resolved, a numeric string is returned.
+func (m *Mapper) Group(gid uint32) string {
+	if m.numeric() {
+		return strconv.FormatUint(uint64(gid), 10)
+	}
+
+	name, ok := m.Resolver.GroupName(gid)
+	if !ok {
+		return strconv.FormatUint(uint64(gid), 10)
+	}
+
+	return m.qualify(name)
+}
+
+// ParseOwner returns the uid for the given owner string.
+// It returns ErrBadOwner if the string cannot be translated.
+func (m *Mapper) ParseOwner(owner string) (uint32, error) {
+	if id, err := strconv.ParseUint(owner, 10, 32); err == nil {
+		return uint32(id), nil
+	}
+
+	name, ok := m.unqualify(owner)
+	if !ok || m == nil || m.Resolver == nil {
+		return 0, ErrBadOwner
+	}
+
+	uid, ok := m.Resolver.UID(name)
+	if !ok {
+		return 0, ErrBadOwner
+	}
+
+	return uid, nil
+}
+
+// ParseGroup returns the gid for the given owner_group string.
+// It returns ErrBadOwner if the string cannot be translated.
+func (m *Mapper) ParseGroup(group string) (uint32, error) {
+	if id, err := strconv.ParseUint(group, 10, 32); err == nil {
+		return uint32(id), nil
+	}
+
+	name, ok := m.unqualify(group)
+	if !ok || m == nil || m.Resolver == nil {
+		return 0, ErrBadOwner
+	}
+
+	gid, ok := m.Resolver.GID(name)
+	if !ok {
+		return 0, ErrBadOwner
+	}
+
+	return gid, nil
+}
+
+func (m *Mapper) numeric() bool {
+	return m == nil || m.Resolver == nil || m.Numeric
+}
+
+func (m *Mapper) qualify(name string) string {
+	if m.Domain == "" {
+		return name
+	}
+
+	return name + "@" + m.Domain
+}
+
+func (m *Mapper) unqualify(owner string) (string, bool) {
+	name, domain, found := strings.Cut(owner, "@")
+	if name == "" {
+		return "", false
+	}
+
+	if !found {
+		return name, true
+	}
+
+	if m == nil || !strings.EqualFold(domain, m.Domain) {
+		return "", false
+	}
+
+	return name, true
+}
@@ -0,0 +1,54 @@
+package idmap
+
+// Static is a Resolver backed by fixed maps.
+type Static struct {
+	Users  map[uint32]string
+	Groups map[uint32]string
+
+	uids map[string]uint32
+	gids map[string]uint32
+}
+
+// NewStatic returns a Resolver for the given users and groups.
+func NewStatic(users, groups map[uint32]string) *Static {
+	s := &Static{
+		Users:  users,
+		Groups: groups,
+		uids:   make(map[string]uint32, len(users)),
+		gids:   make(map[string]uint32, len(groups)),
+	}
+
+	for uid, name := range users {
+		s.uids[name] = uid
+	}
+
+	for gid, name := range groups {
+		s.gids[name] = gid
+	}
+
+	return s
+}
+
+func (s *Static) UserName(uid uint32) (string, bool) {
+	name, ok := s.Users[uid]
+
+	return name, ok
+}
+
+func (s *Static) GroupName(gid uint32) (string, bool) {
+	name, ok := s.Groups[gid]
+
+	return name, ok
+}
+
+func (s *Static) UID(name string) (uint32, bool) {
+	uid, ok := s.uids[name]
+
+	return uid, ok
+}
+
+func (s *Static) GID(name string) (uint32, bool) {
+	gid, ok := s.gids[name]
+
+	return gid, ok
+}
@@ -0,0 +1,735 @@
+package nfs4go
+
+import (
+	"bytes"
+	"errors"
+	"hash/fnv"
+	"net"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/kuleuven/nfs4go/clients"
+	"github.com/kuleuven/nfs4go/clock"
+	"github.com/kuleuven/nfs4go/msg"
+	"github.com/kuleuven/nfs4go/worker"
+	"github.com/kuleuven/nfs4go/xdr"
+	"github.com/kuleuven/vfs"
+)
+
+// FlexLayout configures pNFS with the flexible files layout (RFC 8435), so that clients
+// read and write files directly on the data servers instead of through this server.
+// The data servers are nfs4go servers with Export.DataServer set, which serve the same
+// file system with the same Export.HandleSigner, e.g. on shared storage. Files are
+// striped over all data servers at the same offsets as in the file. The data servers
+// are accessed with the anonymous stateid and the credentials of the user that requested
+// the layout, so that the data servers check permissions as usual.
+type FlexLayout struct {
+	// DataServers are the addresses (host:port) of the data servers.
+	DataServers []string
+
+	// StripeUnit is the amount of data stored on a data server before continuing with
+	// the next one. If zero, DefaultStripeUnit is used.
+	StripeUnit uint32
+}
+
+// DefaultStripeUnit is the stripe unit used if FlexLayout.StripeUnit is zero.
+const DefaultStripeUnit = 1024 * 1024
+
+// layoutIOSize is the maximum size of a READ or WRITE that clients send to data servers.
+const layoutIOSize = 1024 * 1024
+
+// layoutTypes returns the value of the fs_layout_types attribute.
+func (l *FlexLayout) layoutTypes() []uint32 {
+	if l == nil {
+		return []uint32{}
+	}
+
+	return []uint32{msg.LAYOUT4_FLEX_FILES}
+}
+
+// stripeUnit returns the value of the layout_blksize attribute.
+func (l *FlexLayout) stripeUnit() uint32 {
+	if l == nil || l.StripeUnit == 0 {
+		return DefaultStripeUnit
+	}
+
+	return l.StripeUnit
+}
+
+// deviceID returns the device id of a data server. It is derived from its address,
+// so that it does not change when data servers are added or removed.
+func deviceID(addr string) [16]byte {
+	var id [16]byte
+
+	h := fnv.New128a()
+
+	h.Write([]byte(addr)) //nolint:errcheck
+
+	copy(id[:], h.Sum(nil))
+
+	return id
+}
+
+// dataServer returns the address of the data server with the given device id.
+func (l *FlexLayout) dataServer(id [16]byte) (string, bool) {
+	if l == nil {
+		return "", false
+	}
+
+	for _, addr := range l.DataServers {
+		if deviceID(addr) == id {
+			return addr, true
+		}
+	}
+
+	return "", false
+}
+
+// deviceAddr returns the device address of a data server, which is contacted with the given minor version.
+func deviceAddr(addr string, minorVer uint32) (msg.FFDeviceAddr4, error) {
+	tcp, err := net.ResolveTCPAddr("tcp", addr)
+	if err != nil {
+		return msg.FFDeviceAddr4{}, err
+	}
+
+	loc, _ := universalAddress(tcp)
+
+	return msg.FFDeviceAddr4{
+		NetAddrs: []msg.NetAddr4{loc.NetAddr},
+		Versions: []msg.FFDeviceVersions4{
+			{
+				Version:      4,
+				MinorVersion: minorVer,
+				RSize:        layoutIOSize,
+				WSize:        layoutIOSize,
+			},
+		},
+	}, nil
+}
+
+// Layouts keeps track of the layouts handed out by LAYOUTGET, per client and file,
+// until they are returned with LAYOUTRETURN or the file is closed.
+// Layouts of clients that no longer exist are forgotten. A nil Layouts holds no layouts.
+type Layouts struct {
+	layouts   map[layoutKey]*layoutState
+	lastSweep time.Time
+	sync.Mutex
+}
+
+type layoutKey struct {
+	clientID uint64
+	handle   string
+}
+
+type layoutState struct {
+	stateID  msg.StateId4
+	ioMode   uint32
+	writable bool // Whether the client has the file open for writing
+}
+
+// NewLayouts returns an empty Layouts.
+func NewLayouts() *Layouts {
+	return &Layouts{
+		layouts: map[layoutKey]*layoutState{},
+	}
+}
+
+// Get records a layout for the file with the given iomode, and returns the layout stateid.
+// The seqid of the layout stateid is increased for each layout of the same file.
+// Writable tells whether the open file the layout is granted for is open for writing.
+func (l *Layouts) Get(clientID uint64, handle []byte, ioMode uint32, writable bool, exists func(clientID uint64) bool) msg.StateId4 {
+	l.Lock()
+	defer l.Unlock()
+
+	l.sweep(exists)
+
+	key := layoutKey{clientID, string(handle)}
+
+	state, ok := l.layouts[key]
+	if !ok {
+		state = &layoutState{
+			stateID: msg.StateId4{Other: randOther()},
+		}
+
+		l.layouts[key] = state
+	}
+
+	state.stateID.SeqId++
+	state.ioMode = max(state.ioMode, ioMode)
+	state.writable = writable
+
+	return state.stateID
+}
+
+// Writable returns whether the file of the layout is open for writing, and whether
+// the stateid is the layout stateid of the file.
+func (l *Layouts) Writable(clientID uint64, handle []byte, stateID msg.StateId4) (bool, bool) {
+	if l == nil {
+		return false, false
+	}
+
+	l.Lock()
+	defer l.Unlock()
+
+	state, ok := l.layouts[layoutKey{clientID, string(handle)}]
+	if !ok || state.stateID.Other != stateID.Other {
+		return false, false
+	}
+
+	return state.writable, true
+}
+
+// SetWritable records whether the file of the layout is still open for writing,
+// e.g. when one of several open files is closed.
+func (l *Layouts) SetWritable(clientID uint64, handle []byte, writable bool) {
+	if l == nil {
+		return
+	}
+
+	l.Lock()
+	defer l.Unlock()
+
+	if state, ok := l.layouts[layoutKey{clientID, string(handle)}]; ok {
+		state.writable = writable
+	}
+}
+
+// Valid returns whether the stateid is the layout stateid of the file.
+func (l *Layouts) Valid(clientID uint64, handle []byte, stateID msg.StateId4) bool {
+	if l == nil {
+		return false
+	}
+
+	l.Lock()
+	defer l.Unlock()
+
+	state, ok := l.layouts[layoutKey{clientID, string(handle)}]
+
+	return ok && state.stateID.Other == stateID.Other
+}
+
+// Return handles a LAYOUTRETURN of the file. If the whole file is returned with a matching
+// iomode, the layout is forgotten and nil is returned; otherwise the new layout stateid.
+func (l *Layouts) Return(clientID uint64, handle []byte, stateID msg.StateId4, ioMode uint32, whole bool) (*msg.StateId4, uint32) {
+	if l == nil {
+		return nil, msg.NFS4_OK
+	}
+
+	l.Lock()
+	defer l.Unlock()
+
+	key := layoutKey{clientID, string(handle)}
+
+	state, ok := l.layouts[key]
+
+	switch {
+	case !ok:
+		return nil, msg.NFS4_OK
+	case state.stateID.Other != stateID.Other:
+		return nil, msg.NFS4ERR_BAD_STATEID
+	case whole && (ioMode == msg.LAYOUTIOMODE4_ANY || ioMode == state.ioMode):
+		delete(l.layouts, key)
+
+		return nil, msg.NFS4_OK
+	}
+
+	state.stateID.SeqId++
+
+	stateID = state.stateID
+
+	return &stateID, msg.NFS4_OK
+}
+
+// ReturnFile forgets the layout of a file, e.g. when it is closed.
+func (l *Layouts) ReturnFile(clientID uint64, handle []byte) {
+	if l == nil {
+		return
+	}
+
+	l.Lock()
+	defer l.Unlock()
+
+	delete(l.layouts, layoutKey{clientID, string(handle)})
+}
+
+// ReturnAll forgets all layouts of a client.
+func (l *Layouts) ReturnAll(clientID uint64) {
+	if l == nil {
+		return
+	}
+
+	l.Lock()
+	defer l.Unlock()
+
+	for key := range l.layouts {
+		if key.clientID == clientID {
+			delete(l.layouts, key)
+		}
+	}
+}
+
+// sweep forgets the layouts of clients that no longer exist, at most once per lease time.
+func (l *Layouts) sweep(exists func(clientID uint64) bool) {
+	if clock.Now().Sub(l.lastSweep) < clients.ClientExpiration {
+		return
+	}
+
+	l.lastSweep = clock.Now()
+
+	for key := range l.layouts {
+		if !exists(key.clientID) {
+			delete(l.layouts, key)
+		}
+	}
+}
+
+// pnfsFlags returns the pNFS roles of the server, as reported by EXCHANGE_ID.
+func (x *Muxv4) pnfsFlags() uint32 {
+	var flags uint32
+
+	if x.Layout != nil {
+		flags |= msg.EXCHGID4_FLAG_USE_PNFS_MDS
+	}
+
+	if x.DataServer {
+		flags |= msg.EXCHGID4_FLAG_USE_PNFS_DS
+	}
+
+	if flags == 0 {
+		return msg.EXCHGID4_FLAG_USE_NON_PNFS
+	}
+
+	return flags
+}
+
+// isAnonymousStateID returns whether the stateid is the anonymous or the READ bypass stateid.
+func isAnonymousStateID(stateID msg.StateId4) bool {
+	return stateID.SeqId == 0 && stateID.Other == [3]uint32{} ||
+		stateID.SeqId == 0xffffffff && stateID.Other == [3]uint32{0xffffffff, 0xffffffff, 0xffffffff}
+}
+
+// isCurrentStateID returns whether the stateid is the special stateid that refers to the current stateid.
+func isCurrentStateID(stateID msg.StateId4) bool {
+	return stateID.SeqId == 1 && stateID.Other == [3]uint32{}
+}
+
+// openAnonymous opens a file for I/O with the anonymous stateid, which clients use on data servers
+// with the flexible files layout. The file is closed at the end of the compound.
+func (x *Compound) openAnonymous(fs *worker.Worker, fh *FileHandle) (*worker.File, uint32) {
+	if fh.IsNamedAttr() || fh.Foreign {
+		return nil, msg.NFS4ERR_BAD_STATEID
+	}
+
+	var f vfs.WriterAtReaderAt
+
+	file, err := fs.OpenFile(fh.Path, os.O_RDWR, 0)
+	if err == nil {
+		f = file
+	}
+
+	// Users that cannot write the file can still read it
+	if errors.Is(err, os.ErrPermission) {
+		var r vfs.ReaderAt
+
+		if r, err = fs.FileRead(fh.Path); err == nil {
+			f = NopWriterAt(r)
+		}
+	}
+
+	if err != nil {
+		DiscardOnServerFault(fs, err)
+
+		return nil, msg.Err2Status(err)
+	}
+
+	x.opened = append(x.opened, f)
+
+	return &worker.File{
+		File:   f,
+		Handle: fh.Handle,
+	}, msg.NFS4_OK
+}
+
+// closeOpened closes the files that were opened for the duration of the compound.
+func (x *Compound) closeOpened() {
+	for _, f := range x.opened {
+		if err := f.Close(); err != nil {
+			x.Logger.Warnf("failed to close file: %v", err)
+		}
+	}
+}
+
+// flexLayout returns the flexible files layout of a file, which is striped over all data servers.
+func (x *Compound) flexLayout(handle []byte) msg.FFLayout4 {
+	var (
+		fh      = x.Signer.Wrap(handle)
+		servers = make([]msg.FFDataServer4, 0, len(x.Layout.DataServers))
+	)
+
+	for _, addr := range x.Layout.DataServers {
+		servers = append(servers, msg.FFDataServer4{
+			DeviceID: deviceID(addr),
+			FhVers:   [][]byte{fh},
+			User:     x.IDMapper.Owner(x.Creds.UID),
+			Group:    x.IDMapper.Group(x.Creds.GID),
+		})
+	}
+
+	return msg.FFLayout4{
+		StripeUnit: uint64(x.Layout.stripeUnit()),
+		Mirrors: []msg.FFMirror4{
+			{DataServers: servers},
+		},
+	}
+}
+
+func (x *Compound) LayoutGet(in, out Bytes) (uint32, error) { //nolint:funlen
+	var args msg.LAYOUTGET4args
+
+	if err := xdr.NewDecoder(in).Decode(&args); err != nil {
+		return 0, err
+	}
+
+	x.Logger.Tracef("LAYOUTGET %d %d %d", args.IoMode, args.Offset, args.Length)
+
+	switch {
+	case x.CurrentHandle == nil:
+		return OperationResponse(out, msg.OP4_LAYOUTGET, msg.NFS4ERR_NOFILEHANDLE)
+	case x.Layout == nil || x.Layouts == nil || x.MinorVer == 0:
+		return OperationResponse(out, msg.OP4_LAYOUTGET, msg.NFS4ERR_LAYOUTUNAVAILABLE)
+	case args.LayoutType != msg.LAYOUT4_FLEX_FILES:
+		return OperationResponse(out, msg.OP4_LAYOUTGET, msg.NFS4ERR_UNKNOWN_LAYOUTTYPE)
+	case args.IoMode != msg.LAYOUTIOMODE4_READ && args.IoMode != msg.LAYOUTIOMODE4_RW:
+		return OperationResponse(out, msg.OP4_LAYOUTGET, msg.NFS4ERR_BADIOMODE)
+	}
+
+	fs := x.FS(x.Creds, x.SessionID)
+
+	defer fs.Close()
+
+	fi, err := fs.Lstat(x.CurrentHandle.Path)
+	if err != nil {
+		DiscardOnServerFault(fs, err)
+
+		return OperationResponse(out, msg.OP4_LAYOUTGET, msg.Err2Status(err))
+	}
+
+	if !fi.Mode().IsRegular() {
+		return OperationResponse(out, msg.OP4_LAYOUTGET, msg.NFS4ERR_LAYOUTUNAVAILABLE)
+	}
+
+	if isAnonymousStateID(args.StateId) {
+		return OperationResponse(out, msg.OP4_LAYOUTGET, msg.NFS4ERR_BAD_STATEID)
+	}
+
+	// Clients that send LAYOUTGET in the same compound as OPEN use the current stateid
+	if isCurrentStateID(args.StateId) {
+		if x.CurrentStateID == nil {
+			return OperationResponse(out, msg.OP4_LAYOUTGET, msg.NFS4ERR_BAD_STATEID)
+		}
+
+		args.StateId = *x.CurrentStateID
+	}
+
+	clientID := clients.ClientIDFromSessionID(x.SessionID)
+
+	// The stateid is an open stateid or, for later layouts, the layout stateid
+	canWrite, ok := x.Layouts.Writable(clientID, x.CurrentHandle.Handle, args.StateId)
+	if !ok {
+		f, status := x.openFile(fs, args.StateId)
+		if status != msg.NFS4_OK {
+			return OperationResponse(out, msg.OP4_LAYOUTGET, status)
+		}
+
+		canWrite = writable(f)
+	}
+
+	if args.IoMode == msg.LAYOUTIOMODE4_RW && !canWrite {
+		return OperationResponse(out, msg.OP4_LAYOUTGET, msg.NFS4ERR_OPENMODE)
+	}
+
+	body, err := xdr.Marshal(x.flexLayout(x.CurrentHandle.Handle))
+	if err != nil {
+		return 0, err
+	}
+
+	// Return on close, so that layouts do not outlive the open file
+	res := msg.LAYOUTGET4resok{
+		ReturnOnClose: true,
+		Layout: []msg.Layout4{
+			{
+				Offset: 0,
+				Length: msg.NFS4_MAXFILELEN,
+				IoMode: args.IoMode,
+				Content: msg.LayoutContent4{
+					Type: msg.LAYOUT4_FLEX_FILES,
+					Body: body,
+				},
+			},
+		},
+	}
+
+	if SizeOf(xdr.Marshal(res)) > args.MaxCount {
+		return OperationResponse(out, msg.OP4_LAYOUTGET, msg.NFS4ERR_TOOSMALL)
+	}
+
+	res.StateId = x.Layouts.Get(clientID, x.CurrentHandle.Handle, args.IoMode, canWrite, func(clientID uint64) bool {
+		_, ok := x.Clients.Get(clientID)
+
+		return ok
+	})
+
+	return OperationResponse(out, msg.OP4_LAYOUTGET, msg.NFS4_OK, res)
+}
+
+// closeLayout forgets the layout of a closed file, unless the client still has the file
+// open through another open file. In that case, the layout only remains writable
+// if one of the remaining open files is.
+func (x *Compound) closeLayout(fs *worker.Worker, f *worker.File) {
+	clientID := clients.ClientIDFromSessionID(x.SessionID)
+
+	remaining := fs.GetFilesByHandle(f.Client, f.Handle)
+	if len(remaining) == 0 {
+		x.Layouts.ReturnFile(clientID, f.Handle)
+
+		return
+	}
+
+	x.Layouts.SetWritable(clientID, f.Handle, slices.ContainsFunc(remaining, writable))
+}
+
+func (x *Compound) LayoutReturn(in, out Bytes) (uint32, error) {
+	var args msg.LAYOUTRETURN4args
+
+	if err := xdr.NewDecoder(in).Decode(&args); err != nil {
+		return 0, err
+	}
+
+	x.Logger.Tracef("LAYOUTRETURN %d %d", args.Return.ReturnType, args.IoMode)
+
+	if args.LayoutType != msg.LAYOUT4_FLEX_FILES {
+		return OperationResponse(out, msg.OP4_LAYOUTRETURN, msg.NFS4ERR_UNKNOWN_LAYOUTTYPE)
+	}
+
+	clientID := clients.ClientIDFromSessionID(x.SessionID)
+
+	switch args.Return.ReturnType {
+	case msg.LAYOUTRETURN4_FILE:
+		if x.CurrentHandle == nil {
+			return OperationResponse(out, msg.OP4_LAYOUTRETURN, msg.NFS4ERR_NOFILEHANDLE)
+		}
+
+		ret := args.Return.File
+
+		var body msg.FFLayoutReturn4
+
+		if len(ret.Body) > 0 {
+			if err := xdr.NewDecoder(bytes.NewReader(ret.Body)).Decode(&body); err != nil {
+				x.Logger.Debugf("failed to decode layoutreturn: %v", err)
+			}
+		}
+
+		for _, ioErr := range body.IoErrs {
+			x.logDeviceErrors(ioErr.Errors)
+		}
+
+		whole := ret.Offset == 0 && ret.Length == msg.NFS4_MAXFILELEN
+
+		stateID, status := x.Layouts.Return(clientID, x.CurrentHandle.Handle, ret.StateId, args.IoMode, whole)
+		if status != msg.NFS4_OK {
+			return OperationResponse(out, msg.OP4_LAYOUTRETURN, status)
+		}
+
+		return OperationResponse(out, msg.OP4_LAYOUTRETURN, msg.NFS4_OK, msg.LAYOUTRETURN4resok{
+			StateId: stateID,
+		})
+	case msg.LAYOUTRETURN4_FSID, msg.LAYOUTRETURN4_ALL:
+		x.Layouts.ReturnAll(clientID)
+
+		return OperationResponse(out, msg.OP4_LAYOUTRETURN, msg.NFS4_OK, msg.LAYOUTRETURN4resok{})
+	default:
+		return OperationResponse(out, msg.OP4_LAYOUTRETURN, msg.NFS4ERR_INVAL)
+	}
+}
+
+// LayoutCommit makes the writes to the data servers visible in the attributes of the file.
+// The data servers write to the same file system, so that only the change attribute and,
+// if the client reports a later write, the size need to be updated.
+func (x *Compound) LayoutCommit(in, out Bytes) (uint32, error) {
+	var args msg.LAYOUTCOMMIT4args
+
+	if err := xdr.NewDecoder(in).Decode(&args); err != nil {
+		return 0, err
+	}
+
+	x.Logger.Tracef("LAYOUTCOMMIT %d %d", args.Offset, args.Length)
+
+	if x.CurrentHandle == nil {
+		return OperationResponse(out, msg.OP4_LAYOUTCOMMIT, msg.NFS4ERR_NOFILEHANDLE)
+	}
+
+	if !x.Layouts.Valid(clients.ClientIDFromSessionID(x.SessionID), x.CurrentHandle.Handle, args.StateId) {
+		return OperationResponse(out, msg.OP4_LAYOUTCOMMIT, msg.NFS4ERR_BADLAYOUT)
+	}
+
+	fs := x.FS(x.Creds, x.SessionID)
+
+	defer fs.Close()
+
+	fs.Cache.Invalidate(x.CurrentHandle.Handle)
+
+	fi, err := fs.Lstat(x.CurrentHandle.Path)
+	if err == nil && args.LastWriteOffset != nil && *args.LastWriteOffset >= uint64(fi.Size()) {
+		err = fs.Truncate(x.CurrentHandle.Path, int64(*args.LastWriteOffset)+1)
+	}
+
+	if err == nil {
+		err = touch(fs, x.CurrentHandle.Path)
+	}
+
+	if err == nil {
+		fi, err = fs.Lstat(x.CurrentHandle.Path)
+	}
+
+	if err != nil {
+		DiscardOnServerFault(fs, err)
+
+		return OperationResponse(out, msg.OP4_LAYOUTCOMMIT, msg.Err2Status(err))
+	}
+
+	size := uint64(fi.Size())
+
+	return OperationResponse(out, msg.OP4_LAYOUTCOMMIT, msg.NFS4_OK, msg.LAYOUTCOMMIT4resok{
+		NewSize: &size,
+	})
+}
+
+func (x *Compound) GetDeviceInfo(in, out Bytes) (uint32, error) {
+	var args msg.GETDEVICEINFO4args
+
+	if err := xdr.NewDecoder(in).Decode(&args); err != nil {
+		return 0, err
+	}
+
+	x.Logger.Tracef("GETDEVICEINFO %x", args.DeviceID)
+
+	if args.LayoutType != msg.LAYOUT4_FLEX_FILES {
+		return OperationResponse(out, msg.OP4_GETDEVICEINFO, msg.NFS4ERR_UNKNOWN_LAYOUTTYPE)
+	}
+
+	addr, ok := x.Layout.dataServer(args.DeviceID)
+	if !ok {
+		return OperationResponse(out, msg.OP4_GETDEVICEINFO, msg.NFS4ERR_NOENT)
+	}
+
+	dev, err := deviceAddr(addr, x.MinorVer)
+	if err != nil {
+		x.Logger.Warnf("failed to resolve data server %s: %v", addr, err)
+
+		return OperationResponse(out, msg.OP4_GETDEVICEINFO, msg.NFS4ERR_NOENT)
+	}
+
+	body, err := xdr.Marshal(dev)
+	if err != nil {
+		return 0, err
+	}
+
+	res := msg.GETDEVICEINFO4resok{
+		DeviceAddr: msg.DeviceAddr4{
+			LayoutType: msg.LAYOUT4_FLEX_FILES,
+			Body:       body,
+		},
+		Notification: []uint32{},
+	}
+
+	if size := SizeOf(xdr.Marshal(res.DeviceAddr)); size > args.MaxCount {
+		return OperationResponse(out, msg.OP4_GETDEVICEINFO, msg.NFS4ERR_TOOSMALL, size)
+	}
+
+	return OperationResponse(out, msg.OP4_GETDEVICEINFO, msg.NFS4_OK, res)
+}
+
+func (x *Compound) GetDeviceList(in, out Bytes) (uint32, error) {
+	var args msg.GETDEVICELIST4args
+
+	if err := xdr.NewDecoder(in).Decode(&args); err != nil {
+		return 0, err
+	}
+
+	x.Logger.Tracef("GETDEVICELIST %d %d", args.Cookie, args.MaxDevices)
+
+	if args.LayoutType != msg.LAYOUT4_FLEX_FILES {
+		return OperationResponse(out, msg.OP4_GETDEVICELIST, msg.NFS4ERR_UNKNOWN_LAYOUTTYPE)
+	}
+
+	ids := [][16]byte{}
+
+	if x.Layout != nil {
+		for _, addr := range x.Layout.DataServers {
+			ids = append(ids, deviceID(addr))
+		}
+	}
+
+	if args.Cookie > uint64(len(ids)) {
+		return OperationResponse(out, msg.OP4_GETDEVICELIST, msg.NFS4ERR_BAD_COOKIE)
+	}
+
+	end := min(args.Cookie+uint64(args.MaxDevices), uint64(len(ids)))
+
+	if end == args.Cookie && end < uint64(len(ids)) {
+		return OperationResponse(out, msg.OP4_GETDEVICELIST, msg.NFS4ERR_TOOSMALL)
+	}
+
+	return OperationResponse(out, msg.OP4_GETDEVICELIST, msg.NFS4_OK, msg.GETDEVICELIST4resok{
+		Cookie:     end,
+		CookieVerf: args.CookieVerf,
+		DeviceIDs:  ids[args.Cookie:end],
+		Eof:        end == uint64(len(ids)),
+	})
+}
+
+// LayoutError logs the errors that clients encountered on data servers.
+func (x *Compound) LayoutError(in, out Bytes) (uint32, error) {
+	var args msg.LAYOUTERROR4args
+
+	if err := xdr.NewDecoder(in).Decode(&args); err != nil {
+		return 0, err
+	}
+
+	x.Logger.Tracef("LAYOUTERROR %d %d", args.Offset, args.Length)
+
+	x.logDeviceErrors(args.Errors)
+
+	return OperationResponse(out, msg.OP4_LAYOUTERROR, msg.NFS4_OK)
+}
+
+// LayoutStats logs the I/O statistics that clients report for data servers.
+func (x *Compound) LayoutStats(in, out Bytes) (uint32, error) {
+	var args msg.LAYOUTSTATS4args
+
+	if err := xdr.NewDecoder(in).Decode(&args); err != nil {
+		return 0, err
+	}
+
+	x.Logger.Tracef("LAYOUTSTATS %d %d", args.Offset, args.Length)
+
+	addr, _ := x.Layout.dataServer(args.DeviceID)
+
+	x.Logger.Debugf("layout stats for data server %s: read %d bytes in %d operations, wrote %d bytes in %d operations",
+		addr, args.Read.Bytes, args.Read.Count, args.Write.Bytes, args.Write.Count)
+
+	return OperationResponse(out, msg.OP4_LAYOUTSTATS, msg.NFS4_OK)
+}
+
+func (x *Compound) logDeviceErrors(errs []msg.DeviceError4) {
+	for _, e := range errs {
+		addr, ok := x.Layout.dataServer(e.DeviceID)
+		if !ok {
+			addr = "unknown"
+		}
+
+		x.Logger.Warnf("client reports error %d for %s on data server %s", e.Status, msg.Proc4Name(e.OpNum), addr)
+	}
+}
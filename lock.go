@@ -0,0 +1,268 @@
+package nfs4go
+
+import (
+	"slices"
+
+	"github.com/kuleuven/nfs4go/clients"
+	"github.com/kuleuven/nfs4go/locks"
+	"github.com/kuleuven/nfs4go/msg"
+	"github.com/kuleuven/nfs4go/worker"
+	"github.com/kuleuven/nfs4go/xdr"
+)
+
+// Lock acquires a byte-range lock. The locks are kept in the lock manager of the server,
+// which is shared with NLM, so that NFSv3 and NFSv4 clients see each other's locks.
+// A lock stateid refers to the open file through which the lock owner locked the file,
+// so that it can also be used for READ and WRITE. Blocking locks are not queued:
+// clients poll for them.
+func (x *Compound) Lock(in, out Bytes) (uint32, error) {
+	var args msg.LOCK4args
+
+	if err := xdr.NewDecoder(in).Decode(&args); err != nil {
+		return 0, err
+	}
+
+	x.Logger.Tracef("LOCK %d %d %d", args.LockType, args.Offset, args.Length)
+
+	if x.CurrentHandle == nil {
+		return OperationResponse(out, msg.OP4_LOCK, msg.NFS4ERR_NOFILEHANDLE)
+	}
+
+	length, status := lockLength(args.Offset, args.Length)
+	if status != msg.NFS4_OK {
+		return OperationResponse(out, msg.OP4_LOCK, status)
+	}
+
+	fs := x.FS(x.Creds, x.SessionID)
+
+	defer fs.Close()
+
+	x.sweepLocks()
+
+	var (
+		f     *worker.File
+		owner locks.Owner
+		index uint32
+		other [3]uint32
+	)
+
+	if args.Locker.NewLockOwner != 0 {
+		open := args.Locker.OpenOwner
+
+		f, status = x.openFile(fs, open.OpenStateId)
+		if status != msg.NFS4_OK {
+			return OperationResponse(out, msg.OP4_LOCK, status)
+		}
+
+		owner = x.lockOwner(open.LockOwner)
+		index = fs.AddLockOwner(f, owner)
+		other = LockOther(FileID(open.OpenStateId.Other), index)
+	} else {
+		stateID := args.Locker.LockOwner.LockStateId
+
+		f, owner, status = x.lockFile(fs, stateID)
+		if status != msg.NFS4_OK {
+			return OperationResponse(out, msg.OP4_LOCK, status)
+		}
+
+		other = stateID.Other
+	}
+
+	conflict, ok := x.Locks.Lock(string(f.Handle), locks.Lock{
+		Owner:     owner,
+		Exclusive: args.LockType == msg.WRITE_LT || args.LockType == msg.WRITEW_LT,
+		Offset:    args.Offset,
+		Length:    length,
+	})
+	if !ok {
+		return OperationResponse(out, msg.OP4_LOCK, msg.NFS4ERR_DENIED, lockDenied(conflict))
+	}
+
+	return OperationResponse(out,
+		msg.OP4_LOCK,
+		msg.NFS4_OK,
+		msg.StateId4{
+			SeqId: 1,
+			Other: other,
+		},
+	)
+}
+
+// LockTest tests whether a lock could be acquired, without the need to open the file.
+func (x *Compound) LockTest(in, out Bytes) (uint32, error) {
+	var args msg.LOCKT4args
+
+	if err := xdr.NewDecoder(in).Decode(&args); err != nil {
+		return 0, err
+	}
+
+	x.Logger.Tracef("LOCKT %d %d %d", args.LockType, args.Offset, args.Length)
+
+	if x.CurrentHandle == nil {
+		return OperationResponse(out, msg.OP4_LOCKT, msg.NFS4ERR_NOFILEHANDLE)
+	}
+
+	length, status := lockLength(args.Offset, args.Length)
+	if status != msg.NFS4_OK {
+		return OperationResponse(out, msg.OP4_LOCKT, status)
+	}
+
+	x.sweepLocks()
+
+	conflict, ok := x.Locks.Test(string(x.CurrentHandle.Handle), locks.Lock{
+		Owner:     x.lockOwner(args.Owner),
+		Exclusive: args.LockType == msg.WRITE_LT || args.LockType == msg.WRITEW_LT,
+		Offset:    args.Offset,
+		Length:    length,
+	})
+	if ok {
+		return OperationResponse(out, msg.OP4_LOCKT, msg.NFS4ERR_DENIED, lockDenied(conflict))
+	}
+
+	return OperationResponse(out, msg.OP4_LOCKT, msg.NFS4_OK)
+}
+
+// Unlock releases a range of the locks of a lock owner.
+func (x *Compound) Unlock(in, out Bytes) (uint32, error) {
+	var args msg.LOCKU4args
+
+	if err := xdr.NewDecoder(in).Decode(&args); err != nil {
+		return 0, err
+	}
+
+	x.Logger.Tracef("LOCKU %d %d", args.Offset, args.Length)
+
+	if x.CurrentHandle == nil {
+		return OperationResponse(out, msg.OP4_LOCKU, msg.NFS4ERR_NOFILEHANDLE)
+	}
+
+	length, status := lockLength(args.Offset, args.Length)
+	if status != msg.NFS4_OK {
+		return OperationResponse(out, msg.OP4_LOCKU, status)
+	}
+
+	fs := x.FS(x.Creds, x.SessionID)
+
+	defer fs.Close()
+
+	f, owner, status := x.lockFile(fs, args.LockStateId)
+	if status != msg.NFS4_OK {
+		return OperationResponse(out, msg.OP4_LOCKU, status)
+	}
+
+	x.Locks.Unlock(string(f.Handle), owner, args.Offset, length)
+
+	return OperationResponse(out, msg.OP4_LOCKU, msg.NFS4_OK, args.LockStateId)
+}
+
+// ReleaseLockOwner forgets a lock owner of NFSv4.0, which must not hold locks anymore.
+func (x *Compound) ReleaseLockOwner(in, out Bytes) (uint32, error) {
+	var args msg.LockOwner4
+
+	if err := xdr.NewDecoder(in).Decode(&args); err != nil {
+		return 0, err
+	}
+
+	if x.MinorVer > 0 {
+		return OperationResponse(out, msg.OP4_RELEASE_LOCKOWNER, msg.NFS4ERR_NOTSUPP)
+	}
+
+	x.Logger.Tracef("RELEASE_LOCKOWNER %d", args.ClientId)
+
+	owner := x.lockOwner(args)
+
+	if x.Locks.Holds(func(o locks.Owner) bool { return o == owner }) {
+		return OperationResponse(out, msg.OP4_RELEASE_LOCKOWNER, msg.NFS4ERR_LOCKS_HELD)
+	}
+
+	return OperationResponse(out, msg.OP4_RELEASE_LOCKOWNER, msg.NFS4_OK)
+}
+
+// lockOwner returns the owner of NFSv4 locks in the lock manager.
+// Clients of v4.1 and higher are identified by their session.
+func (x *Compound) lockOwner(owner msg.LockOwner4) locks.Owner {
+	if x.MinorVer > 0 {
+		owner.ClientId = clients.ClientIDFromSessionID(x.SessionID)
+	}
+
+	return locks.Owner{
+		ClientID: owner.ClientId,
+		ID:       string(owner.Owner),
+	}
+}
+
+// lockFile returns the open file and the lock owner that are referred to by a lock stateid.
+func (x *Compound) lockFile(fs *worker.Worker, stateID msg.StateId4) (*worker.File, locks.Owner, uint32) {
+	index, ok := LockOwnerIndex(stateID.Other)
+	if !ok {
+		return nil, locks.Owner{}, msg.NFS4ERR_BAD_STATEID
+	}
+
+	f, status := x.openFile(fs, stateID)
+	if status != msg.NFS4_OK {
+		return nil, locks.Owner{}, status
+	}
+
+	owner, ok := fs.GetLockOwner(f, index)
+	if !ok {
+		return nil, locks.Owner{}, msg.NFS4ERR_BAD_STATEID
+	}
+
+	return f, owner, msg.NFS4_OK
+}
+
+// releaseLocks releases the locks that were acquired through an open file when it is closed.
+func (x *Compound) releaseLocks(f *worker.File) {
+	if len(f.LockOwners) == 0 {
+		return
+	}
+
+	x.Locks.ReleaseFile(string(f.Handle), func(o locks.Owner) bool {
+		return slices.Contains(f.LockOwners, o)
+	})
+}
+
+// sweepLocks releases the locks of clients that expired.
+func (x *Compound) sweepLocks() {
+	x.Locks.Sweep(func(clientID uint64) bool {
+		_, ok := x.Clients.Get(clientID)
+
+		return ok
+	}, clients.ClientExpiration)
+}
+
+// lockLength returns the length of a lock in the lock manager,
+// where locks until the end of the file have length zero.
+func lockLength(offset, length uint64) (uint64, uint32) {
+	switch {
+	case length == msg.NFS4_UINT64_MAX:
+		return 0, msg.NFS4_OK
+	case length == 0, offset+length < offset:
+		return 0, msg.NFS4ERR_INVAL
+	default:
+		return length, msg.NFS4_OK
+	}
+}
+
+// lockDenied describes a conflicting lock to the client.
+func lockDenied(conflict locks.Lock) msg.LOCK4denied {
+	denied := msg.LOCK4denied{
+		Offset:   conflict.Offset,
+		Length:   conflict.Length,
+		LockType: msg.READ_LT,
+		Owner: msg.LockOwner4{
+			ClientId: conflict.Owner.ClientID,
+			Owner:    []byte(conflict.Owner.ID),
+		},
+	}
+
+	if conflict.Length == 0 {
+		denied.Length = msg.NFS4_UINT64_MAX
+	}
+
+	if conflict.Exclusive {
+		denied.LockType = msg.WRITE_LT
+	}
+
+	return denied
+}
@@ -0,0 +1,417 @@
+// Package locks keeps the byte-range locks and share reservations of files.
+// The same Manager is used for the locks of NFSv4 clients and of NFSv3 clients
+// that use the Network Lock Manager, so that they see each other's locks.
+package locks
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/kuleuven/nfs4go/clock"
+)
+
+// Owner identifies the owner of a lock or share reservation.
+type Owner struct {
+	Host     string // Address of an NLM client, empty for NFSv4 clients
+	ClientID uint64 // Client id of an NFSv4 client
+	Svid     uint32 // Process id of the owner on an NLM client
+	ID       string // Opaque owner: the owner handle of NLM or the lock owner of NFSv4
+}
+
+// Lock is a POSIX byte-range lock.
+type Lock struct {
+	Owner     Owner
+	Exclusive bool
+	Offset    uint64
+	Length    uint64 // Zero to lock until the end of the file
+}
+
+// End returns the offset after the last byte of the lock.
+func (l Lock) End() uint64 {
+	if l.Length == 0 || l.Offset+l.Length < l.Offset {
+		return math.MaxUint64
+	}
+
+	return l.Offset + l.Length
+}
+
+func (l Lock) overlaps(o Lock) bool {
+	return l.Offset < o.End() && o.Offset < l.End()
+}
+
+func (l Lock) conflicts(o Lock) bool {
+	return l.Owner != o.Owner && (l.Exclusive || o.Exclusive) && l.overlaps(o)
+}
+
+// withEnd returns the lock with its length set to end at the given offset.
+func (l Lock) withEnd(end uint64) Lock {
+	if end == math.MaxUint64 {
+		l.Length = 0
+	} else {
+		l.Length = end - l.Offset
+	}
+
+	return l
+}
+
+const (
+	ShareRead  = uint32(1)
+	ShareWrite = uint32(2)
+)
+
+// Share is a share reservation, which denies access modes to other owners.
+type Share struct {
+	Owner  Owner
+	Access uint32 // ShareRead | ShareWrite
+	Deny   uint32 // ShareRead | ShareWrite
+}
+
+func (s Share) conflicts(o Share) bool {
+	return s.Owner != o.Owner && (s.Access&o.Deny != 0 || s.Deny&o.Access != 0)
+}
+
+// Manager keeps the locks, the queued lock requests and the share reservations of files,
+// which are identified by their file handle.
+type Manager struct {
+	files     map[string][]Lock
+	waiters   map[string][]*waiter
+	shares    map[string][]Share
+	lastSweep time.Time
+	mutex     sync.Mutex
+}
+
+type waiter struct {
+	lock    Lock
+	granted func(Lock)
+}
+
+func New() *Manager {
+	return &Manager{
+		files:   map[string][]Lock{},
+		waiters: map[string][]*waiter{},
+		shares:  map[string][]Share{},
+	}
+}
+
+// Test returns a lock of another owner that conflicts with l, if any.
+func (m *Manager) Test(file string, l Lock) (Lock, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.conflict(file, l)
+}
+
+// Lock acquires l, or returns the conflicting lock if it cannot be acquired.
+// Existing locks of the same owner in the range of l are replaced, so that
+// locks can be upgraded and downgraded.
+func (m *Manager) Lock(file string, l Lock) (Lock, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if conflict, ok := m.conflict(file, l); ok {
+		return conflict, false
+	}
+
+	m.acquire(file, l)
+
+	return Lock{}, true
+}
+
+// Wait acquires l as Lock, or queues it until the conflicting locks are released.
+// Queued locks are acquired in order, after which granted is called in a separate goroutine.
+// A queued lock of the same owner with the same range is replaced.
+func (m *Manager) Wait(file string, l Lock, granted func(Lock)) (Lock, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	conflict, ok := m.conflict(file, l)
+	if !ok {
+		m.acquire(file, l)
+
+		return Lock{}, true
+	}
+
+	m.cancel(file, l)
+
+	m.waiters[file] = append(m.waiters[file], &waiter{
+		lock:    l,
+		granted: granted,
+	})
+
+	return conflict, false
+}
+
+// Cancel removes a queued lock of the same owner with the same range as l,
+// and returns whether it was found.
+func (m *Manager) Cancel(file string, l Lock) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.cancel(file, l)
+}
+
+// Unlock releases the range of the locks of owner, which can be part of a lock.
+func (m *Manager) Unlock(file string, owner Owner, offset, length uint64) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	unlock := Lock{Owner: owner, Offset: offset, Length: length}
+
+	m.set(file, without(m.files[file], unlock))
+	m.wake(file)
+}
+
+// Holds returns whether an owner for which match returns true holds a lock.
+func (m *Manager) Holds(match func(Owner) bool) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, locks := range m.files {
+		for _, l := range locks {
+			if match(l.Owner) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// ReleaseFile releases the locks, queued locks and share reservations of a file
+// of the owners for which match returns true.
+func (m *Manager) ReleaseFile(file string, match func(Owner) bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.release(file, match)
+}
+
+// Release releases all locks, queued locks and share reservations of the owners
+// for which match returns true, e.g. after a client rebooted.
+func (m *Manager) Release(match func(Owner) bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	files := map[string]struct{}{}
+
+	for file := range m.files {
+		files[file] = struct{}{}
+	}
+
+	for file := range m.waiters {
+		files[file] = struct{}{}
+	}
+
+	for file := range m.shares {
+		files[file] = struct{}{}
+	}
+
+	for file := range files {
+		m.release(file, match)
+	}
+}
+
+// Sweep releases the locks of NFSv4 clients that no longer exist.
+// The locks are checked at most once per interval.
+func (m *Manager) Sweep(exists func(clientID uint64) bool, interval time.Duration) {
+	m.mutex.Lock()
+
+	if clock.Now().Sub(m.lastSweep) < interval {
+		m.mutex.Unlock()
+
+		return
+	}
+
+	m.lastSweep = clock.Now()
+
+	m.mutex.Unlock()
+
+	m.Release(func(o Owner) bool {
+		return o.Host == "" && !exists(o.ClientID)
+	})
+}
+
+// Share adds a share reservation, or returns false if it conflicts with the reservation
+// of another owner. A reservation of the same owner is replaced.
+func (m *Manager) Share(file string, s Share) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var shares []Share
+
+	for _, o := range m.shares[file] {
+		if s.conflicts(o) {
+			return false
+		}
+
+		if o.Owner != s.Owner {
+			shares = append(shares, o)
+		}
+	}
+
+	m.shares[file] = append(shares, s)
+
+	return true
+}
+
+// Unshare removes the share reservation of owner.
+func (m *Manager) Unshare(file string, owner Owner) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.unshare(file, func(o Owner) bool {
+		return o == owner
+	})
+}
+
+func (m *Manager) conflict(file string, l Lock) (Lock, bool) {
+	for _, o := range m.files[file] {
+		if l.conflicts(o) {
+			return o, true
+		}
+	}
+
+	return Lock{}, false
+}
+
+// acquire adds l, which must not conflict, and merges it with adjacent locks of the same owner and type.
+func (m *Manager) acquire(file string, l Lock) {
+	locks := without(m.files[file], l)
+
+	merged := locks[:0]
+
+	for _, o := range locks {
+		switch {
+		case o.Owner != l.Owner || o.Exclusive != l.Exclusive:
+		case o.End() == l.Offset:
+			l = Lock{Owner: l.Owner, Exclusive: l.Exclusive, Offset: o.Offset}.withEnd(l.End())
+
+			continue
+		case o.Offset == l.End():
+			l = l.withEnd(o.End())
+
+			continue
+		}
+
+		merged = append(merged, o)
+	}
+
+	m.set(file, append(merged, l))
+}
+
+func (m *Manager) set(file string, locks []Lock) {
+	if len(locks) == 0 {
+		delete(m.files, file)
+	} else {
+		m.files[file] = locks
+	}
+}
+
+// without returns the locks without the range of l for the owner of l.
+// Locks that extend beyond the range are split.
+func without(locks []Lock, l Lock) []Lock {
+	var result []Lock
+
+	for _, o := range locks {
+		if o.Owner != l.Owner || !o.overlaps(l) {
+			result = append(result, o)
+
+			continue
+		}
+
+		if o.Offset < l.Offset {
+			result = append(result, o.withEnd(l.Offset))
+		}
+
+		if o.End() > l.End() {
+			right := o
+			right.Offset = l.End()
+
+			result = append(result, right.withEnd(o.End()))
+		}
+	}
+
+	return result
+}
+
+func (m *Manager) cancel(file string, l Lock) bool {
+	waiters := m.waiters[file]
+
+	for i, w := range waiters {
+		if w.lock.Owner == l.Owner && w.lock.Offset == l.Offset && w.lock.End() == l.End() {
+			m.setWaiters(file, append(waiters[:i:i], waiters[i+1:]...))
+
+			return true
+		}
+	}
+
+	return false
+}
+
+func (m *Manager) setWaiters(file string, waiters []*waiter) {
+	if len(waiters) == 0 {
+		delete(m.waiters, file)
+	} else {
+		m.waiters[file] = waiters
+	}
+}
+
+// wake acquires the queued locks of a file that no longer conflict.
+func (m *Manager) wake(file string) {
+	var waiters []*waiter
+
+	for _, w := range m.waiters[file] {
+		if _, ok := m.conflict(file, w.lock); ok {
+			waiters = append(waiters, w)
+
+			continue
+		}
+
+		m.acquire(file, w.lock)
+
+		go w.granted(w.lock)
+	}
+
+	m.setWaiters(file, waiters)
+}
+
+func (m *Manager) release(file string, match func(Owner) bool) {
+	var (
+		locks   []Lock
+		waiters []*waiter
+	)
+
+	for _, l := range m.files[file] {
+		if !match(l.Owner) {
+			locks = append(locks, l)
+		}
+	}
+
+	for _, w := range m.waiters[file] {
+		if !match(w.lock.Owner) {
+			waiters = append(waiters, w)
+		}
+	}
+
+	m.set(file, locks)
+	m.setWaiters(file, waiters)
+	m.unshare(file, match)
+	m.wake(file)
+}
+
+func (m *Manager) unshare(file string, match func(Owner) bool) {
+	var shares []Share
+
+	for _, s := range m.shares[file] {
+		if !match(s.Owner) {
+			shares = append(shares, s)
+		}
+	}
+
+	if len(shares) == 0 {
+		delete(m.shares, file)
+	} else {
+		m.shares[file] = shares
+	}
+}
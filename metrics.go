@@ -0,0 +1,10 @@
+package nfs4go
+
+import "sync/atomic"
+
+// Metrics contains counters about the operation of a Server.
+// All fields can be read concurrently while the server is running.
+type Metrics struct {
+	RejectedConnections atomic.Uint64 // Connections refused because of the export policy
+	RejectedCalls       atomic.Uint64 // RPC calls answered with AUTH_TOOWEAK on refused connections
+}
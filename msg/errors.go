@@ -79,14 +79,25 @@ const (
 	NFS4ERR_FILE_OPEN           = uint32(10046) /* open file blocks op.     */
 	NFS4ERR_ADMIN_REVOKED       = uint32(10047) /* lock-owner state revoked */
 	NFS4ERR_CB_PATH_DOWN        = uint32(10048) /* callback path down       */
+	NFS4ERR_BADIOMODE           = uint32(10049) /* invalid layout iomode    */
+	NFS4ERR_BADLAYOUT           = uint32(10050) /* invalid layout           */
+	NFS4ERR_LAYOUTUNAVAILABLE   = uint32(10059) /* layout not available     */
+	NFS4ERR_NOMATCHING_LAYOUT   = uint32(10060) /* no matching layout       */
+	NFS4ERR_UNKNOWN_LAYOUTTYPE  = uint32(10062) /* unknown layout type      */
 	NFS4ERR_NOXATTR             = uint32(10095) /* no extended attributes   */
 	NFS4ERR_XATTR2BIG           = uint32(10096) /* extended attributes too big */
 	NFS4ERR_NOT_ONLY_OP         = uint32(10081) /* not only operation       */
+	NFS4ERR_WRONG_TYPE          = uint32(10083) /* op on wrong type object  */
 	NFS4ERR_DEADSESSION         = uint32(10078) /* dead session             */
 	NFS4ERR_SEQ_MISORDERED      = uint32(10063) /* sequence misordered      */
 	NFS4ERR_OP_NOT_IN_SESSION   = uint32(10071) /* operation not in session */
 	NFS4ERR_RETRY_UNCACHED_REP  = uint32(10068) /* retry uncached rep       */
 	NFS4ERR_CLIENTID_BUSY       = uint32(10074) /* clientid in use          */
+	NFS4ERR_PARTNER_NOTSUPP     = uint32(10088) /* s2s not supported        */
+	NFS4ERR_PARTNER_NO_AUTH     = uint32(10089) /* s2s not authorized       */
+	NFS4ERR_UNION_NOTSUPP       = uint32(10090) /* [discriminant] not supp  */
+	NFS4ERR_OFFLOAD_DENIED      = uint32(10091) /* dest not allowing copy   */
+	NFS4ERR_OFFLOAD_NO_REQS     = uint32(10094) /* cannot meet requirements */
 )
 
 type Error uint32
@@ -110,7 +121,7 @@ func Err2Status(err error) uint32 {
 		return NFS4ERR_EXIST
 	case errors.Is(err, os.ErrPermission):
 		return NFS4ERR_PERM
-	case errors.Is(err, os.ErrInvalid):
+	case errors.Is(err, os.ErrInvalid), errors.Is(err, syscall.EINVAL):
 		return NFS4ERR_INVAL
 	case errors.Is(err, vfs.ErrInvalidHandle):
 		return NFS4ERR_BADHANDLE
@@ -120,6 +131,14 @@ func Err2Status(err error) uint32 {
 		return NFS4ERR_NOTDIR
 	case errors.Is(err, syscall.ENOTSUP), errors.Is(err, syscall.EOPNOTSUPP):
 		return NFS4ERR_NOTSUPP
+	case errors.Is(err, syscall.ENOSPC):
+		return NFS4ERR_NOSPC
+	case errors.Is(err, syscall.EDQUOT):
+		return NFS4ERR_DQUOT
+	case errors.Is(err, syscall.EFBIG):
+		return NFS4ERR_FBIG
+	case errors.Is(err, syscall.EXDEV):
+		return NFS4ERR_XDEV
 	case errors.Is(err, io.EOF):
 		return NFS4ERR_IO
 	default:
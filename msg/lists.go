@@ -0,0 +1,213 @@
+package msg
+
+import "github.com/kuleuven/nfs4go/xdr"
+
+// The list types in this file have specialized Decode and Encode
+// functions to avoid the use of the reflect package.
+
+type decodable[T any] interface {
+	*T
+	Decode(decoder *xdr.Decoder) error
+}
+
+type encodable interface {
+	Encode(encoder *xdr.Encoder) error
+}
+
+func decodeList[T any, P decodable[T]](decoder *xdr.Decoder) ([]T, error) {
+	n, err := decoder.Uint32()
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]T, 0, min(n, 1024))
+
+	for range n {
+		var item T
+
+		if err := P(&item).Decode(decoder); err != nil {
+			return nil, err
+		}
+
+		list = append(list, item)
+	}
+
+	return list, nil
+}
+
+func decodeOptional[T any, P decodable[T]](decoder *xdr.Decoder) (*T, error) {
+	if ok, err := decoder.Bool(); err != nil || !ok {
+		return nil, err
+	}
+
+	item := new(T)
+
+	return item, P(item).Decode(decoder)
+}
+
+func encodeOptional[T encodable](encoder *xdr.Encoder, item *T) error {
+	if err := encoder.Bool(item != nil); err != nil || item == nil {
+		return err
+	}
+
+	return (*item).Encode(encoder)
+}
+
+func encodeList[T encodable](encoder *xdr.Encoder, list []T) error {
+	if err := encoder.Uint32(uint32(len(list))); err != nil {
+		return err
+	}
+
+	for _, item := range list {
+		if err := item.Encode(encoder); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ReadPlusContents is a list of ReadPlusContent4.
+type ReadPlusContents []ReadPlusContent4
+
+func (x *ReadPlusContents) Decode(decoder *xdr.Decoder) error {
+	list, err := decodeList[ReadPlusContent4](decoder)
+
+	*x = list
+
+	return err
+}
+
+func (x ReadPlusContents) Encode(encoder *xdr.Encoder) error {
+	return encodeList(encoder, x)
+}
+
+// Netlocs is a list of Netloc4.
+type Netlocs []Netloc4
+
+func (x *Netlocs) Decode(decoder *xdr.Decoder) error {
+	list, err := decodeList[Netloc4](decoder)
+
+	*x = list
+
+	return err
+}
+
+func (x Netlocs) Encode(encoder *xdr.Encoder) error {
+	return encodeList(encoder, x)
+}
+
+// ReferringCalls is a list of ReferringCall4.
+type ReferringCalls []ReferringCall4
+
+func (x *ReferringCalls) Decode(decoder *xdr.Decoder) error {
+	list, err := decodeList[ReferringCall4](decoder)
+
+	*x = list
+
+	return err
+}
+
+func (x ReferringCalls) Encode(encoder *xdr.Encoder) error {
+	return encodeList(encoder, x)
+}
+
+// ReferringCallLists is a list of ReferringCallList4.
+type ReferringCallLists []ReferringCallList4
+
+func (x *ReferringCallLists) Decode(decoder *xdr.Decoder) error {
+	list, err := decodeList[ReferringCallList4](decoder)
+
+	*x = list
+
+	return err
+}
+
+func (x ReferringCallLists) Encode(encoder *xdr.Encoder) error {
+	return encodeList(encoder, x)
+}
+
+// MountList3 is the linked list of mounts returned by MOUNTPROC3_DUMP.
+type MountList3 struct {
+	Entries *MountBody3
+}
+
+func (x *MountList3) Decode(decoder *xdr.Decoder) error {
+	entries, err := decodeOptional[MountBody3](decoder)
+
+	x.Entries = entries
+
+	return err
+}
+
+func (x MountList3) Encode(encoder *xdr.Encoder) error {
+	return encodeOptional(encoder, x.Entries)
+}
+
+// Groups3 is the linked list of groups that may mount an export.
+type Groups3 struct {
+	Entries *GroupNode3
+}
+
+func (x *Groups3) Decode(decoder *xdr.Decoder) error {
+	entries, err := decodeOptional[GroupNode3](decoder)
+
+	x.Entries = entries
+
+	return err
+}
+
+func (x Groups3) Encode(encoder *xdr.Encoder) error {
+	return encodeOptional(encoder, x.Entries)
+}
+
+// Exports3 is the linked list of exports returned by MOUNTPROC3_EXPORT.
+type Exports3 struct {
+	Entries *ExportNode3
+}
+
+func (x *Exports3) Decode(decoder *xdr.Decoder) error {
+	entries, err := decodeOptional[ExportNode3](decoder)
+
+	x.Entries = entries
+
+	return err
+}
+
+func (x Exports3) Encode(encoder *xdr.Encoder) error {
+	return encodeOptional(encoder, x.Entries)
+}
+
+// PmapList is the linked list of mappings returned by PMAPPROC_DUMP.
+type PmapList struct {
+	Entries *PmapNode
+}
+
+func (x *PmapList) Decode(decoder *xdr.Decoder) error {
+	entries, err := decodeOptional[PmapNode](decoder)
+
+	x.Entries = entries
+
+	return err
+}
+
+func (x PmapList) Encode(encoder *xdr.Encoder) error {
+	return encodeOptional(encoder, x.Entries)
+}
+
+// RpcbList is the linked list of registrations returned by RPCBPROC_DUMP.
+type RpcbList struct {
+	Entries *RpcbNode
+}
+
+func (x *RpcbList) Decode(decoder *xdr.Decoder) error {
+	entries, err := decodeOptional[RpcbNode](decoder)
+
+	x.Entries = entries
+
+	return err
+}
+
+func (x RpcbList) Encode(encoder *xdr.Encoder) error {
+	return encodeOptional(encoder, x.Entries)
+}
@@ -0,0 +1,92 @@
+//go:generate go run ../xdr/generate/generate.go -- mount.go
+//nolint:staticcheck
+package msg
+
+import "fmt"
+
+// Structures of the MOUNT protocol version 3, rfc1813 appendix I.
+// The optional heads of the linked lists are defined in lists.go.
+
+const (
+	MOUNT_PROGRAM = uint32(100005)
+	MOUNT_V3      = uint32(3)
+
+	MNTPATHLEN = 1024
+	MNTNAMLEN  = 255
+)
+
+const (
+	MOUNTPROC3_NULL    = uint32(0)
+	MOUNTPROC3_MNT     = uint32(1)
+	MOUNTPROC3_DUMP    = uint32(2)
+	MOUNTPROC3_UMNT    = uint32(3)
+	MOUNTPROC3_UMNTALL = uint32(4)
+	MOUNTPROC3_EXPORT  = uint32(5)
+)
+
+func MountProc3Name(proc uint32) string {
+	switch proc {
+	case MOUNTPROC3_NULL:
+		return "null"
+	case MOUNTPROC3_MNT:
+		return "mnt"
+	case MOUNTPROC3_DUMP:
+		return "dump"
+	case MOUNTPROC3_UMNT:
+		return "umnt"
+	case MOUNTPROC3_UMNTALL:
+		return "umntall"
+	case MOUNTPROC3_EXPORT:
+		return "export"
+	}
+
+	return fmt.Sprintf("%d", proc)
+}
+
+const (
+	MNT3_OK             = uint32(0)
+	MNT3ERR_PERM        = uint32(1)
+	MNT3ERR_NOENT       = uint32(2)
+	MNT3ERR_IO          = uint32(5)
+	MNT3ERR_ACCES       = uint32(13)
+	MNT3ERR_NOTDIR      = uint32(20)
+	MNT3ERR_INVAL       = uint32(22)
+	MNT3ERR_NAMETOOLONG = uint32(63)
+	MNT3ERR_NOTSUPP     = uint32(10004)
+	MNT3ERR_SERVERFAULT = uint32(10006)
+)
+
+// Err2StatusMnt translates an error to a MOUNT status, which are a subset of the NFSv3 statuses.
+func Err2StatusMnt(err error) uint32 {
+	switch status := Err2Status3(err); status {
+	case NFS3_OK, NFS3ERR_PERM, NFS3ERR_NOENT, NFS3ERR_IO, NFS3ERR_ACCES, NFS3ERR_NOTDIR,
+		NFS3ERR_INVAL, NFS3ERR_NAMETOOLONG, NFS3ERR_NOTSUPP, NFS3ERR_SERVERFAULT:
+		return status
+	case NFS3ERR_STALE:
+		return MNT3ERR_NOENT
+	default:
+		return MNT3ERR_IO
+	}
+}
+
+type MountRes3ok struct {
+	FHandle     []byte
+	AuthFlavors []uint32
+}
+
+type MountBody3 struct {
+	Hostname  string
+	Directory string
+	Next      *MountBody3
+}
+
+type GroupNode3 struct {
+	Name string
+	Next *GroupNode3
+}
+
+type ExportNode3 struct {
+	Dir    string
+	Groups Groups3
+	Next   *ExportNode3
+}
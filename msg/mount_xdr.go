@@ -0,0 +1,107 @@
+// This file was automatically generated by go generate; DO NOT EDIT
+package msg
+
+// This file contains specialed Decode and Encode functions
+// to avoid the use of the reflect package while encoding.
+// In principal, everything should work when commenting out
+// this file.
+
+import "github.com/kuleuven/nfs4go/xdr"
+
+func (x *MountRes3ok) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.FHandle, &x.AuthFlavors)
+}
+	
+func (x MountRes3ok) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.FHandle, x.AuthFlavors)
+}
+
+func (x *MountBody3) Decode(decoder *xdr.Decoder) error {
+	if err := decoder.DecodeAll(&x.Hostname, &x.Directory); err != nil {
+		return err
+	}
+
+	if ok, err := decoder.Bool(); err != nil || !ok {
+		return err
+	}
+
+	x.Next = new(MountBody3)
+
+	return decoder.Decode(x.Next)
+}
+	
+func (x MountBody3) Encode(encoder *xdr.Encoder) error {
+	if err := encoder.EncodeAll(x.Hostname, x.Directory); err != nil {
+		return err
+	}
+
+	if x.Next == nil {
+		return encoder.Bool(false)
+	}
+
+	if err := encoder.Bool(true); err != nil {
+		return err
+	}
+
+	return encoder.Encode(*x.Next)
+}
+
+func (x *GroupNode3) Decode(decoder *xdr.Decoder) error {
+	if err := decoder.DecodeAll(&x.Name); err != nil {
+		return err
+	}
+
+	if ok, err := decoder.Bool(); err != nil || !ok {
+		return err
+	}
+
+	x.Next = new(GroupNode3)
+
+	return decoder.Decode(x.Next)
+}
+	
+func (x GroupNode3) Encode(encoder *xdr.Encoder) error {
+	if err := encoder.EncodeAll(x.Name); err != nil {
+		return err
+	}
+
+	if x.Next == nil {
+		return encoder.Bool(false)
+	}
+
+	if err := encoder.Bool(true); err != nil {
+		return err
+	}
+
+	return encoder.Encode(*x.Next)
+}
+
+func (x *ExportNode3) Decode(decoder *xdr.Decoder) error {
+	if err := decoder.DecodeAll(&x.Dir, &x.Groups); err != nil {
+		return err
+	}
+
+	if ok, err := decoder.Bool(); err != nil || !ok {
+		return err
+	}
+
+	x.Next = new(ExportNode3)
+
+	return decoder.Decode(x.Next)
+}
+	
+func (x ExportNode3) Encode(encoder *xdr.Encoder) error {
+	if err := encoder.EncodeAll(x.Dir, x.Groups); err != nil {
+		return err
+	}
+
+	if x.Next == nil {
+		return encoder.Bool(false)
+	}
+
+	if err := encoder.Bool(true); err != nil {
+		return err
+	}
+
+	return encoder.Encode(*x.Next)
+}
\ No newline at end of file
@@ -0,0 +1,555 @@
+//go:generate go run ../xdr/generate/generate.go -- nfs3.go
+//nolint:staticcheck
+package msg
+
+import "fmt"
+
+// Structures of NFS version 3, rfc1813.
+// Optional values are encoded as unions with a void arm for false.
+
+const (
+	NFS3_PROGRAM = uint32(100003)
+	NFS3_VERSION = uint32(3)
+
+	NFS3_FHSIZE         = 64
+	NFS3_COOKIEVERFSIZE = 8
+	NFS3_CREATEVERFSIZE = 8
+	NFS3_WRITEVERFSIZE  = 8
+)
+
+const (
+	NFSPROC3_NULL        = uint32(0)
+	NFSPROC3_GETATTR     = uint32(1)
+	NFSPROC3_SETATTR     = uint32(2)
+	NFSPROC3_LOOKUP      = uint32(3)
+	NFSPROC3_ACCESS      = uint32(4)
+	NFSPROC3_READLINK    = uint32(5)
+	NFSPROC3_READ        = uint32(6)
+	NFSPROC3_WRITE       = uint32(7)
+	NFSPROC3_CREATE      = uint32(8)
+	NFSPROC3_MKDIR       = uint32(9)
+	NFSPROC3_SYMLINK     = uint32(10)
+	NFSPROC3_MKNOD       = uint32(11)
+	NFSPROC3_REMOVE      = uint32(12)
+	NFSPROC3_RMDIR       = uint32(13)
+	NFSPROC3_RENAME      = uint32(14)
+	NFSPROC3_LINK        = uint32(15)
+	NFSPROC3_READDIR     = uint32(16)
+	NFSPROC3_READDIRPLUS = uint32(17)
+	NFSPROC3_FSSTAT      = uint32(18)
+	NFSPROC3_FSINFO      = uint32(19)
+	NFSPROC3_PATHCONF    = uint32(20)
+	NFSPROC3_COMMIT      = uint32(21)
+)
+
+func Proc3Name(proc uint32) string {
+	switch proc {
+	case NFSPROC3_NULL:
+		return "null"
+	case NFSPROC3_GETATTR:
+		return "getattr"
+	case NFSPROC3_SETATTR:
+		return "setattr"
+	case NFSPROC3_LOOKUP:
+		return "lookup"
+	case NFSPROC3_ACCESS:
+		return "access"
+	case NFSPROC3_READLINK:
+		return "readlink"
+	case NFSPROC3_READ:
+		return "read"
+	case NFSPROC3_WRITE:
+		return "write"
+	case NFSPROC3_CREATE:
+		return "create"
+	case NFSPROC3_MKDIR:
+		return "mkdir"
+	case NFSPROC3_SYMLINK:
+		return "symlink"
+	case NFSPROC3_MKNOD:
+		return "mknod"
+	case NFSPROC3_REMOVE:
+		return "remove"
+	case NFSPROC3_RMDIR:
+		return "rmdir"
+	case NFSPROC3_RENAME:
+		return "rename"
+	case NFSPROC3_LINK:
+		return "link"
+	case NFSPROC3_READDIR:
+		return "readdir"
+	case NFSPROC3_READDIRPLUS:
+		return "readdirplus"
+	case NFSPROC3_FSSTAT:
+		return "fsstat"
+	case NFSPROC3_FSINFO:
+		return "fsinfo"
+	case NFSPROC3_PATHCONF:
+		return "pathconf"
+	case NFSPROC3_COMMIT:
+		return "commit"
+	}
+
+	return fmt.Sprintf("%d", proc)
+}
+
+const (
+	NFS3_OK             = uint32(0)
+	NFS3ERR_PERM        = uint32(1)
+	NFS3ERR_NOENT       = uint32(2)
+	NFS3ERR_IO          = uint32(5)
+	NFS3ERR_NXIO        = uint32(6)
+	NFS3ERR_ACCES       = uint32(13)
+	NFS3ERR_EXIST       = uint32(17)
+	NFS3ERR_XDEV        = uint32(18)
+	NFS3ERR_NODEV       = uint32(19)
+	NFS3ERR_NOTDIR      = uint32(20)
+	NFS3ERR_ISDIR       = uint32(21)
+	NFS3ERR_INVAL       = uint32(22)
+	NFS3ERR_FBIG        = uint32(27)
+	NFS3ERR_NOSPC       = uint32(28)
+	NFS3ERR_ROFS        = uint32(30)
+	NFS3ERR_MLINK       = uint32(31)
+	NFS3ERR_NAMETOOLONG = uint32(63)
+	NFS3ERR_NOTEMPTY    = uint32(66)
+	NFS3ERR_DQUOT       = uint32(69)
+	NFS3ERR_STALE       = uint32(70)
+	NFS3ERR_REMOTE      = uint32(71)
+	NFS3ERR_BADHANDLE   = uint32(10001)
+	NFS3ERR_NOT_SYNC    = uint32(10002)
+	NFS3ERR_BAD_COOKIE  = uint32(10003)
+	NFS3ERR_NOTSUPP     = uint32(10004)
+	NFS3ERR_TOOSMALL    = uint32(10005)
+	NFS3ERR_SERVERFAULT = uint32(10006)
+	NFS3ERR_BADTYPE     = uint32(10007)
+	NFS3ERR_JUKEBOX     = uint32(10008)
+)
+
+// Err2Status3 is the NFSv3 counterpart of Err2Status. Most statuses have the same value
+// in both versions, the statuses that only exist in NFSv4 are translated.
+func Err2Status3(err error) uint32 {
+	status := Err2Status(err)
+
+	switch status {
+	case NFS4_OK, NFS4ERR_PERM, NFS4ERR_NOENT, NFS4ERR_IO, NFS4ERR_NXIO, NFS4ERR_ACCESS,
+		NFS4ERR_EXIST, NFS4ERR_XDEV, NFS4ERR_NOTDIR, NFS4ERR_ISDIR, NFS4ERR_INVAL, NFS4ERR_FBIG,
+		NFS4ERR_NOSPC, NFS4ERR_ROFS, NFS4ERR_MLINK, NFS4ERR_NAMETOOLONG, NFS4ERR_NOTEMPTY,
+		NFS4ERR_DQUOT, NFS4ERR_STALE, NFS4ERR_BADHANDLE, NFS4ERR_BAD_COOKIE, NFS4ERR_NOTSUPP,
+		NFS4ERR_TOOSMALL, NFS4ERR_SERVERFAULT, NFS4ERR_BADTYPE, NFS4ERR_DELAY:
+		return status
+	case NFS4ERR_FHEXPIRED:
+		return NFS3ERR_STALE
+	case NFS4ERR_NOT_SAME:
+		return NFS3ERR_BAD_COOKIE
+	case NFS4ERR_SYMLINK, NFS4ERR_WRONG_TYPE, NFS4ERR_BADNAME, NFS4ERR_BADCHAR, NFS4ERR_BADOWNER:
+		return NFS3ERR_INVAL
+	case NFS4ERR_LOCKED, NFS4ERR_GRACE:
+		return NFS3ERR_JUKEBOX
+	default:
+		return NFS3ERR_IO
+	}
+}
+
+const (
+	NF3REG  = uint32(1)
+	NF3DIR  = uint32(2)
+	NF3BLK  = uint32(3)
+	NF3CHR  = uint32(4)
+	NF3LNK  = uint32(5)
+	NF3SOCK = uint32(6)
+	NF3FIFO = uint32(7)
+)
+
+const (
+	ACCESS3_READ    = uint32(0x0001)
+	ACCESS3_LOOKUP  = uint32(0x0002)
+	ACCESS3_MODIFY  = uint32(0x0004)
+	ACCESS3_EXTEND  = uint32(0x0008)
+	ACCESS3_DELETE  = uint32(0x0010)
+	ACCESS3_EXECUTE = uint32(0x0020)
+)
+
+const (
+	UNSTABLE  = uint32(0)
+	DATA_SYNC = uint32(1)
+	FILE_SYNC = uint32(2)
+)
+
+const (
+	UNCHECKED = uint32(0)
+	GUARDED   = uint32(1)
+	EXCLUSIVE = uint32(2)
+)
+
+const (
+	DONT_CHANGE        = uint32(0)
+	SET_TO_SERVER_TIME = uint32(1)
+	SET_TO_CLIENT_TIME = uint32(2)
+)
+
+const (
+	FSF3_LINK        = uint32(0x0001)
+	FSF3_SYMLINK     = uint32(0x0002)
+	FSF3_HOMOGENEOUS = uint32(0x0008)
+	FSF3_CANSETTIME  = uint32(0x0010)
+)
+
+type NfsTime3 struct {
+	Seconds  uint32
+	NSeconds uint32
+}
+
+type Specdata3 struct {
+	D1 uint32
+	D2 uint32
+}
+
+type Fattr3 struct {
+	Type   uint32 // NF3*
+	Mode   uint32
+	NLink  uint32
+	Uid    uint32
+	Gid    uint32
+	Size   uint64
+	Used   uint64
+	Rdev   Specdata3
+	Fsid   uint64
+	FileID uint64
+	Atime  NfsTime3
+	Mtime  NfsTime3
+	Ctime  NfsTime3
+}
+
+type PostOpAttr3 struct {
+	AttributesFollow uint32 `xdr:"union"`
+	Void             Void
+	Attributes       Fattr3
+}
+
+type WccAttr3 struct {
+	Size  uint64
+	Mtime NfsTime3
+	Ctime NfsTime3
+}
+
+type PreOpAttr3 struct {
+	AttributesFollow uint32 `xdr:"union"`
+	Void             Void
+	Attributes       WccAttr3
+}
+
+type WccData3 struct {
+	Before PreOpAttr3
+	After  PostOpAttr3
+}
+
+type PostOpFh3 struct {
+	HandleFollows uint32 `xdr:"union"`
+	Void          Void
+	Handle        []byte // nfs_fh3
+}
+
+type SetUint3 struct {
+	SetIt uint32 `xdr:"union"`
+	Void  Void
+	Value uint32
+}
+
+type SetSize3 struct {
+	SetIt uint32 `xdr:"union"`
+	Void  Void
+	Size  uint64
+}
+
+type SetTime3 struct {
+	How        uint32 `xdr:"union"` // DONT_CHANGE | SET_TO_SERVER_TIME | SET_TO_CLIENT_TIME
+	DontChange Void
+	ServerTime Void
+	ClientTime NfsTime3
+}
+
+type Sattr3 struct {
+	Mode  SetUint3
+	Uid   SetUint3
+	Gid   SetUint3
+	Size  SetSize3
+	Atime SetTime3
+	Mtime SetTime3
+}
+
+type DirOpArgs3 struct {
+	Dir  []byte // nfs_fh3
+	Name string
+}
+
+type GETATTR3args struct {
+	Object []byte
+}
+
+type GETATTR3resok struct {
+	ObjAttributes Fattr3
+}
+
+type SattrGuard3 struct {
+	Check    uint32 `xdr:"union"`
+	Void     Void
+	ObjCtime NfsTime3
+}
+
+type SETATTR3args struct {
+	Object        []byte
+	NewAttributes Sattr3
+	Guard         SattrGuard3
+}
+
+type LOOKUP3args struct {
+	What DirOpArgs3
+}
+
+type LOOKUP3resok struct {
+	Object        []byte
+	ObjAttributes PostOpAttr3
+	DirAttributes PostOpAttr3
+}
+
+type ACCESS3args struct {
+	Object []byte
+	Access uint32
+}
+
+type ACCESS3resok struct {
+	ObjAttributes PostOpAttr3
+	Access        uint32
+}
+
+type READLINK3args struct {
+	Symlink []byte
+}
+
+type READLINK3resok struct {
+	SymlinkAttributes PostOpAttr3
+	Data              string
+}
+
+type READ3args struct {
+	File   []byte
+	Offset uint64
+	Count  uint32
+}
+
+type READ3resok struct {
+	FileAttributes PostOpAttr3
+	Count          uint32
+	Eof            bool
+	Data           []byte
+}
+
+type WRITE3args struct {
+	File   []byte
+	Offset uint64
+	Count  uint32
+	Stable uint32 // UNSTABLE | DATA_SYNC | FILE_SYNC
+	Data   []byte
+}
+
+type WRITE3resok struct {
+	FileWcc   WccData3
+	Count     uint32
+	Committed uint32
+	Verf      uint64 // writeverf3
+}
+
+type CreateHow3 struct {
+	Mode           uint32 `xdr:"union"` // UNCHECKED | GUARDED | EXCLUSIVE
+	ObjAttributes  Sattr3
+	GuardedAttrs   Sattr3
+	CreateVerifier uint64 // createverf3
+}
+
+type CREATE3args struct {
+	Where DirOpArgs3
+	How   CreateHow3
+}
+
+// DIROP3resok is the result of CREATE, MKDIR, SYMLINK and MKNOD.
+type DIROP3resok struct {
+	Obj           PostOpFh3
+	ObjAttributes PostOpAttr3
+	DirWcc        WccData3
+}
+
+type MKDIR3args struct {
+	Where      DirOpArgs3
+	Attributes Sattr3
+}
+
+type SymlinkData3 struct {
+	SymlinkAttributes Sattr3
+	SymlinkData       string
+}
+
+type SYMLINK3args struct {
+	Where   DirOpArgs3
+	Symlink SymlinkData3
+}
+
+type DeviceData3 struct {
+	DevAttributes Sattr3
+	Spec          Specdata3
+}
+
+type MknodData3 struct {
+	Type      uint32 `xdr:"union"` // NF3*
+	Void      Void
+	VoidReg   Void
+	VoidDir   Void
+	BlkDevice DeviceData3
+	ChrDevice DeviceData3
+	VoidLnk   Void
+	SockAttrs Sattr3
+	PipeAttrs Sattr3
+}
+
+type MKNOD3args struct {
+	Where DirOpArgs3
+	What  MknodData3
+}
+
+type REMOVE3args struct {
+	Object DirOpArgs3
+}
+
+type RMDIR3args struct {
+	Object DirOpArgs3
+}
+
+type RENAME3args struct {
+	From DirOpArgs3
+	To   DirOpArgs3
+}
+
+type RENAME3res struct {
+	FromDirWcc WccData3
+	ToDirWcc   WccData3
+}
+
+type LINK3args struct {
+	File []byte
+	Link DirOpArgs3
+}
+
+type LINK3res struct {
+	FileAttributes PostOpAttr3
+	LinkDirWcc     WccData3
+}
+
+type READDIR3args struct {
+	Dir        []byte
+	Cookie     uint64
+	CookieVerf uint64 // cookieverf3
+	Count      uint32
+}
+
+type Entry3 struct {
+	FileID uint64
+	Name   string
+	Cookie uint64
+	Next   *Entry3
+}
+
+type DirList3 struct {
+	Entries *Entry3
+	Eof     bool
+}
+
+type READDIR3resok struct {
+	DirAttributes PostOpAttr3
+	CookieVerf    uint64
+	Reply         DirList3
+}
+
+type READDIRPLUS3args struct {
+	Dir        []byte
+	Cookie     uint64
+	CookieVerf uint64
+	DirCount   uint32 // max size of bytes of directory info
+	MaxCount   uint32 // max size of the entire READDIRPLUS3resok
+}
+
+type EntryPlus3 struct {
+	FileID         uint64
+	Name           string
+	Cookie         uint64
+	NameAttributes PostOpAttr3
+	NameHandle     PostOpFh3
+	Next           *EntryPlus3
+}
+
+type DirListPlus3 struct {
+	Entries *EntryPlus3
+	Eof     bool
+}
+
+type READDIRPLUS3resok struct {
+	DirAttributes PostOpAttr3
+	CookieVerf    uint64
+	Reply         DirListPlus3
+}
+
+type FSSTAT3args struct {
+	FsRoot []byte
+}
+
+type FSSTAT3resok struct {
+	ObjAttributes PostOpAttr3
+	Tbytes        uint64
+	Fbytes        uint64
+	Abytes        uint64
+	Tfiles        uint64
+	Ffiles        uint64
+	Afiles        uint64
+	Invarsec      uint32
+}
+
+type FSINFO3args struct {
+	FsRoot []byte
+}
+
+type FSINFO3resok struct {
+	ObjAttributes PostOpAttr3
+	Rtmax         uint32
+	Rtpref        uint32
+	Rtmult        uint32
+	Wtmax         uint32
+	Wtpref        uint32
+	Wtmult        uint32
+	Dtpref        uint32
+	MaxFileSize   uint64
+	TimeDelta     NfsTime3
+	Properties    uint32 // FSF3_*
+}
+
+type PATHCONF3args struct {
+	Object []byte
+}
+
+type PATHCONF3resok struct {
+	ObjAttributes   PostOpAttr3
+	LinkMax         uint32
+	NameMax         uint32
+	NoTrunc         bool
+	ChownRestricted bool
+	CaseInsensitive bool
+	CasePreserving  bool
+}
+
+type COMMIT3args struct {
+	File   []byte
+	Offset uint64
+	Count  uint32
+}
+
+type COMMIT3resok struct {
+	FileWcc WccData3
+	Verf    uint64 // writeverf3
+}
@@ -0,0 +1,561 @@
+// This file was automatically generated by go generate; DO NOT EDIT
+package msg
+
+// This file contains specialed Decode and Encode functions
+// to avoid the use of the reflect package while encoding.
+// In principal, everything should work when commenting out
+// this file.
+
+import "github.com/kuleuven/nfs4go/xdr"
+
+func (x *NfsTime3) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.Seconds, &x.NSeconds)
+}
+	
+func (x NfsTime3) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.Seconds, x.NSeconds)
+}
+
+func (x *Specdata3) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.D1, &x.D2)
+}
+	
+func (x Specdata3) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.D1, x.D2)
+}
+
+func (x *Fattr3) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.Type, &x.Mode, &x.NLink, &x.Uid, &x.Gid, &x.Size, &x.Used, &x.Rdev, &x.Fsid, &x.FileID, &x.Atime, &x.Mtime, &x.Ctime)
+}
+	
+func (x Fattr3) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.Type, x.Mode, x.NLink, x.Uid, x.Gid, x.Size, x.Used, x.Rdev, x.Fsid, x.FileID, x.Atime, x.Mtime, x.Ctime)
+}
+
+func (x *PostOpAttr3) Decode(decoder *xdr.Decoder) error {
+	return decoder.Union(&x.AttributesFollow, &x.Void, &x.Attributes)
+}
+	
+func (x PostOpAttr3) Encode(encoder *xdr.Encoder) error {
+	return encoder.Union(x.AttributesFollow, x.Void, x.Attributes)
+}
+
+func (x *WccAttr3) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.Size, &x.Mtime, &x.Ctime)
+}
+	
+func (x WccAttr3) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.Size, x.Mtime, x.Ctime)
+}
+
+func (x *PreOpAttr3) Decode(decoder *xdr.Decoder) error {
+	return decoder.Union(&x.AttributesFollow, &x.Void, &x.Attributes)
+}
+	
+func (x PreOpAttr3) Encode(encoder *xdr.Encoder) error {
+	return encoder.Union(x.AttributesFollow, x.Void, x.Attributes)
+}
+
+func (x *WccData3) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.Before, &x.After)
+}
+	
+func (x WccData3) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.Before, x.After)
+}
+
+func (x *PostOpFh3) Decode(decoder *xdr.Decoder) error {
+	return decoder.Union(&x.HandleFollows, &x.Void, &x.Handle)
+}
+	
+func (x PostOpFh3) Encode(encoder *xdr.Encoder) error {
+	return encoder.Union(x.HandleFollows, x.Void, x.Handle)
+}
+
+func (x *SetUint3) Decode(decoder *xdr.Decoder) error {
+	return decoder.Union(&x.SetIt, &x.Void, &x.Value)
+}
+	
+func (x SetUint3) Encode(encoder *xdr.Encoder) error {
+	return encoder.Union(x.SetIt, x.Void, x.Value)
+}
+
+func (x *SetSize3) Decode(decoder *xdr.Decoder) error {
+	return decoder.Union(&x.SetIt, &x.Void, &x.Size)
+}
+	
+func (x SetSize3) Encode(encoder *xdr.Encoder) error {
+	return encoder.Union(x.SetIt, x.Void, x.Size)
+}
+
+func (x *SetTime3) Decode(decoder *xdr.Decoder) error {
+	return decoder.Union(&x.How, &x.DontChange, &x.ServerTime, &x.ClientTime)
+}
+	
+func (x SetTime3) Encode(encoder *xdr.Encoder) error {
+	return encoder.Union(x.How, x.DontChange, x.ServerTime, x.ClientTime)
+}
+
+func (x *Sattr3) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.Mode, &x.Uid, &x.Gid, &x.Size, &x.Atime, &x.Mtime)
+}
+	
+func (x Sattr3) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.Mode, x.Uid, x.Gid, x.Size, x.Atime, x.Mtime)
+}
+
+func (x *DirOpArgs3) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.Dir, &x.Name)
+}
+	
+func (x DirOpArgs3) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.Dir, x.Name)
+}
+
+func (x *GETATTR3args) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.Object)
+}
+	
+func (x GETATTR3args) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.Object)
+}
+
+func (x *GETATTR3resok) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.ObjAttributes)
+}
+	
+func (x GETATTR3resok) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.ObjAttributes)
+}
+
+func (x *SattrGuard3) Decode(decoder *xdr.Decoder) error {
+	return decoder.Union(&x.Check, &x.Void, &x.ObjCtime)
+}
+	
+func (x SattrGuard3) Encode(encoder *xdr.Encoder) error {
+	return encoder.Union(x.Check, x.Void, x.ObjCtime)
+}
+
+func (x *SETATTR3args) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.Object, &x.NewAttributes, &x.Guard)
+}
+	
+func (x SETATTR3args) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.Object, x.NewAttributes, x.Guard)
+}
+
+func (x *LOOKUP3args) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.What)
+}
+	
+func (x LOOKUP3args) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.What)
+}
+
+func (x *LOOKUP3resok) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.Object, &x.ObjAttributes, &x.DirAttributes)
+}
+	
+func (x LOOKUP3resok) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.Object, x.ObjAttributes, x.DirAttributes)
+}
+
+func (x *ACCESS3args) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.Object, &x.Access)
+}
+	
+func (x ACCESS3args) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.Object, x.Access)
+}
+
+func (x *ACCESS3resok) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.ObjAttributes, &x.Access)
+}
+	
+func (x ACCESS3resok) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.ObjAttributes, x.Access)
+}
+
+func (x *READLINK3args) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.Symlink)
+}
+	
+func (x READLINK3args) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.Symlink)
+}
+
+func (x *READLINK3resok) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.SymlinkAttributes, &x.Data)
+}
+	
+func (x READLINK3resok) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.SymlinkAttributes, x.Data)
+}
+
+func (x *READ3args) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.File, &x.Offset, &x.Count)
+}
+	
+func (x READ3args) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.File, x.Offset, x.Count)
+}
+
+func (x *READ3resok) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.FileAttributes, &x.Count, &x.Eof, &x.Data)
+}
+	
+func (x READ3resok) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.FileAttributes, x.Count, x.Eof, x.Data)
+}
+
+func (x *WRITE3args) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.File, &x.Offset, &x.Count, &x.Stable, &x.Data)
+}
+	
+func (x WRITE3args) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.File, x.Offset, x.Count, x.Stable, x.Data)
+}
+
+func (x *WRITE3resok) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.FileWcc, &x.Count, &x.Committed, &x.Verf)
+}
+	
+func (x WRITE3resok) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.FileWcc, x.Count, x.Committed, x.Verf)
+}
+
+func (x *CreateHow3) Decode(decoder *xdr.Decoder) error {
+	return decoder.Union(&x.Mode, &x.ObjAttributes, &x.GuardedAttrs, &x.CreateVerifier)
+}
+	
+func (x CreateHow3) Encode(encoder *xdr.Encoder) error {
+	return encoder.Union(x.Mode, x.ObjAttributes, x.GuardedAttrs, x.CreateVerifier)
+}
+
+func (x *CREATE3args) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.Where, &x.How)
+}
+	
+func (x CREATE3args) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.Where, x.How)
+}
+
+func (x *DIROP3resok) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.Obj, &x.ObjAttributes, &x.DirWcc)
+}
+	
+func (x DIROP3resok) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.Obj, x.ObjAttributes, x.DirWcc)
+}
+
+func (x *MKDIR3args) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.Where, &x.Attributes)
+}
+	
+func (x MKDIR3args) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.Where, x.Attributes)
+}
+
+func (x *SymlinkData3) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.SymlinkAttributes, &x.SymlinkData)
+}
+	
+func (x SymlinkData3) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.SymlinkAttributes, x.SymlinkData)
+}
+
+func (x *SYMLINK3args) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.Where, &x.Symlink)
+}
+	
+func (x SYMLINK3args) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.Where, x.Symlink)
+}
+
+func (x *DeviceData3) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.DevAttributes, &x.Spec)
+}
+	
+func (x DeviceData3) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.DevAttributes, x.Spec)
+}
+
+func (x *MknodData3) Decode(decoder *xdr.Decoder) error {
+	return decoder.Union(&x.Type, &x.Void, &x.VoidReg, &x.VoidDir, &x.BlkDevice, &x.ChrDevice, &x.VoidLnk, &x.SockAttrs, &x.PipeAttrs)
+}
+	
+func (x MknodData3) Encode(encoder *xdr.Encoder) error {
+	return encoder.Union(x.Type, x.Void, x.VoidReg, x.VoidDir, x.BlkDevice, x.ChrDevice, x.VoidLnk, x.SockAttrs, x.PipeAttrs)
+}
+
+func (x *MKNOD3args) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.Where, &x.What)
+}
+	
+func (x MKNOD3args) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.Where, x.What)
+}
+
+func (x *REMOVE3args) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.Object)
+}
+	
+func (x REMOVE3args) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.Object)
+}
+
+func (x *RMDIR3args) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.Object)
+}
+	
+func (x RMDIR3args) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.Object)
+}
+
+func (x *RENAME3args) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.From, &x.To)
+}
+	
+func (x RENAME3args) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.From, x.To)
+}
+
+func (x *RENAME3res) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.FromDirWcc, &x.ToDirWcc)
+}
+	
+func (x RENAME3res) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.FromDirWcc, x.ToDirWcc)
+}
+
+func (x *LINK3args) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.File, &x.Link)
+}
+	
+func (x LINK3args) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.File, x.Link)
+}
+
+func (x *LINK3res) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.FileAttributes, &x.LinkDirWcc)
+}
+	
+func (x LINK3res) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.FileAttributes, x.LinkDirWcc)
+}
+
+func (x *READDIR3args) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.Dir, &x.Cookie, &x.CookieVerf, &x.Count)
+}
+	
+func (x READDIR3args) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.Dir, x.Cookie, x.CookieVerf, x.Count)
+}
+
+func (x *Entry3) Decode(decoder *xdr.Decoder) error {
+	if err := decoder.DecodeAll(&x.FileID, &x.Name, &x.Cookie); err != nil {
+		return err
+	}
+
+	if ok, err := decoder.Bool(); err != nil || !ok {
+		return err
+	}
+
+	x.Next = new(Entry3)
+
+	return decoder.Decode(x.Next)
+}
+	
+func (x Entry3) Encode(encoder *xdr.Encoder) error {
+	if err := encoder.EncodeAll(x.FileID, x.Name, x.Cookie); err != nil {
+		return err
+	}
+
+	if x.Next == nil {
+		return encoder.Bool(false)
+	}
+
+	if err := encoder.Bool(true); err != nil {
+		return err
+	}
+
+	return encoder.Encode(*x.Next)
+}
+
+func (x *DirList3) Decode(decoder *xdr.Decoder) error {
+	if ok, err := decoder.Bool(); err != nil {
+		return err
+	} else if ok {
+	 	x.Entries = new(Entry3)
+
+		if err := decoder.Decode(x.Entries); err != nil {
+			return err
+		}
+	}
+
+	return decoder.DecodeAll(&x.Eof)
+}
+	
+func (x DirList3) Encode(encoder *xdr.Encoder) error {
+	ok := x.Entries != nil
+
+	if err := encoder.Bool(ok); err != nil {
+		return err
+	}
+
+	if ok {
+		if err := encoder.Encode(*x.Entries); err != nil {
+			return err
+		}
+	}
+
+	return encoder.EncodeAll(x.Eof)
+}
+
+func (x *READDIR3resok) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.DirAttributes, &x.CookieVerf, &x.Reply)
+}
+	
+func (x READDIR3resok) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.DirAttributes, x.CookieVerf, x.Reply)
+}
+
+func (x *READDIRPLUS3args) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.Dir, &x.Cookie, &x.CookieVerf, &x.DirCount, &x.MaxCount)
+}
+	
+func (x READDIRPLUS3args) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.Dir, x.Cookie, x.CookieVerf, x.DirCount, x.MaxCount)
+}
+
+func (x *EntryPlus3) Decode(decoder *xdr.Decoder) error {
+	if err := decoder.DecodeAll(&x.FileID, &x.Name, &x.Cookie, &x.NameAttributes, &x.NameHandle); err != nil {
+		return err
+	}
+
+	if ok, err := decoder.Bool(); err != nil || !ok {
+		return err
+	}
+
+	x.Next = new(EntryPlus3)
+
+	return decoder.Decode(x.Next)
+}
+	
+func (x EntryPlus3) Encode(encoder *xdr.Encoder) error {
+	if err := encoder.EncodeAll(x.FileID, x.Name, x.Cookie, x.NameAttributes, x.NameHandle); err != nil {
+		return err
+	}
+
+	if x.Next == nil {
+		return encoder.Bool(false)
+	}
+
+	if err := encoder.Bool(true); err != nil {
+		return err
+	}
+
+	return encoder.Encode(*x.Next)
+}
+
+func (x *DirListPlus3) Decode(decoder *xdr.Decoder) error {
+	if ok, err := decoder.Bool(); err != nil {
+		return err
+	} else if ok {
+	 	x.Entries = new(EntryPlus3)
+
+		if err := decoder.Decode(x.Entries); err != nil {
+			return err
+		}
+	}
+
+	return decoder.DecodeAll(&x.Eof)
+}
+	
+func (x DirListPlus3) Encode(encoder *xdr.Encoder) error {
+	ok := x.Entries != nil
+
+	if err := encoder.Bool(ok); err != nil {
+		return err
+	}
+
+	if ok {
+		if err := encoder.Encode(*x.Entries); err != nil {
+			return err
+		}
+	}
+
+	return encoder.EncodeAll(x.Eof)
+}
+
+func (x *READDIRPLUS3resok) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.DirAttributes, &x.CookieVerf, &x.Reply)
+}
+	
+func (x READDIRPLUS3resok) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.DirAttributes, x.CookieVerf, x.Reply)
+}
+
+func (x *FSSTAT3args) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.FsRoot)
+}
+	
+func (x FSSTAT3args) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.FsRoot)
+}
+
+func (x *FSSTAT3resok) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.ObjAttributes, &x.Tbytes, &x.Fbytes, &x.Abytes, &x.Tfiles, &x.Ffiles, &x.Afiles, &x.Invarsec)
+}
+	
+func (x FSSTAT3resok) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.ObjAttributes, x.Tbytes, x.Fbytes, x.Abytes, x.Tfiles, x.Ffiles, x.Afiles, x.Invarsec)
+}
+
+func (x *FSINFO3args) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.FsRoot)
+}
+	
+func (x FSINFO3args) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.FsRoot)
+}
+
+func (x *FSINFO3resok) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.ObjAttributes, &x.Rtmax, &x.Rtpref, &x.Rtmult, &x.Wtmax, &x.Wtpref, &x.Wtmult, &x.Dtpref, &x.MaxFileSize, &x.TimeDelta, &x.Properties)
+}
+	
+func (x FSINFO3resok) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.ObjAttributes, x.Rtmax, x.Rtpref, x.Rtmult, x.Wtmax, x.Wtpref, x.Wtmult, x.Dtpref, x.MaxFileSize, x.TimeDelta, x.Properties)
+}
+
+func (x *PATHCONF3args) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.Object)
+}
+	
+func (x PATHCONF3args) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.Object)
+}
+
+func (x *PATHCONF3resok) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.ObjAttributes, &x.LinkMax, &x.NameMax, &x.NoTrunc, &x.ChownRestricted, &x.CaseInsensitive, &x.CasePreserving)
+}
+	
+func (x PATHCONF3resok) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.ObjAttributes, x.LinkMax, x.NameMax, x.NoTrunc, x.ChownRestricted, x.CaseInsensitive, x.CasePreserving)
+}
+
+func (x *COMMIT3args) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.File, &x.Offset, &x.Count)
+}
+	
+func (x COMMIT3args) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.File, x.Offset, x.Count)
+}
+
+func (x *COMMIT3resok) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.FileWcc, &x.Verf)
+}
+	
+func (x COMMIT3resok) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.FileWcc, x.Verf)
+}
\ No newline at end of file
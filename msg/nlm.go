@@ -0,0 +1,257 @@
+//go:generate go run ../xdr/generate/generate.go -- nlm.go
+//nolint:staticcheck
+package msg
+
+import "fmt"
+
+// Structures of the Network Lock Manager version 4, rfc1813 appendix II,
+// and of the Network Status Monitor version 1 (X/Open XNFS chapter 11).
+
+const (
+	NLM_PROGRAM = uint32(100021)
+	NLM_V4      = uint32(4)
+
+	LM_MAXSTRLEN = 1024
+)
+
+const (
+	NLMPROC4_NULL        = uint32(0)
+	NLMPROC4_TEST        = uint32(1)
+	NLMPROC4_LOCK        = uint32(2)
+	NLMPROC4_CANCEL      = uint32(3)
+	NLMPROC4_UNLOCK      = uint32(4)
+	NLMPROC4_GRANTED     = uint32(5)
+	NLMPROC4_TEST_MSG    = uint32(6)
+	NLMPROC4_LOCK_MSG    = uint32(7)
+	NLMPROC4_CANCEL_MSG  = uint32(8)
+	NLMPROC4_UNLOCK_MSG  = uint32(9)
+	NLMPROC4_GRANTED_MSG = uint32(10)
+	NLMPROC4_TEST_RES    = uint32(11)
+	NLMPROC4_LOCK_RES    = uint32(12)
+	NLMPROC4_CANCEL_RES  = uint32(13)
+	NLMPROC4_UNLOCK_RES  = uint32(14)
+	NLMPROC4_GRANTED_RES = uint32(15)
+	NLMPROC4_SHARE       = uint32(20)
+	NLMPROC4_UNSHARE     = uint32(21)
+	NLMPROC4_NM_LOCK     = uint32(22)
+	NLMPROC4_FREE_ALL    = uint32(23)
+)
+
+func NLMProc4Name(proc uint32) string {
+	switch proc {
+	case NLMPROC4_NULL:
+		return "null"
+	case NLMPROC4_TEST:
+		return "test"
+	case NLMPROC4_LOCK:
+		return "lock"
+	case NLMPROC4_CANCEL:
+		return "cancel"
+	case NLMPROC4_UNLOCK:
+		return "unlock"
+	case NLMPROC4_GRANTED:
+		return "granted"
+	case NLMPROC4_TEST_MSG:
+		return "test_msg"
+	case NLMPROC4_LOCK_MSG:
+		return "lock_msg"
+	case NLMPROC4_CANCEL_MSG:
+		return "cancel_msg"
+	case NLMPROC4_UNLOCK_MSG:
+		return "unlock_msg"
+	case NLMPROC4_GRANTED_MSG:
+		return "granted_msg"
+	case NLMPROC4_TEST_RES:
+		return "test_res"
+	case NLMPROC4_LOCK_RES:
+		return "lock_res"
+	case NLMPROC4_CANCEL_RES:
+		return "cancel_res"
+	case NLMPROC4_UNLOCK_RES:
+		return "unlock_res"
+	case NLMPROC4_GRANTED_RES:
+		return "granted_res"
+	case NLMPROC4_SHARE:
+		return "share"
+	case NLMPROC4_UNSHARE:
+		return "unshare"
+	case NLMPROC4_NM_LOCK:
+		return "nm_lock"
+	case NLMPROC4_FREE_ALL:
+		return "free_all"
+	}
+
+	return fmt.Sprintf("%d", proc)
+}
+
+const (
+	NLM4_GRANTED             = uint32(0)
+	NLM4_DENIED              = uint32(1)
+	NLM4_DENIED_NOLOCKS      = uint32(2)
+	NLM4_BLOCKED             = uint32(3)
+	NLM4_DENIED_GRACE_PERIOD = uint32(4)
+	NLM4_DEADLCK             = uint32(5)
+	NLM4_ROFS                = uint32(6)
+	NLM4_STALE_FH            = uint32(7)
+	NLM4_FBIG                = uint32(8)
+	NLM4_FAILED              = uint32(9)
+)
+
+// Share modes and access modes of NLM4Share.
+const (
+	FSM_DN  = uint32(0)
+	FSM_DR  = uint32(1)
+	FSM_DW  = uint32(2)
+	FSM_DRW = uint32(3)
+
+	FSA_NONE = uint32(0)
+	FSA_R    = uint32(1)
+	FSA_W    = uint32(2)
+	FSA_RW   = uint32(3)
+)
+
+type NLM4Holder struct {
+	Exclusive bool
+	Svid      uint32
+	Oh        []byte
+	Offset    uint64
+	Length    uint64
+}
+
+type NLM4Lock struct {
+	CallerName string
+	FH         []byte
+	Oh         []byte
+	Svid       uint32
+	Offset     uint64
+	Length     uint64 // zero to lock until the end of the file
+}
+
+type NLM4LockArgs struct {
+	Cookie    []byte
+	Block     bool
+	Exclusive bool
+	Lock      NLM4Lock
+	Reclaim   bool
+	State     uint32
+}
+
+type NLM4CancArgs struct {
+	Cookie    []byte
+	Block     bool
+	Exclusive bool
+	Lock      NLM4Lock
+}
+
+// NLM4TestArgs are the arguments of TEST, and of the GRANTED callback.
+type NLM4TestArgs struct {
+	Cookie    []byte
+	Exclusive bool
+	Lock      NLM4Lock
+}
+
+type NLM4UnlockArgs struct {
+	Cookie []byte
+	Lock   NLM4Lock
+}
+
+type NLM4Res struct {
+	Cookie []byte
+	Stat   uint32
+}
+
+type NLM4Share struct {
+	CallerName string
+	FH         []byte
+	Oh         []byte
+	Mode       uint32 // FSM_DN | FSM_DR | FSM_DW | FSM_DRW
+	Access     uint32 // FSA_NONE | FSA_R | FSA_W | FSA_RW
+}
+
+type NLM4ShareArgs struct {
+	Cookie  []byte
+	Share   NLM4Share
+	Reclaim bool
+}
+
+type NLM4ShareRes struct {
+	Cookie   []byte
+	Stat     uint32
+	Sequence uint32
+}
+
+// NLM4Notify are the arguments of FREE_ALL.
+type NLM4Notify struct {
+	Name  string
+	State uint32
+}
+
+const (
+	SM_PROGRAM = uint32(100024)
+	SM_VERS    = uint32(1)
+
+	SM_MAXSTRLEN = 1024
+)
+
+const (
+	SM_NULL       = uint32(0)
+	SM_STAT       = uint32(1)
+	SM_MON        = uint32(2)
+	SM_UNMON      = uint32(3)
+	SM_UNMON_ALL  = uint32(4)
+	SM_SIMU_CRASH = uint32(5)
+	SM_NOTIFY     = uint32(6)
+)
+
+func SMProcName(proc uint32) string {
+	switch proc {
+	case SM_NULL:
+		return "null"
+	case SM_STAT:
+		return "stat"
+	case SM_MON:
+		return "mon"
+	case SM_UNMON:
+		return "unmon"
+	case SM_UNMON_ALL:
+		return "unmon_all"
+	case SM_SIMU_CRASH:
+		return "simu_crash"
+	case SM_NOTIFY:
+		return "notify"
+	}
+
+	return fmt.Sprintf("%d", proc)
+}
+
+const (
+	STAT_SUCC = uint32(0)
+	STAT_FAIL = uint32(1)
+)
+
+type SMStatRes struct {
+	Res   uint32 // STAT_SUCC | STAT_FAIL
+	State uint32
+}
+
+type MyID struct {
+	MyName string
+	MyProg uint32
+	MyVers uint32
+	MyProc uint32
+}
+
+type MonID struct {
+	MonName string
+	MyID    MyID
+}
+
+type Mon struct {
+	MonID MonID
+	Priv  [16]byte
+}
+
+type StatChge struct {
+	MonName string
+	State   uint32
+}
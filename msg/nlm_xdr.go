@@ -0,0 +1,137 @@
+// This file was automatically generated by go generate; DO NOT EDIT
+package msg
+
+// This file contains specialed Decode and Encode functions
+// to avoid the use of the reflect package while encoding.
+// In principal, everything should work when commenting out
+// this file.
+
+import "github.com/kuleuven/nfs4go/xdr"
+
+func (x *NLM4Holder) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.Exclusive, &x.Svid, &x.Oh, &x.Offset, &x.Length)
+}
+	
+func (x NLM4Holder) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.Exclusive, x.Svid, x.Oh, x.Offset, x.Length)
+}
+
+func (x *NLM4Lock) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.CallerName, &x.FH, &x.Oh, &x.Svid, &x.Offset, &x.Length)
+}
+	
+func (x NLM4Lock) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.CallerName, x.FH, x.Oh, x.Svid, x.Offset, x.Length)
+}
+
+func (x *NLM4LockArgs) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.Cookie, &x.Block, &x.Exclusive, &x.Lock, &x.Reclaim, &x.State)
+}
+	
+func (x NLM4LockArgs) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.Cookie, x.Block, x.Exclusive, x.Lock, x.Reclaim, x.State)
+}
+
+func (x *NLM4CancArgs) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.Cookie, &x.Block, &x.Exclusive, &x.Lock)
+}
+	
+func (x NLM4CancArgs) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.Cookie, x.Block, x.Exclusive, x.Lock)
+}
+
+func (x *NLM4TestArgs) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.Cookie, &x.Exclusive, &x.Lock)
+}
+	
+func (x NLM4TestArgs) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.Cookie, x.Exclusive, x.Lock)
+}
+
+func (x *NLM4UnlockArgs) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.Cookie, &x.Lock)
+}
+	
+func (x NLM4UnlockArgs) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.Cookie, x.Lock)
+}
+
+func (x *NLM4Res) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.Cookie, &x.Stat)
+}
+	
+func (x NLM4Res) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.Cookie, x.Stat)
+}
+
+func (x *NLM4Share) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.CallerName, &x.FH, &x.Oh, &x.Mode, &x.Access)
+}
+	
+func (x NLM4Share) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.CallerName, x.FH, x.Oh, x.Mode, x.Access)
+}
+
+func (x *NLM4ShareArgs) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.Cookie, &x.Share, &x.Reclaim)
+}
+	
+func (x NLM4ShareArgs) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.Cookie, x.Share, x.Reclaim)
+}
+
+func (x *NLM4ShareRes) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.Cookie, &x.Stat, &x.Sequence)
+}
+	
+func (x NLM4ShareRes) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.Cookie, x.Stat, x.Sequence)
+}
+
+func (x *NLM4Notify) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.Name, &x.State)
+}
+	
+func (x NLM4Notify) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.Name, x.State)
+}
+
+func (x *SMStatRes) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.Res, &x.State)
+}
+	
+func (x SMStatRes) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.Res, x.State)
+}
+
+func (x *MyID) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.MyName, &x.MyProg, &x.MyVers, &x.MyProc)
+}
+	
+func (x MyID) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.MyName, x.MyProg, x.MyVers, x.MyProc)
+}
+
+func (x *MonID) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.MonName, &x.MyID)
+}
+	
+func (x MonID) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.MonName, x.MyID)
+}
+
+func (x *Mon) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.MonID, &x.Priv)
+}
+	
+func (x Mon) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.MonID, x.Priv)
+}
+
+func (x *StatChge) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.MonName, &x.State)
+}
+	
+func (x StatChge) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.MonName, x.State)
+}
\ No newline at end of file
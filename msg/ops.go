@@ -91,15 +91,19 @@ const (
 	OP4_ILLEGAL = uint32(10044)
 )
 
+const NFS4_PROGRAM = uint32(100003)
+
 const (
 	PROC4_CB_NULL     = uint32(0)
 	PROC4_CB_COMPOUND = uint32(1)
 )
 
 const (
-	OP4_CB_GETATTR = uint32(3)
-	OP4_CB_RECALL  = uint32(4)
-	OP4_CB_ILLEGAL = uint32(10044)
+	OP4_CB_GETATTR  = uint32(3)
+	OP4_CB_RECALL   = uint32(4)
+	OP4_CB_SEQUENCE = uint32(11)
+	OP4_CB_OFFLOAD  = uint32(15)
+	OP4_CB_ILLEGAL  = uint32(10044)
 )
 
 func Proc4Name(proc uint32) string { //nolint:funlen,gocyclo
@@ -315,6 +319,8 @@ const (
 	EXCHGID4_FLAG_UPD_CONFIRMED_REC_A = 0x40000000
 	EXCHGID4_FLAG_CONFIRMED_R         = 0x80000000
 	EXCHGID4_FLAG_USE_NON_PNFS        = 0x00010000
+	EXCHGID4_FLAG_USE_PNFS_MDS        = 0x00020000
+	EXCHGID4_FLAG_USE_PNFS_DS         = 0x00040000
 )
 
 const (
@@ -0,0 +1,127 @@
+//go:generate go run ../xdr/generate/generate.go -- rpcbind.go
+//nolint:staticcheck
+package msg
+
+import "fmt"
+
+// Structures of the portmapper version 2 (rfc1833 section 3) and
+// rpcbind versions 3 and 4 (rfc1833 section 2).
+// The optional heads of the linked lists are defined in lists.go.
+
+const (
+	PMAP_PROGRAM = uint32(100000)
+	PMAP_V2      = uint32(2)
+	RPCBIND_V3   = uint32(3)
+	RPCBIND_V4   = uint32(4)
+
+	PMAP_PORT = 111
+)
+
+const (
+	IPPROTO_TCP = uint32(6)
+	IPPROTO_UDP = uint32(17)
+)
+
+const (
+	PMAPPROC_NULL    = uint32(0)
+	PMAPPROC_SET     = uint32(1)
+	PMAPPROC_UNSET   = uint32(2)
+	PMAPPROC_GETPORT = uint32(3)
+	PMAPPROC_DUMP    = uint32(4)
+	PMAPPROC_CALLIT  = uint32(5)
+)
+
+const (
+	RPCBPROC_NULL        = uint32(0)
+	RPCBPROC_SET         = uint32(1)
+	RPCBPROC_UNSET       = uint32(2)
+	RPCBPROC_GETADDR     = uint32(3)
+	RPCBPROC_DUMP        = uint32(4)
+	RPCBPROC_CALLIT      = uint32(5)
+	RPCBPROC_GETTIME     = uint32(6)
+	RPCBPROC_UADDR2TADDR = uint32(7)
+	RPCBPROC_TADDR2UADDR = uint32(8)
+	RPCBPROC_GETVERSADDR = uint32(9)  // v4 only
+	RPCBPROC_INDIRECT    = uint32(10) // v4 only
+	RPCBPROC_GETADDRLIST = uint32(11) // v4 only
+	RPCBPROC_GETSTAT     = uint32(12) // v4 only
+)
+
+func PmapProcName(vers, proc uint32) string {
+	if vers == PMAP_V2 {
+		switch proc {
+		case PMAPPROC_NULL:
+			return "null"
+		case PMAPPROC_SET:
+			return "set"
+		case PMAPPROC_UNSET:
+			return "unset"
+		case PMAPPROC_GETPORT:
+			return "getport"
+		case PMAPPROC_DUMP:
+			return "dump"
+		case PMAPPROC_CALLIT:
+			return "callit"
+		}
+
+		return fmt.Sprintf("%d", proc)
+	}
+
+	switch proc {
+	case RPCBPROC_NULL:
+		return "null"
+	case RPCBPROC_SET:
+		return "set"
+	case RPCBPROC_UNSET:
+		return "unset"
+	case RPCBPROC_GETADDR:
+		return "getaddr"
+	case RPCBPROC_DUMP:
+		return "dump"
+	case RPCBPROC_CALLIT:
+		return "callit"
+	case RPCBPROC_GETTIME:
+		return "gettime"
+	case RPCBPROC_UADDR2TADDR:
+		return "uaddr2taddr"
+	case RPCBPROC_TADDR2UADDR:
+		return "taddr2uaddr"
+	case RPCBPROC_GETVERSADDR:
+		return "getversaddr"
+	case RPCBPROC_INDIRECT:
+		return "indirect"
+	case RPCBPROC_GETADDRLIST:
+		return "getaddrlist"
+	case RPCBPROC_GETSTAT:
+		return "getstat"
+	}
+
+	return fmt.Sprintf("%d", proc)
+}
+
+// Mapping is a registration of the portmapper version 2.
+type Mapping struct {
+	Prog uint32
+	Vers uint32
+	Prot uint32 // IPPROTO_TCP | IPPROTO_UDP
+	Port uint32
+}
+
+type PmapNode struct {
+	Map  Mapping
+	Next *PmapNode
+}
+
+// Rpcb is a registration of rpcbind versions 3 and 4.
+type Rpcb struct {
+	Prog  uint32
+	Vers  uint32
+	Netid string // e.g. tcp or tcp6
+	Addr  string // universal address
+	Owner string
+}
+
+type RpcbNode struct {
+	Map  Rpcb
+	Next *RpcbNode
+}
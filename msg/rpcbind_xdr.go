@@ -0,0 +1,85 @@
+// This file was automatically generated by go generate; DO NOT EDIT
+package msg
+
+// This file contains specialed Decode and Encode functions
+// to avoid the use of the reflect package while encoding.
+// In principal, everything should work when commenting out
+// this file.
+
+import "github.com/kuleuven/nfs4go/xdr"
+
+func (x *Mapping) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.Prog, &x.Vers, &x.Prot, &x.Port)
+}
+	
+func (x Mapping) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.Prog, x.Vers, x.Prot, x.Port)
+}
+
+func (x *PmapNode) Decode(decoder *xdr.Decoder) error {
+	if err := decoder.DecodeAll(&x.Map); err != nil {
+		return err
+	}
+
+	if ok, err := decoder.Bool(); err != nil || !ok {
+		return err
+	}
+
+	x.Next = new(PmapNode)
+
+	return decoder.Decode(x.Next)
+}
+	
+func (x PmapNode) Encode(encoder *xdr.Encoder) error {
+	if err := encoder.EncodeAll(x.Map); err != nil {
+		return err
+	}
+
+	if x.Next == nil {
+		return encoder.Bool(false)
+	}
+
+	if err := encoder.Bool(true); err != nil {
+		return err
+	}
+
+	return encoder.Encode(*x.Next)
+}
+
+func (x *Rpcb) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.Prog, &x.Vers, &x.Netid, &x.Addr, &x.Owner)
+}
+	
+func (x Rpcb) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.Prog, x.Vers, x.Netid, x.Addr, x.Owner)
+}
+
+func (x *RpcbNode) Decode(decoder *xdr.Decoder) error {
+	if err := decoder.DecodeAll(&x.Map); err != nil {
+		return err
+	}
+
+	if ok, err := decoder.Bool(); err != nil || !ok {
+		return err
+	}
+
+	x.Next = new(RpcbNode)
+
+	return decoder.Decode(x.Next)
+}
+	
+func (x RpcbNode) Encode(encoder *xdr.Encoder) error {
+	if err := encoder.EncodeAll(x.Map); err != nil {
+		return err
+	}
+
+	if x.Next == nil {
+		return encoder.Bool(false)
+	}
+
+	if err := encoder.Bool(true); err != nil {
+		return err
+	}
+
+	return encoder.Encode(*x.Next)
+}
\ No newline at end of file
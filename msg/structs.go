@@ -56,6 +56,9 @@ type SETCLIENTID_CONFIRM4args struct {
 	Verifier uint64
 }
 
+// Maximum size of a file handle
+const NFS4_FHSIZE = 128
+
 type PUTFH4args struct {
 	Fh []byte // nfs_fh4
 }
@@ -370,11 +373,78 @@ type CLOSE4args struct {
 	OpenStateId StateId4
 }
 
+const (
+	READ_LT   = uint32(1)
+	WRITE_LT  = uint32(2)
+	READW_LT  = uint32(3) // blocking read
+	WRITEW_LT = uint32(4) // blocking write
+)
+
+// Length of a lock until the end of the file
+const NFS4_UINT64_MAX = ^uint64(0)
+
+type LockOwner4 struct {
+	ClientId uint64
+	Owner    []byte
+}
+
+type OpenToLockOwner4 struct {
+	OpenSeqId   uint32
+	OpenStateId StateId4
+	LockSeqId   uint32
+	LockOwner   LockOwner4
+}
+
+type ExistLockOwner4 struct {
+	LockStateId StateId4
+	LockSeqId   uint32
+}
+
+type Locker4 struct {
+	NewLockOwner uint32          `xdr:"union"` // bool
+	LockOwner    ExistLockOwner4 // if NewLockOwner == false
+	OpenOwner    OpenToLockOwner4
+}
+
+type LOCK4args struct {
+	LockType uint32 // READ_LT | WRITE_LT | READW_LT | WRITEW_LT
+	Reclaim  bool
+	Offset   uint64
+	Length   uint64
+	Locker   Locker4
+}
+
+type LOCK4denied struct {
+	Offset   uint64
+	Length   uint64
+	LockType uint32
+	Owner    LockOwner4
+}
+
+type LOCKT4args struct {
+	LockType uint32
+	Offset   uint64
+	Length   uint64
+	Owner    LockOwner4
+}
+
+type LOCKU4args struct {
+	LockType    uint32
+	SeqId       uint32
+	LockStateId StateId4
+	Offset      uint64
+	Length      uint64
+}
+
 type SETATTR4args struct {
 	StateId StateId4
 	Attrs   FAttr4
 }
 
+type OPENATTR4args struct {
+	CreateDir bool
+}
+
 type REMOVE4args struct {
 	Target string
 }
@@ -599,3 +669,397 @@ type SEQUENCE4resok struct {
 	SlotIDHighestTarget uint32
 	Flags               uint32
 }
+
+const (
+	NFS4_CONTENT_DATA = uint32(0)
+	NFS4_CONTENT_HOLE = uint32(1)
+)
+
+type SEEK4args struct {
+	StateId StateId4
+	Offset  uint64
+	What    uint32 // NFS4_CONTENT_DATA | NFS4_CONTENT_HOLE
+}
+
+type SEEK4resok struct {
+	Eof    bool
+	Offset uint64
+}
+
+type READ_PLUS4args struct {
+	StateId StateId4
+	Offset  uint64
+	Count   uint32
+}
+
+type Data4 struct {
+	Offset uint64
+	Data   []byte
+}
+
+type DataInfo4 struct {
+	Offset uint64
+	Length uint64
+}
+
+type ReadPlusContent4 struct {
+	What uint32    `xdr:"union"` // NFS4_CONTENT_DATA | NFS4_CONTENT_HOLE
+	Data Data4     // if What == NFS4_CONTENT_DATA
+	Hole DataInfo4 // if What == NFS4_CONTENT_HOLE
+}
+
+type READ_PLUS4resok struct {
+	Eof      bool
+	Contents ReadPlusContents
+}
+
+type ALLOCATE4args struct {
+	StateId StateId4
+	Offset  uint64
+	Length  uint64
+}
+
+type DEALLOCATE4args struct {
+	StateId StateId4
+	Offset  uint64
+	Length  uint64
+}
+
+const (
+	NL4_NAME    = uint32(1)
+	NL4_URL     = uint32(2)
+	NL4_NETADDR = uint32(3)
+)
+
+type NetAddr4 struct {
+	Netid string
+	Addr  string
+}
+
+type Netloc4 struct {
+	Type    uint32   `xdr:"union"` // NL4_NAME | NL4_URL | NL4_NETADDR
+	Void    Void     // not used
+	Name    string   // if Type == NL4_NAME
+	URL     string   // if Type == NL4_URL
+	NetAddr NetAddr4 // if Type == NL4_NETADDR
+}
+
+type COPY4args struct {
+	SrcStateId   StateId4
+	DstStateId   StateId4
+	SrcOffset    uint64
+	DstOffset    uint64
+	Count        uint64
+	Consecutive  bool
+	Synchronous  bool
+	SourceServer Netlocs
+}
+
+type WriteResponse4 struct {
+	CallbackID *StateId4 // stateid4 wr_callback_id<1>
+	Count      uint64
+	Committed  uint32 // USTABLE4 | DATA_SYNC4 | FILE_SYNC4
+	WriteVerf  uint64
+}
+
+type CopyRequirements4 struct {
+	Consecutive bool
+	Synchronous bool
+}
+
+type COPY4resok struct {
+	Response     WriteResponse4
+	Requirements CopyRequirements4
+}
+
+type OFFLOAD_STATUS4resok struct {
+	Count    uint64
+	Complete *uint32 // nfsstat4 osr_complete<1>
+}
+
+const (
+	IO_ADVISE4_NORMAL                 = 0
+	IO_ADVISE4_SEQUENTIAL             = 1
+	IO_ADVISE4_SEQUENTIAL_BACKWARDS   = 2
+	IO_ADVISE4_RANDOM                 = 3
+	IO_ADVISE4_WILLNEED               = 4
+	IO_ADVISE4_WILLNEED_OPPORTUNISTIC = 5
+	IO_ADVISE4_DONTNEED               = 6
+	IO_ADVISE4_NOREUSE                = 7
+	IO_ADVISE4_READ                   = 8
+	IO_ADVISE4_WRITE                  = 9
+	IO_ADVISE4_INIT_PROXIMITY         = 10
+)
+
+type IO_ADVISE4args struct {
+	StateId StateId4
+	Offset  uint64
+	Count   uint64
+	Hints   []uint32 // bitmap4 of IO_ADVISE4_*
+}
+
+type IO_ADVISE4resok struct {
+	Hints []uint32 // bitmap4 of IO_ADVISE4_*
+}
+
+type AppDataBlock4 struct {
+	Offset         uint64
+	BlockSize      uint64
+	BlockCount     uint64
+	RelOffBlockNum uint64
+	BlockNum       uint32
+	RelOffPattern  uint64
+	Pattern        []byte
+}
+
+type WRITE_SAME4args struct {
+	StateId StateId4
+	Stable  uint32 // USTABLE4 | DATA_SYNC4 | FILE_SYNC4
+	Adb     AppDataBlock4
+}
+
+type COPY_NOTIFY4args struct {
+	SrcStateId        StateId4
+	DestinationServer Netloc4
+}
+
+type COPY_NOTIFY4resok struct {
+	LeaseTime    NfsTime4
+	StateId      StateId4
+	SourceServer Netlocs
+}
+
+type CB_SEQUENCE4args struct {
+	SessionID          [16]byte
+	SequenceID         uint32
+	SlotID             uint32
+	HighestSlotID      uint32
+	CacheThis          bool
+	ReferringCallLists ReferringCallLists
+}
+
+type ReferringCall4 struct {
+	SequenceID uint32
+	SlotID     uint32
+}
+
+type ReferringCallList4 struct {
+	SessionID [16]byte
+	Calls     ReferringCalls
+}
+
+type CB_OFFLOAD4args struct {
+	Fh      []byte
+	StateId StateId4
+	Info    OffloadInfo4
+}
+
+type CLONE4args struct {
+	SrcStateId StateId4
+	DstStateId StateId4
+	SrcOffset  uint64
+	DstOffset  uint64
+	Count      uint64
+}
+
+const (
+	LAYOUT4_FLEX_FILES = uint32(4)
+
+	LAYOUTIOMODE4_READ = uint32(1)
+	LAYOUTIOMODE4_RW   = uint32(2)
+	LAYOUTIOMODE4_ANY  = uint32(3)
+
+	LAYOUTRETURN4_FILE = uint32(1)
+	LAYOUTRETURN4_FSID = uint32(2)
+	LAYOUTRETURN4_ALL  = uint32(3)
+
+	NFS4_MAXFILELEN = uint64(0xffffffffffffffff)
+)
+
+type LAYOUTGET4args struct {
+	SignalLayoutAvail bool
+	LayoutType        uint32
+	IoMode            uint32 // LAYOUTIOMODE4_*
+	Offset            uint64
+	Length            uint64
+	MinLength         uint64
+	StateId           StateId4
+	MaxCount          uint32
+}
+
+type LayoutContent4 struct {
+	Type uint32
+	Body []byte
+}
+
+type Layout4 struct {
+	Offset  uint64
+	Length  uint64
+	IoMode  uint32
+	Content LayoutContent4
+}
+
+type LAYOUTGET4resok struct {
+	ReturnOnClose bool
+	StateId       StateId4
+	Layout        []Layout4
+}
+
+type LayoutReturnFile4 struct {
+	Offset  uint64
+	Length  uint64
+	StateId StateId4
+	Body    []byte
+}
+
+type LayoutReturn4 struct {
+	ReturnType uint32            `xdr:"union"` // LAYOUTRETURN4_*
+	Void       Void              // not used
+	File       LayoutReturnFile4 // if ReturnType == LAYOUTRETURN4_FILE
+	VoidFsid   Void              // if ReturnType == LAYOUTRETURN4_FSID
+	VoidAll    Void              // if ReturnType == LAYOUTRETURN4_ALL
+}
+
+type LAYOUTRETURN4args struct {
+	Reclaim    bool
+	LayoutType uint32
+	IoMode     uint32
+	Return     LayoutReturn4
+}
+
+type LAYOUTRETURN4resok struct {
+	StateId *StateId4 // set if the client still holds layouts for the file
+}
+
+type LayoutUpdate4 struct {
+	Type uint32
+	Body []byte
+}
+
+type LAYOUTCOMMIT4args struct {
+	Offset          uint64
+	Length          uint64
+	Reclaim         bool
+	StateId         StateId4
+	LastWriteOffset *uint64
+	TimeModify      *NfsTime4
+	Update          LayoutUpdate4
+}
+
+type LAYOUTCOMMIT4resok struct {
+	NewSize *uint64
+}
+
+type GETDEVICEINFO4args struct {
+	DeviceID    [16]byte
+	LayoutType  uint32
+	MaxCount    uint32
+	NotifyTypes []uint32 // bitmap4
+}
+
+type DeviceAddr4 struct {
+	LayoutType uint32
+	Body       []byte
+}
+
+type GETDEVICEINFO4resok struct {
+	DeviceAddr   DeviceAddr4
+	Notification []uint32 // bitmap4
+}
+
+type GETDEVICELIST4args struct {
+	LayoutType uint32
+	MaxDevices uint32
+	Cookie     uint64
+	CookieVerf uint64
+}
+
+type GETDEVICELIST4resok struct {
+	Cookie     uint64
+	CookieVerf uint64
+	DeviceIDs  [][16]byte
+	Eof        bool
+}
+
+type DeviceError4 struct {
+	DeviceID [16]byte
+	Status   uint32
+	OpNum    uint32
+}
+
+type LAYOUTERROR4args struct {
+	Offset  uint64
+	Length  uint64
+	StateId StateId4
+	Errors  []DeviceError4
+}
+
+type IoInfo4 struct {
+	Count uint64
+	Bytes uint64
+}
+
+type LAYOUTSTATS4args struct {
+	Offset   uint64
+	Length   uint64
+	StateId  StateId4
+	Read     IoInfo4
+	Write    IoInfo4
+	DeviceID [16]byte
+	Update   LayoutUpdate4
+}
+
+// Flexible files layout, RFC 8435
+
+const (
+	FF_FLAGS_NO_LAYOUTCOMMIT  = uint32(0x00000001)
+	FF_FLAGS_NO_IO_THRU_MDS   = uint32(0x00000002)
+	FF_FLAGS_NO_READ_IO       = uint32(0x00000004)
+	FF_FLAGS_WRITE_ONE_MIRROR = uint32(0x00000008)
+)
+
+type FFDataServer4 struct {
+	DeviceID   [16]byte
+	Efficiency uint32
+	StateId    StateId4
+	FhVers     [][]byte // nfs_fh4
+	User       string
+	Group      string
+}
+
+type FFMirror4 struct {
+	DataServers []FFDataServer4
+}
+
+type FFLayout4 struct {
+	StripeUnit       uint64
+	Mirrors          []FFMirror4
+	Flags            uint32 // FF_FLAGS_*
+	StatsCollectHint uint32
+}
+
+type FFDeviceVersions4 struct {
+	Version        uint32
+	MinorVersion   uint32
+	RSize          uint32
+	WSize          uint32
+	TightlyCoupled bool
+}
+
+type FFDeviceAddr4 struct {
+	NetAddrs []NetAddr4
+	Versions []FFDeviceVersions4
+}
+
+type FFIoErr4 struct {
+	Offset  uint64
+	Length  uint64
+	StateId StateId4
+	Errors  []DeviceError4
+}
+
+// FFLayoutReturn4 is the body of LAYOUTRETURN for the flexible files layout.
+// The I/O statistics that follow the errors are not decoded.
+type FFLayoutReturn4 struct {
+	IoErrs []FFIoErr4
+}
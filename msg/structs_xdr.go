@@ -514,6 +514,70 @@ func (x CLOSE4args) Encode(encoder *xdr.Encoder) error {
 	return encoder.EncodeAll(x.SeqId, x.OpenStateId)
 }
 
+func (x *LockOwner4) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.ClientId, &x.Owner)
+}
+	
+func (x LockOwner4) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.ClientId, x.Owner)
+}
+
+func (x *OpenToLockOwner4) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.OpenSeqId, &x.OpenStateId, &x.LockSeqId, &x.LockOwner)
+}
+	
+func (x OpenToLockOwner4) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.OpenSeqId, x.OpenStateId, x.LockSeqId, x.LockOwner)
+}
+
+func (x *ExistLockOwner4) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.LockStateId, &x.LockSeqId)
+}
+	
+func (x ExistLockOwner4) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.LockStateId, x.LockSeqId)
+}
+
+func (x *Locker4) Decode(decoder *xdr.Decoder) error {
+	return decoder.Union(&x.NewLockOwner, &x.LockOwner, &x.OpenOwner)
+}
+	
+func (x Locker4) Encode(encoder *xdr.Encoder) error {
+	return encoder.Union(x.NewLockOwner, x.LockOwner, x.OpenOwner)
+}
+
+func (x *LOCK4args) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.LockType, &x.Reclaim, &x.Offset, &x.Length, &x.Locker)
+}
+	
+func (x LOCK4args) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.LockType, x.Reclaim, x.Offset, x.Length, x.Locker)
+}
+
+func (x *LOCK4denied) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.Offset, &x.Length, &x.LockType, &x.Owner)
+}
+	
+func (x LOCK4denied) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.Offset, x.Length, x.LockType, x.Owner)
+}
+
+func (x *LOCKT4args) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.LockType, &x.Offset, &x.Length, &x.Owner)
+}
+	
+func (x LOCKT4args) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.LockType, x.Offset, x.Length, x.Owner)
+}
+
+func (x *LOCKU4args) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.LockType, &x.SeqId, &x.LockStateId, &x.Offset, &x.Length)
+}
+	
+func (x LOCKU4args) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.LockType, x.SeqId, x.LockStateId, x.Offset, x.Length)
+}
+
 func (x *SETATTR4args) Decode(decoder *xdr.Decoder) error {
 	return decoder.DecodeAll(&x.StateId, &x.Attrs)
 }
@@ -522,6 +586,14 @@ func (x SETATTR4args) Encode(encoder *xdr.Encoder) error {
 	return encoder.EncodeAll(x.StateId, x.Attrs)
 }
 
+func (x *OPENATTR4args) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.CreateDir)
+}
+	
+func (x OPENATTR4args) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.CreateDir)
+}
+
 func (x *REMOVE4args) Decode(decoder *xdr.Decoder) error {
 	return decoder.DecodeAll(&x.Target)
 }
@@ -828,4 +900,462 @@ func (x *SEQUENCE4resok) Decode(decoder *xdr.Decoder) error {
 	
 func (x SEQUENCE4resok) Encode(encoder *xdr.Encoder) error {
 	return encoder.EncodeAll(x.SessionID, x.SequenceID, x.SlotID, x.SlotIDHighest, x.SlotIDHighestTarget, x.Flags)
+}
+
+func (x *SEEK4args) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.StateId, &x.Offset, &x.What)
+}
+	
+func (x SEEK4args) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.StateId, x.Offset, x.What)
+}
+
+func (x *SEEK4resok) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.Eof, &x.Offset)
+}
+	
+func (x SEEK4resok) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.Eof, x.Offset)
+}
+
+func (x *READ_PLUS4args) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.StateId, &x.Offset, &x.Count)
+}
+	
+func (x READ_PLUS4args) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.StateId, x.Offset, x.Count)
+}
+
+func (x *Data4) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.Offset, &x.Data)
+}
+	
+func (x Data4) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.Offset, x.Data)
+}
+
+func (x *DataInfo4) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.Offset, &x.Length)
+}
+	
+func (x DataInfo4) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.Offset, x.Length)
+}
+
+func (x *ReadPlusContent4) Decode(decoder *xdr.Decoder) error {
+	return decoder.Union(&x.What, &x.Data, &x.Hole)
+}
+	
+func (x ReadPlusContent4) Encode(encoder *xdr.Encoder) error {
+	return encoder.Union(x.What, x.Data, x.Hole)
+}
+
+func (x *READ_PLUS4resok) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.Eof, &x.Contents)
+}
+	
+func (x READ_PLUS4resok) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.Eof, x.Contents)
+}
+
+func (x *ALLOCATE4args) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.StateId, &x.Offset, &x.Length)
+}
+	
+func (x ALLOCATE4args) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.StateId, x.Offset, x.Length)
+}
+
+func (x *DEALLOCATE4args) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.StateId, &x.Offset, &x.Length)
+}
+	
+func (x DEALLOCATE4args) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.StateId, x.Offset, x.Length)
+}
+
+func (x *NetAddr4) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.Netid, &x.Addr)
+}
+	
+func (x NetAddr4) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.Netid, x.Addr)
+}
+
+func (x *Netloc4) Decode(decoder *xdr.Decoder) error {
+	return decoder.Union(&x.Type, &x.Void, &x.Name, &x.URL, &x.NetAddr)
+}
+	
+func (x Netloc4) Encode(encoder *xdr.Encoder) error {
+	return encoder.Union(x.Type, x.Void, x.Name, x.URL, x.NetAddr)
+}
+
+func (x *COPY4args) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.SrcStateId, &x.DstStateId, &x.SrcOffset, &x.DstOffset, &x.Count, &x.Consecutive, &x.Synchronous, &x.SourceServer)
+}
+	
+func (x COPY4args) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.SrcStateId, x.DstStateId, x.SrcOffset, x.DstOffset, x.Count, x.Consecutive, x.Synchronous, x.SourceServer)
+}
+
+func (x *WriteResponse4) Decode(decoder *xdr.Decoder) error {
+	if ok, err := decoder.Bool(); err != nil {
+		return err
+	} else if ok {
+	 	x.CallbackID = new(StateId4)
+
+		if err := decoder.Decode(x.CallbackID); err != nil {
+			return err
+		}
+	}
+
+	return decoder.DecodeAll(&x.Count, &x.Committed, &x.WriteVerf)
+}
+	
+func (x WriteResponse4) Encode(encoder *xdr.Encoder) error {
+	ok := x.CallbackID != nil
+
+	if err := encoder.Bool(ok); err != nil {
+		return err
+	}
+
+	if ok {
+		if err := encoder.Encode(*x.CallbackID); err != nil {
+			return err
+		}
+	}
+
+	return encoder.EncodeAll(x.Count, x.Committed, x.WriteVerf)
+}
+
+func (x *CopyRequirements4) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.Consecutive, &x.Synchronous)
+}
+	
+func (x CopyRequirements4) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.Consecutive, x.Synchronous)
+}
+
+func (x *COPY4resok) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.Response, &x.Requirements)
+}
+	
+func (x COPY4resok) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.Response, x.Requirements)
+}
+
+func (x *OFFLOAD_STATUS4resok) Decode(decoder *xdr.Decoder) error {
+	if err := decoder.DecodeAll(&x.Count); err != nil {
+		return err
+	}
+
+	if ok, err := decoder.Bool(); err != nil || !ok {
+		return err
+	}
+
+	x.Complete = new(uint32)
+
+	return decoder.Decode(x.Complete)
+}
+	
+func (x OFFLOAD_STATUS4resok) Encode(encoder *xdr.Encoder) error {
+	if err := encoder.EncodeAll(x.Count); err != nil {
+		return err
+	}
+
+	if x.Complete == nil {
+		return encoder.Bool(false)
+	}
+
+	if err := encoder.Bool(true); err != nil {
+		return err
+	}
+
+	return encoder.Encode(*x.Complete)
+}
+
+func (x *IO_ADVISE4args) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.StateId, &x.Offset, &x.Count, &x.Hints)
+}
+	
+func (x IO_ADVISE4args) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.StateId, x.Offset, x.Count, x.Hints)
+}
+
+func (x *IO_ADVISE4resok) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.Hints)
+}
+	
+func (x IO_ADVISE4resok) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.Hints)
+}
+
+func (x *AppDataBlock4) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.Offset, &x.BlockSize, &x.BlockCount, &x.RelOffBlockNum, &x.BlockNum, &x.RelOffPattern, &x.Pattern)
+}
+	
+func (x AppDataBlock4) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.Offset, x.BlockSize, x.BlockCount, x.RelOffBlockNum, x.BlockNum, x.RelOffPattern, x.Pattern)
+}
+
+func (x *WRITE_SAME4args) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.StateId, &x.Stable, &x.Adb)
+}
+	
+func (x WRITE_SAME4args) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.StateId, x.Stable, x.Adb)
+}
+
+func (x *COPY_NOTIFY4args) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.SrcStateId, &x.DestinationServer)
+}
+	
+func (x COPY_NOTIFY4args) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.SrcStateId, x.DestinationServer)
+}
+
+func (x *COPY_NOTIFY4resok) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.LeaseTime, &x.StateId, &x.SourceServer)
+}
+	
+func (x COPY_NOTIFY4resok) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.LeaseTime, x.StateId, x.SourceServer)
+}
+
+func (x *CB_SEQUENCE4args) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.SessionID, &x.SequenceID, &x.SlotID, &x.HighestSlotID, &x.CacheThis, &x.ReferringCallLists)
+}
+	
+func (x CB_SEQUENCE4args) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.SessionID, x.SequenceID, x.SlotID, x.HighestSlotID, x.CacheThis, x.ReferringCallLists)
+}
+
+func (x *ReferringCall4) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.SequenceID, &x.SlotID)
+}
+	
+func (x ReferringCall4) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.SequenceID, x.SlotID)
+}
+
+func (x *ReferringCallList4) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.SessionID, &x.Calls)
+}
+	
+func (x ReferringCallList4) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.SessionID, x.Calls)
+}
+
+func (x *CB_OFFLOAD4args) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.Fh, &x.StateId, &x.Info)
+}
+	
+func (x CB_OFFLOAD4args) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.Fh, x.StateId, x.Info)
+}
+
+func (x *CLONE4args) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.SrcStateId, &x.DstStateId, &x.SrcOffset, &x.DstOffset, &x.Count)
+}
+	
+func (x CLONE4args) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.SrcStateId, x.DstStateId, x.SrcOffset, x.DstOffset, x.Count)
+}
+
+func (x *LAYOUTGET4args) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.SignalLayoutAvail, &x.LayoutType, &x.IoMode, &x.Offset, &x.Length, &x.MinLength, &x.StateId, &x.MaxCount)
+}
+	
+func (x LAYOUTGET4args) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.SignalLayoutAvail, x.LayoutType, x.IoMode, x.Offset, x.Length, x.MinLength, x.StateId, x.MaxCount)
+}
+
+func (x *LayoutContent4) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.Type, &x.Body)
+}
+	
+func (x LayoutContent4) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.Type, x.Body)
+}
+
+func (x *Layout4) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.Offset, &x.Length, &x.IoMode, &x.Content)
+}
+	
+func (x Layout4) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.Offset, x.Length, x.IoMode, x.Content)
+}
+
+func (x *LAYOUTGET4resok) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.ReturnOnClose, &x.StateId, &x.Layout)
+}
+	
+func (x LAYOUTGET4resok) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.ReturnOnClose, x.StateId, x.Layout)
+}
+
+func (x *LayoutReturnFile4) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.Offset, &x.Length, &x.StateId, &x.Body)
+}
+	
+func (x LayoutReturnFile4) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.Offset, x.Length, x.StateId, x.Body)
+}
+
+func (x *LayoutReturn4) Decode(decoder *xdr.Decoder) error {
+	return decoder.Union(&x.ReturnType, &x.Void, &x.File, &x.VoidFsid, &x.VoidAll)
+}
+	
+func (x LayoutReturn4) Encode(encoder *xdr.Encoder) error {
+	return encoder.Union(x.ReturnType, x.Void, x.File, x.VoidFsid, x.VoidAll)
+}
+
+func (x *LAYOUTRETURN4args) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.Reclaim, &x.LayoutType, &x.IoMode, &x.Return)
+}
+	
+func (x LAYOUTRETURN4args) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.Reclaim, x.LayoutType, x.IoMode, x.Return)
+}
+
+
+
+func (x *LayoutUpdate4) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.Type, &x.Body)
+}
+	
+func (x LayoutUpdate4) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.Type, x.Body)
+}
+
+
+
+
+
+func (x *GETDEVICEINFO4args) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.DeviceID, &x.LayoutType, &x.MaxCount, &x.NotifyTypes)
+}
+	
+func (x GETDEVICEINFO4args) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.DeviceID, x.LayoutType, x.MaxCount, x.NotifyTypes)
+}
+
+func (x *DeviceAddr4) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.LayoutType, &x.Body)
+}
+	
+func (x DeviceAddr4) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.LayoutType, x.Body)
+}
+
+func (x *GETDEVICEINFO4resok) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.DeviceAddr, &x.Notification)
+}
+	
+func (x GETDEVICEINFO4resok) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.DeviceAddr, x.Notification)
+}
+
+func (x *GETDEVICELIST4args) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.LayoutType, &x.MaxDevices, &x.Cookie, &x.CookieVerf)
+}
+	
+func (x GETDEVICELIST4args) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.LayoutType, x.MaxDevices, x.Cookie, x.CookieVerf)
+}
+
+func (x *GETDEVICELIST4resok) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.Cookie, &x.CookieVerf, &x.DeviceIDs, &x.Eof)
+}
+	
+func (x GETDEVICELIST4resok) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.Cookie, x.CookieVerf, x.DeviceIDs, x.Eof)
+}
+
+func (x *DeviceError4) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.DeviceID, &x.Status, &x.OpNum)
+}
+	
+func (x DeviceError4) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.DeviceID, x.Status, x.OpNum)
+}
+
+func (x *LAYOUTERROR4args) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.Offset, &x.Length, &x.StateId, &x.Errors)
+}
+	
+func (x LAYOUTERROR4args) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.Offset, x.Length, x.StateId, x.Errors)
+}
+
+func (x *IoInfo4) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.Count, &x.Bytes)
+}
+	
+func (x IoInfo4) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.Count, x.Bytes)
+}
+
+func (x *LAYOUTSTATS4args) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.Offset, &x.Length, &x.StateId, &x.Read, &x.Write, &x.DeviceID, &x.Update)
+}
+	
+func (x LAYOUTSTATS4args) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.Offset, x.Length, x.StateId, x.Read, x.Write, x.DeviceID, x.Update)
+}
+
+func (x *FFDataServer4) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.DeviceID, &x.Efficiency, &x.StateId, &x.FhVers, &x.User, &x.Group)
+}
+	
+func (x FFDataServer4) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.DeviceID, x.Efficiency, x.StateId, x.FhVers, x.User, x.Group)
+}
+
+func (x *FFMirror4) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.DataServers)
+}
+	
+func (x FFMirror4) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.DataServers)
+}
+
+func (x *FFLayout4) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.StripeUnit, &x.Mirrors, &x.Flags, &x.StatsCollectHint)
+}
+	
+func (x FFLayout4) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.StripeUnit, x.Mirrors, x.Flags, x.StatsCollectHint)
+}
+
+func (x *FFDeviceVersions4) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.Version, &x.MinorVersion, &x.RSize, &x.WSize, &x.TightlyCoupled)
+}
+	
+func (x FFDeviceVersions4) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.Version, x.MinorVersion, x.RSize, x.WSize, x.TightlyCoupled)
+}
+
+func (x *FFDeviceAddr4) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.NetAddrs, &x.Versions)
+}
+	
+func (x FFDeviceAddr4) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.NetAddrs, x.Versions)
+}
+
+func (x *FFIoErr4) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.Offset, &x.Length, &x.StateId, &x.Errors)
+}
+	
+func (x FFIoErr4) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.Offset, x.Length, x.StateId, x.Errors)
+}
+
+func (x *FFLayoutReturn4) Decode(decoder *xdr.Decoder) error {
+	return decoder.DecodeAll(&x.IoErrs)
+}
+	
+func (x FFLayoutReturn4) Encode(encoder *xdr.Encoder) error {
+	return encoder.EncodeAll(x.IoErrs)
 }
\ No newline at end of file
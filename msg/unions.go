@@ -0,0 +1,37 @@
+package msg
+
+import "github.com/kuleuven/nfs4go/xdr"
+
+// The unions in this file have a default arm, which cannot be
+// expressed with the `xdr:"union"` tag, so they are encoded by hand.
+
+// OffloadInfo4 is the result of an asynchronous copy, as sent in CB_OFFLOAD.
+type OffloadInfo4 struct {
+	Status      uint32
+	Response    WriteResponse4 // if Status == NFS4_OK
+	BytesCopied uint64         // otherwise
+}
+
+func (x *OffloadInfo4) Decode(decoder *xdr.Decoder) error {
+	if err := decoder.Decode(&x.Status); err != nil {
+		return err
+	}
+
+	if x.Status == NFS4_OK {
+		return decoder.Decode(&x.Response)
+	}
+
+	return decoder.Decode(&x.BytesCopied)
+}
+
+func (x OffloadInfo4) Encode(encoder *xdr.Encoder) error {
+	if err := encoder.Uint32(x.Status); err != nil {
+		return err
+	}
+
+	if x.Status == NFS4_OK {
+		return encoder.Encode(x.Response)
+	}
+
+	return encoder.Uint64(x.BytesCopied)
+}
@@ -0,0 +1,274 @@
+package nfs4go
+
+import (
+	"path"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/kuleuven/nfs4go/auth"
+	"github.com/kuleuven/nfs4go/bufpool"
+	"github.com/kuleuven/nfs4go/handle"
+	"github.com/kuleuven/nfs4go/msg"
+	"github.com/kuleuven/nfs4go/worker"
+	"github.com/kuleuven/nfs4go/xdr"
+	"github.com/sirupsen/logrus"
+)
+
+// MuxMount serves the MOUNT protocol version 3 (rfc1813 appendix I), which NFSv3 clients
+// use to obtain the root file handle of the export.
+type MuxMount struct {
+	Logger *logrus.Entry
+	Signer *handle.Signer // Signs file handles sent to clients, if set
+	Mounts *Mounts        // Mounted directories, reported by DUMP
+	Export string         // Path of the export
+	Host   string         // Address of the client
+
+	// Retrieve a FS for the specified creds and sessionID, as for Muxv3.
+	FS func(creds *auth.Creds, sessionID [16]byte) *worker.Worker
+}
+
+func (x *MuxMount) Handle(request Request, response chan<- Response) {
+	reply, data, err := x.HandleProc(request.Header, request.Data)
+	if err != nil {
+		x.Logger.Error(err)
+	}
+
+	response <- Response{
+		Reply: reply,
+		Data:  data,
+		Error: err,
+	}
+}
+
+func (x *MuxMount) HandleProc(header *msg.RPCMsgCall, data Bytes) (*msg.RPCMsgReply, Bytes, error) {
+	defer data.Discard()
+
+	reply := &msg.RPCMsgReply{
+		Xid:       header.Xid,
+		MsgType:   msg.RPC_REPLY,
+		ReplyStat: msg.MSG_ACCEPTED,
+	}
+
+	resp := msg.Auth{
+		Flavor: msg.AUTH_FLAVOR_NULL,
+		Body:   []byte{},
+	}
+
+	dataOut := bufpool.Get()
+
+	if header.Vers != msg.MOUNT_V3 {
+		return reply, dataOut, xdr.NewEncoder(dataOut).EncodeAll(resp, msg.ACCEPT_PROG_MISMATCH, msg.MOUNT_V3, msg.MOUNT_V3)
+	}
+
+	x.Logger.Tracef("MOUNT %s", strings.ToUpper(msg.MountProc3Name(header.Proc)))
+
+	var result []interface{}
+
+	switch header.Proc {
+	case msg.MOUNTPROC3_NULL:
+	case msg.MOUNTPROC3_MNT:
+		// Only MNT accesses the file system and needs credentials
+		authResp, creds, err := auth.Authenticate(header.Cred, header.Verf)
+		if authErr, ok := err.(*auth.AuthError); ok {
+			dataOut.Reset()
+
+			reply.ReplyStat = msg.MSG_DENIED
+
+			return reply, dataOut, xdr.NewEncoder(dataOut).EncodeAll(msg.REJECT_AUTH_ERROR, authErr.Code)
+		} else if err != nil {
+			dataOut.Discard()
+
+			return nil, nil, err
+		}
+
+		var dirPath string
+
+		if err = xdr.NewDecoder(data).Decode(&dirPath); err != nil {
+			return garbageArgs(reply, resp, dataOut)
+		}
+
+		resp = authResp
+		result = x.Mnt(creds, dirPath)
+	case msg.MOUNTPROC3_DUMP:
+		result = []interface{}{x.Mounts.List()}
+	case msg.MOUNTPROC3_UMNT:
+		var dirPath string
+
+		if err := xdr.NewDecoder(data).Decode(&dirPath); err != nil {
+			return garbageArgs(reply, resp, dataOut)
+		}
+
+		x.Mounts.Remove(x.Host, dirPath)
+	case msg.MOUNTPROC3_UMNTALL:
+		x.Mounts.RemoveAll(x.Host)
+	case msg.MOUNTPROC3_EXPORT:
+		result = []interface{}{msg.Exports3{
+			Entries: &msg.ExportNode3{
+				Dir: x.Export,
+			},
+		}}
+	default:
+		return reply, dataOut, xdr.NewEncoder(dataOut).EncodeAll(resp, msg.ACCEPT_PROC_UNAVAIL)
+	}
+
+	if err := xdr.NewEncoder(dataOut).EncodeAll(append([]interface{}{resp, msg.ACCEPT_SUCCESS}, result...)...); err != nil {
+		dataOut.Discard()
+
+		return nil, nil, err
+	}
+
+	return reply, dataOut, nil
+}
+
+// Mnt returns the result of MNT for the given directory, which is the export or a directory below it.
+func (x *MuxMount) Mnt(creds *auth.Creds, dirPath string) []interface{} {
+	if len(dirPath) > msg.MNTPATHLEN {
+		return []interface{}{msg.MNT3ERR_NAMETOOLONG}
+	}
+
+	p, ok := exportedPath(x.Export, dirPath)
+	if !ok {
+		x.Logger.Warnf("refusing to mount %s: not exported", dirPath)
+
+		return []interface{}{msg.MNT3ERR_ACCES}
+	}
+
+	fs := x.FS(creds, [16]byte{})
+
+	defer fs.Close()
+
+	fi, err := fs.Stat(p)
+	if err == nil && !fi.IsDir() {
+		return []interface{}{msg.MNT3ERR_NOTDIR}
+	}
+
+	var h []byte
+
+	if err == nil {
+		h, err = fs.Handle(p)
+	}
+
+	if err != nil {
+		DiscardOnServerFault(fs, err)
+
+		x.Logger.Warnf("failed to mount %s: %v", dirPath, err)
+
+		return []interface{}{msg.Err2StatusMnt(err)}
+	}
+
+	fh := x.Signer.Wrap(h)
+
+	if len(fh) > msg.NFS3_FHSIZE {
+		x.Logger.Warnf("file handle of %d bytes is too long for nfs v3", len(fh))
+
+		return []interface{}{msg.MNT3ERR_SERVERFAULT}
+	}
+
+	x.Mounts.Add(x.Host, dirPath)
+
+	return []interface{}{
+		msg.MNT3_OK,
+		msg.MountRes3ok{
+			FHandle:     fh,
+			AuthFlavors: []uint32{msg.AUTH_FLAVOR_UNIX},
+		},
+	}
+}
+
+// exportedPath returns the path in the file system of a directory below the export.
+func exportedPath(export, dirPath string) (string, bool) {
+	dirPath = path.Clean("/" + dirPath)
+
+	if export == "/" {
+		return dirPath, true
+	}
+
+	if dirPath == export {
+		return "/", true
+	}
+
+	rel, ok := strings.CutPrefix(dirPath, export+"/")
+
+	return "/" + rel, ok
+}
+
+// Mounts keeps track of the directories that clients mounted with the MOUNT protocol.
+// As for the linux server, the list is informational: clients that do not unmount
+// remain listed, and NFSv3 requests are served regardless of the list.
+type Mounts struct {
+	mounts map[mountEntry]struct{}
+	sync.Mutex
+}
+
+type mountEntry struct {
+	host string
+	dir  string
+}
+
+func NewMounts() *Mounts {
+	return &Mounts{
+		mounts: map[mountEntry]struct{}{},
+	}
+}
+
+// Add records that host mounted dir.
+func (m *Mounts) Add(host, dir string) {
+	m.Lock()
+	defer m.Unlock()
+
+	m.mounts[mountEntry{host, dir}] = struct{}{}
+}
+
+// Remove removes a mount of host.
+func (m *Mounts) Remove(host, dir string) {
+	m.Lock()
+	defer m.Unlock()
+
+	delete(m.mounts, mountEntry{host, dir})
+}
+
+// RemoveAll removes all mounts of host.
+func (m *Mounts) RemoveAll(host string) {
+	m.Lock()
+	defer m.Unlock()
+
+	for entry := range m.mounts {
+		if entry.host == host {
+			delete(m.mounts, entry)
+		}
+	}
+}
+
+// List returns the mounts sorted by host and directory.
+func (m *Mounts) List() msg.MountList3 {
+	m.Lock()
+
+	entries := make([]mountEntry, 0, len(m.mounts))
+
+	for entry := range m.mounts {
+		entries = append(entries, entry)
+	}
+
+	m.Unlock()
+
+	slices.SortFunc(entries, func(a, b mountEntry) int {
+		if c := strings.Compare(a.host, b.host); c != 0 {
+			return c
+		}
+
+		return strings.Compare(a.dir, b.dir)
+	})
+
+	var list msg.MountList3
+
+	for i := len(entries) - 1; i >= 0; i-- {
+		list.Entries = &msg.MountBody3{
+			Hostname:  entries[i].host,
+			Directory: entries[i].dir,
+			Next:      list.Entries,
+		}
+	}
+
+	return list
+}
@@ -0,0 +1,577 @@
+package nfs4go
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/kuleuven/nfs4go/auth"
+	"github.com/kuleuven/nfs4go/bufpool"
+	"github.com/kuleuven/nfs4go/clock"
+	"github.com/kuleuven/nfs4go/handle"
+	"github.com/kuleuven/nfs4go/locks"
+	"github.com/kuleuven/nfs4go/msg"
+	"github.com/kuleuven/nfs4go/xdr"
+	"github.com/sirupsen/logrus"
+)
+
+// MuxNLM serves the Network Lock Manager version 4 (rfc1813 appendix II), with which NFSv3
+// clients lock files. The locks are kept in the same lock manager as those of NFSv4 clients,
+// so that both see each other's locks. The asynchronous _MSG and _RES procedures are not supported.
+type MuxNLM struct {
+	Logger  *logrus.Entry
+	Signer  *handle.Signer // Signs file handles sent to clients, if set
+	Locks   *locks.Manager
+	Monitor *Monitor // Detects reboots of clients
+	Host    string   // Address of the client
+}
+
+func (x *MuxNLM) Handle(request Request, response chan<- Response) {
+	reply, data, err := x.HandleProc(request.Header, request.Data)
+	if err != nil {
+		x.Logger.Error(err)
+	}
+
+	response <- Response{
+		Reply: reply,
+		Data:  data,
+		Error: err,
+	}
+}
+
+func (x *MuxNLM) HandleProc(header *msg.RPCMsgCall, data Bytes) (*msg.RPCMsgReply, Bytes, error) {
+	defer data.Discard()
+
+	reply := &msg.RPCMsgReply{
+		Xid:       header.Xid,
+		MsgType:   msg.RPC_REPLY,
+		ReplyStat: msg.MSG_ACCEPTED,
+	}
+
+	resp := msg.Auth{
+		Flavor: msg.AUTH_FLAVOR_NULL,
+		Body:   []byte{},
+	}
+
+	dataOut := bufpool.Get()
+
+	if header.Vers != msg.NLM_V4 {
+		return reply, dataOut, xdr.NewEncoder(dataOut).EncodeAll(resp, msg.ACCEPT_PROG_MISMATCH, msg.NLM_V4, msg.NLM_V4)
+	}
+
+	x.Logger.Tracef("NLM %s", strings.ToUpper(msg.NLMProc4Name(header.Proc)))
+
+	var (
+		result []interface{}
+		err    error
+	)
+
+	switch header.Proc {
+	case msg.NLMPROC4_NULL:
+	case msg.NLMPROC4_TEST:
+		result, err = x.Test(data)
+	case msg.NLMPROC4_LOCK, msg.NLMPROC4_NM_LOCK:
+		result, err = x.Lock(data, header.Proc == msg.NLMPROC4_LOCK)
+	case msg.NLMPROC4_CANCEL:
+		result, err = x.Cancel(data)
+	case msg.NLMPROC4_UNLOCK:
+		result, err = x.Unlock(data)
+	case msg.NLMPROC4_SHARE, msg.NLMPROC4_UNSHARE:
+		result, err = x.Share(data, header.Proc == msg.NLMPROC4_SHARE)
+	case msg.NLMPROC4_FREE_ALL:
+		err = x.FreeAll(data)
+	default:
+		return reply, dataOut, xdr.NewEncoder(dataOut).EncodeAll(resp, msg.ACCEPT_PROC_UNAVAIL)
+	}
+
+	if xdr.IsDecodeError(err) {
+		x.Logger.Warnf("failed to decode arguments: %v", err)
+
+		return garbageArgs(reply, resp, dataOut)
+	}
+
+	if err == nil {
+		err = xdr.NewEncoder(dataOut).EncodeAll(append([]interface{}{resp, msg.ACCEPT_SUCCESS}, result...)...)
+	}
+
+	if err != nil {
+		dataOut.Discard()
+
+		return nil, nil, err
+	}
+
+	return reply, dataOut, nil
+}
+
+// file returns the key of the file in the lock manager, which is the unsigned file handle as for NFSv4.
+func (x *MuxNLM) file(fh []byte) (string, bool) {
+	if len(fh) > msg.NFS3_FHSIZE {
+		return "", false
+	}
+
+	h, err := x.Signer.Unwrap(fh)
+	if err != nil {
+		return "", false
+	}
+
+	return string(h), true
+}
+
+// lock returns the lock requested by the client.
+func (x *MuxNLM) lock(l msg.NLM4Lock, exclusive bool) locks.Lock {
+	return locks.Lock{
+		Owner: locks.Owner{
+			Host: x.Host,
+			Svid: l.Svid,
+			ID:   string(l.Oh),
+		},
+		Exclusive: exclusive,
+		Offset:    l.Offset,
+		Length:    l.Length,
+	}
+}
+
+func (x *MuxNLM) Test(data Bytes) ([]interface{}, error) {
+	var args msg.NLM4TestArgs
+
+	if err := xdr.NewDecoder(data).Decode(&args); err != nil {
+		return nil, err
+	}
+
+	file, ok := x.file(args.Lock.FH)
+	if !ok {
+		return []interface{}{args.Cookie, msg.NLM4_STALE_FH}, nil
+	}
+
+	conflict, ok := x.Locks.Test(file, x.lock(args.Lock, args.Exclusive))
+	if !ok {
+		return []interface{}{args.Cookie, msg.NLM4_GRANTED}, nil
+	}
+
+	return []interface{}{
+		args.Cookie,
+		msg.NLM4_DENIED,
+		msg.NLM4Holder{
+			Exclusive: conflict.Exclusive,
+			Svid:      conflict.Owner.Svid,
+			Oh:        []byte(conflict.Owner.ID),
+			Offset:    conflict.Offset,
+			Length:    conflict.Length,
+		},
+	}, nil
+}
+
+// Lock acquires a lock. If the lock conflicts and the client is willing to wait, the request
+// is queued and the client is notified with a GRANTED callback once the lock is acquired.
+func (x *MuxNLM) Lock(data Bytes, monitored bool) ([]interface{}, error) {
+	var args msg.NLM4LockArgs
+
+	if err := xdr.NewDecoder(data).Decode(&args); err != nil {
+		return nil, err
+	}
+
+	file, ok := x.file(args.Lock.FH)
+	if !ok {
+		return []interface{}{msg.NLM4Res{Cookie: args.Cookie, Stat: msg.NLM4_STALE_FH}}, nil
+	}
+
+	// A changed state of the status monitor of the client means that it rebooted,
+	// so the locks that it held before are released
+	if monitored && x.Monitor.Update(x.Host, args.State) {
+		x.Logger.Infof("client %s rebooted, releasing its locks", x.Host)
+
+		x.Locks.Release(x.ownedByHost)
+	}
+
+	l := x.lock(args.Lock, args.Exclusive)
+
+	if !args.Block {
+		if _, ok = x.Locks.Lock(file, l); !ok {
+			return []interface{}{msg.NLM4Res{Cookie: args.Cookie, Stat: msg.NLM4_DENIED}}, nil
+		}
+
+		return []interface{}{msg.NLM4Res{Cookie: args.Cookie, Stat: msg.NLM4_GRANTED}}, nil
+	}
+
+	callback := msg.NLM4TestArgs{
+		Cookie:    args.Cookie,
+		Exclusive: args.Exclusive,
+		Lock:      args.Lock,
+	}
+
+	if _, ok = x.Locks.Wait(file, l, func(locks.Lock) { x.granted(file, l, callback) }); !ok {
+		return []interface{}{msg.NLM4Res{Cookie: args.Cookie, Stat: msg.NLM4_BLOCKED}}, nil
+	}
+
+	return []interface{}{msg.NLM4Res{Cookie: args.Cookie, Stat: msg.NLM4_GRANTED}}, nil
+}
+
+func (x *MuxNLM) Cancel(data Bytes) ([]interface{}, error) {
+	var args msg.NLM4CancArgs
+
+	if err := xdr.NewDecoder(data).Decode(&args); err != nil {
+		return nil, err
+	}
+
+	file, ok := x.file(args.Lock.FH)
+	if !ok {
+		return []interface{}{msg.NLM4Res{Cookie: args.Cookie, Stat: msg.NLM4_STALE_FH}}, nil
+	}
+
+	if !x.Locks.Cancel(file, x.lock(args.Lock, args.Exclusive)) {
+		return []interface{}{msg.NLM4Res{Cookie: args.Cookie, Stat: msg.NLM4_DENIED}}, nil
+	}
+
+	return []interface{}{msg.NLM4Res{Cookie: args.Cookie, Stat: msg.NLM4_GRANTED}}, nil
+}
+
+func (x *MuxNLM) Unlock(data Bytes) ([]interface{}, error) {
+	var args msg.NLM4UnlockArgs
+
+	if err := xdr.NewDecoder(data).Decode(&args); err != nil {
+		return nil, err
+	}
+
+	file, ok := x.file(args.Lock.FH)
+	if !ok {
+		return []interface{}{msg.NLM4Res{Cookie: args.Cookie, Stat: msg.NLM4_STALE_FH}}, nil
+	}
+
+	l := x.lock(args.Lock, false)
+
+	x.Locks.Unlock(file, l.Owner, l.Offset, l.Length)
+
+	return []interface{}{msg.NLM4Res{Cookie: args.Cookie, Stat: msg.NLM4_GRANTED}}, nil
+}
+
+// Share adds or removes a share reservation, which DOS clients use to open files.
+func (x *MuxNLM) Share(data Bytes, share bool) ([]interface{}, error) {
+	var args msg.NLM4ShareArgs
+
+	if err := xdr.NewDecoder(data).Decode(&args); err != nil {
+		return nil, err
+	}
+
+	res := msg.NLM4ShareRes{
+		Cookie: args.Cookie,
+		Stat:   msg.NLM4_GRANTED,
+	}
+
+	file, ok := x.file(args.Share.FH)
+	if !ok {
+		res.Stat = msg.NLM4_STALE_FH
+
+		return []interface{}{res}, nil
+	}
+
+	owner := locks.Owner{
+		Host: x.Host,
+		ID:   string(args.Share.Oh),
+	}
+
+	if !share {
+		x.Locks.Unshare(file, owner)
+
+		return []interface{}{res}, nil
+	}
+
+	if !x.Locks.Share(file, locks.Share{Owner: owner, Access: args.Share.Access, Deny: args.Share.Mode}) {
+		res.Stat = msg.NLM4_DENIED
+	}
+
+	return []interface{}{res}, nil
+}
+
+// FreeAll releases all locks of the client, which is sent by clients that do not use a status monitor.
+func (x *MuxNLM) FreeAll(data Bytes) error {
+	var args msg.NLM4Notify
+
+	if err := xdr.NewDecoder(data).Decode(&args); err != nil {
+		return err
+	}
+
+	x.Locks.Release(x.ownedByHost)
+
+	return nil
+}
+
+func (x *MuxNLM) ownedByHost(o locks.Owner) bool {
+	return o.Host == x.Host
+}
+
+// granted notifies the client that a queued lock was acquired. If the client does not want
+// the lock anymore, it is released. If the client cannot be reached, the lock is kept:
+// clients poll for blocked locks, and receive NLM4_GRANTED since they already hold it.
+func (x *MuxNLM) granted(file string, l locks.Lock, args msg.NLM4TestArgs) {
+	ctx, cancel := context.WithTimeout(context.Background(), CallbackTimeout)
+	defer cancel()
+
+	var res msg.NLM4Res
+
+	err := nlmCallback(ctx, x.Host, msg.NLMPROC4_GRANTED, args, &res)
+	if err != nil {
+		x.Logger.Warnf("failed to notify %s of granted lock: %v", x.Host, err)
+
+		return
+	}
+
+	if res.Stat != msg.NLM4_GRANTED {
+		x.Logger.Infof("client %s refused granted lock: %d", x.Host, res.Stat)
+
+		x.Locks.Unlock(file, l.Owner, l.Offset, l.Length)
+	}
+}
+
+// nlmCallback calls a procedure of the lock manager of the client, of which the port
+// is looked up with the portmapper of the client.
+func nlmCallback(ctx context.Context, host string, proc uint32, args, res interface{}) error {
+	var port uint32
+
+	err := callRPC(ctx, net.JoinHostPort(host, strconv.Itoa(msg.PMAP_PORT)), msg.PMAP_PROGRAM, msg.PMAP_V2, msg.PMAPPROC_GETPORT, msg.Mapping{
+		Prog: msg.NLM_PROGRAM,
+		Vers: msg.NLM_V4,
+		Prot: msg.IPPROTO_TCP,
+	}, &port)
+	if err != nil {
+		return err
+	}
+
+	if port == 0 {
+		return errors.New("lock manager of client is not registered")
+	}
+
+	return callRPC(ctx, net.JoinHostPort(host, strconv.Itoa(int(port))), msg.NLM_PROGRAM, msg.NLM_V4, proc, args, res)
+}
+
+// callRPC sends a single call over a new connection and decodes the result.
+func callRPC(ctx context.Context, address string, prog, vers, proc uint32, args, res interface{}) error {
+	conn, err := dialReserved(ctx, address)
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline) //nolint:errcheck
+	}
+
+	hostname, _ := os.Hostname() //nolint:errcheck
+
+	var body bytes.Buffer
+
+	if err = xdr.NewEncoder(&body).Encode(auth.Creds{
+		ExpirationValue: uint32(clock.Now().Unix()),
+		Hostname:        hostname,
+	}); err != nil {
+		return err
+	}
+
+	header := &msg.RPCMsgCall{
+		Xid:     uint32(clock.Now().UnixNano()),
+		MsgType: msg.RPC_CALL,
+		RPCVer:  2,
+		Prog:    prog,
+		Vers:    vers,
+		Proc:    proc,
+		Cred:    msg.Auth{Flavor: msg.AUTH_FLAVOR_UNIX, Body: body.Bytes()},
+		Verf:    msg.Auth{Flavor: msg.AUTH_FLAVOR_NULL, Body: []byte{}},
+	}
+
+	buf := bufpool.Get()
+
+	if err = xdr.NewEncoder(buf).Encode(args); err != nil {
+		buf.Discard()
+
+		return err
+	}
+
+	if err = SendCall(conn, header, buf); err != nil {
+		return err
+	}
+
+	_, reply, data, err := ReceiveMessage(bufio.NewReader(conn))
+	if data != nil {
+		defer data.Discard()
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if reply == nil || reply.Xid != header.Xid {
+		return errors.New("unexpected message")
+	}
+
+	if reply.ReplyStat != msg.MSG_ACCEPTED {
+		return errors.New("call denied")
+	}
+
+	var (
+		verf        msg.Auth
+		acceptState uint32
+	)
+
+	if err = xdr.NewDecoder(data).DecodeAll(&verf, &acceptState); err != nil {
+		return err
+	}
+
+	if acceptState != msg.ACCEPT_SUCCESS {
+		return errors.New("call not accepted")
+	}
+
+	return xdr.NewDecoder(data).Decode(res)
+}
+
+// nsmState is the state of the status monitor of the server, which is odd while it is up
+// and changes when the server restarts.
+var nsmState = uint32(clock.Now().Unix()) | 1
+
+// MuxNSM serves a minimal Network Status Monitor version 1. It reports the state of the server
+// to clients, and releases the NLM locks of clients that notify that they rebooted.
+// Monitoring requests are accepted, but the server does not notify clients when it restarts,
+// as the locks do not survive a restart.
+type MuxNSM struct {
+	Logger  *logrus.Entry
+	Locks   *locks.Manager
+	Monitor *Monitor
+	Host    string // Address of the client
+}
+
+func (x *MuxNSM) Handle(request Request, response chan<- Response) {
+	reply, data, err := x.HandleProc(request.Header, request.Data)
+	if err != nil {
+		x.Logger.Error(err)
+	}
+
+	response <- Response{
+		Reply: reply,
+		Data:  data,
+		Error: err,
+	}
+}
+
+func (x *MuxNSM) HandleProc(header *msg.RPCMsgCall, data Bytes) (*msg.RPCMsgReply, Bytes, error) {
+	defer data.Discard()
+
+	reply := &msg.RPCMsgReply{
+		Xid:       header.Xid,
+		MsgType:   msg.RPC_REPLY,
+		ReplyStat: msg.MSG_ACCEPTED,
+	}
+
+	resp := msg.Auth{
+		Flavor: msg.AUTH_FLAVOR_NULL,
+		Body:   []byte{},
+	}
+
+	dataOut := bufpool.Get()
+
+	if header.Vers != msg.SM_VERS {
+		return reply, dataOut, xdr.NewEncoder(dataOut).EncodeAll(resp, msg.ACCEPT_PROG_MISMATCH, msg.SM_VERS, msg.SM_VERS)
+	}
+
+	x.Logger.Tracef("NSM %s", strings.ToUpper(msg.SMProcName(header.Proc)))
+
+	var (
+		result []interface{}
+		err    error
+	)
+
+	switch header.Proc {
+	case msg.SM_NULL, msg.SM_SIMU_CRASH:
+	case msg.SM_STAT:
+		var name string
+
+		if err = xdr.NewDecoder(data).Decode(&name); err == nil {
+			result = []interface{}{msg.SMStatRes{Res: msg.STAT_SUCC, State: nsmState}}
+		}
+	case msg.SM_MON:
+		var args msg.Mon
+
+		if err = xdr.NewDecoder(data).Decode(&args); err == nil {
+			result = []interface{}{msg.SMStatRes{Res: msg.STAT_SUCC, State: nsmState}}
+		}
+	case msg.SM_UNMON:
+		var args msg.MonID
+
+		if err = xdr.NewDecoder(data).Decode(&args); err == nil {
+			result = []interface{}{nsmState}
+		}
+	case msg.SM_UNMON_ALL:
+		var args msg.MyID
+
+		if err = xdr.NewDecoder(data).Decode(&args); err == nil {
+			result = []interface{}{nsmState}
+		}
+	case msg.SM_NOTIFY:
+		var args msg.StatChge
+
+		if err = xdr.NewDecoder(data).Decode(&args); err == nil {
+			x.Notify(args)
+		}
+	default:
+		return reply, dataOut, xdr.NewEncoder(dataOut).EncodeAll(resp, msg.ACCEPT_PROC_UNAVAIL)
+	}
+
+	if xdr.IsDecodeError(err) {
+		x.Logger.Warnf("failed to decode arguments: %v", err)
+
+		return garbageArgs(reply, resp, dataOut)
+	}
+
+	if err == nil {
+		err = xdr.NewEncoder(dataOut).EncodeAll(append([]interface{}{resp, msg.ACCEPT_SUCCESS}, result...)...)
+	}
+
+	if err != nil {
+		dataOut.Discard()
+
+		return nil, nil, err
+	}
+
+	return reply, dataOut, nil
+}
+
+// Notify releases the locks of a client that rebooted, which is identified by the address
+// from which the notification is sent.
+func (x *MuxNSM) Notify(args msg.StatChge) {
+	x.Logger.Infof("client %s (%s) rebooted with state %d, releasing its locks", args.MonName, x.Host, args.State)
+
+	x.Monitor.Update(x.Host, args.State)
+
+	x.Locks.Release(func(o locks.Owner) bool {
+		return o.Host == x.Host
+	})
+}
+
+// Monitor keeps the state of the status monitors of NLM clients, which they send with
+// each LOCK request. The state changes when a client reboots.
+type Monitor struct {
+	states map[string]uint32
+	sync.Mutex
+}
+
+func NewMonitor() *Monitor {
+	return &Monitor{
+		states: map[string]uint32{},
+	}
+}
+
+// Update records the state of the host, and returns whether it changed since the previous update.
+func (m *Monitor) Update(host string, state uint32) bool {
+	m.Lock()
+	defer m.Unlock()
+
+	previous, ok := m.states[host]
+
+	m.states[host] = state
+
+	return ok && previous != state
+}
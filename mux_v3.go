@@ -0,0 +1,1624 @@
+package nfs4go
+
+import (
+	"errors"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/kuleuven/nfs4go/auth"
+	"github.com/kuleuven/nfs4go/bufpool"
+	"github.com/kuleuven/nfs4go/clock"
+	"github.com/kuleuven/nfs4go/handle"
+	"github.com/kuleuven/nfs4go/logger"
+	"github.com/kuleuven/nfs4go/msg"
+	"github.com/kuleuven/nfs4go/worker"
+	"github.com/kuleuven/nfs4go/xdr"
+	"github.com/kuleuven/vfs"
+	"github.com/sirupsen/logrus"
+)
+
+// Muxv3 serves NFS version 3 (rfc1813) on the same file systems and file handles as Muxv4.
+// NFSv3 is stateless: files are opened for the duration of a single READ, WRITE or COMMIT.
+type Muxv3 struct {
+	Logger *logrus.Entry
+	Signer *handle.Signer // Signs file handles sent to clients, if set
+
+	// Retrieve a FS for the specified creds and sessionID.
+	// The sessionID is always empty, so that workers are shared with v4.0 clients on the same host.
+	FS func(creds *auth.Creds, sessionID [16]byte) *worker.Worker
+}
+
+const (
+	maxIO3   = 1024 * 1024 // Maximum size of READ and WRITE
+	maxName3 = 255
+)
+
+// writeVerifier3 is returned by WRITE and COMMIT. Unstable writes are kept in the page cache
+// of the server, so they are only lost if the server restarts, which changes the verifier.
+var writeVerifier3 = uint64(clock.Now().UnixNano())
+
+func (x *Muxv3) Handle(request Request, response chan<- Response) {
+	reply, data, err := x.HandleProc(request.Header, request.Data)
+	if err != nil {
+		x.Logger.Error(err)
+	}
+
+	response <- Response{
+		Reply: reply,
+		Data:  data,
+		Error: err,
+	}
+}
+
+func (x *Muxv3) HandleProc(header *msg.RPCMsgCall, data Bytes) (*msg.RPCMsgReply, Bytes, error) {
+	if header.Proc == msg.NFSPROC3_NULL {
+		return x.Null(header, data)
+	}
+
+	resp, creds, err := auth.Authenticate(header.Cred, header.Verf)
+	if authErr, ok := err.(*auth.AuthError); ok {
+		data.Reset()
+
+		return &msg.RPCMsgReply{
+			Xid:       header.Xid,
+			MsgType:   msg.RPC_REPLY,
+			ReplyStat: msg.MSG_DENIED,
+		}, data, xdr.NewEncoder(data).EncodeAll(msg.REJECT_AUTH_ERROR, authErr.Code)
+	} else if err != nil {
+		return nil, nil, err
+	}
+
+	defer data.Discard()
+
+	call := &Call3{
+		Muxv3: x,
+		Creds: creds,
+	}
+
+	procedure := call.procedure(header.Proc)
+
+	dataOut := bufpool.Get()
+
+	reply := &msg.RPCMsgReply{
+		Xid:       header.Xid,
+		MsgType:   msg.RPC_REPLY,
+		ReplyStat: msg.MSG_ACCEPTED,
+	}
+
+	if procedure == nil {
+		return reply, dataOut, xdr.NewEncoder(dataOut).EncodeAll(resp, msg.ACCEPT_PROC_UNAVAIL)
+	}
+
+	if err = xdr.NewEncoder(dataOut).EncodeAll(resp, msg.ACCEPT_SUCCESS); err != nil {
+		dataOut.Discard()
+
+		return nil, nil, err
+	}
+
+	if _, err = procedure(data, dataOut); xdr.IsDecodeError(err) {
+		x.Logger.Warnf("failed to decode arguments of %s: %v", msg.Proc3Name(header.Proc), err)
+
+		return garbageArgs(reply, resp, dataOut)
+	} else if err != nil {
+		dataOut.Discard()
+
+		return nil, nil, err
+	}
+
+	return reply, dataOut, nil
+}
+
+func (x *Muxv3) Null(header *msg.RPCMsgCall, data Bytes) (*msg.RPCMsgReply, Bytes, error) {
+	data.Reset()
+
+	err := xdr.NewEncoder(data).EncodeAll(
+		msg.Auth{
+			Flavor: msg.AUTH_FLAVOR_NULL,
+			Body:   []byte{},
+		},
+		msg.ACCEPT_SUCCESS,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &msg.RPCMsgReply{
+		Xid:       header.Xid,
+		MsgType:   msg.RPC_REPLY,
+		ReplyStat: msg.MSG_ACCEPTED,
+	}, data, nil
+}
+
+// Call3 is a single NFSv3 call, the counterpart of Compound.
+type Call3 struct {
+	*Muxv3
+	Creds *auth.Creds // Credentials used for authentication
+}
+
+func (x *Call3) procedure(proc uint32) func(in, out Bytes) (uint32, error) { //nolint:gocyclo
+	switch proc {
+	case msg.NFSPROC3_GETATTR:
+		return x.GetAttr
+	case msg.NFSPROC3_SETATTR:
+		return x.SetAttr
+	case msg.NFSPROC3_LOOKUP:
+		return x.Lookup
+	case msg.NFSPROC3_ACCESS:
+		return x.Access
+	case msg.NFSPROC3_READLINK:
+		return x.Readlink
+	case msg.NFSPROC3_READ:
+		return x.Read
+	case msg.NFSPROC3_WRITE:
+		return x.Write
+	case msg.NFSPROC3_CREATE:
+		return x.Create
+	case msg.NFSPROC3_MKDIR:
+		return x.Mkdir
+	case msg.NFSPROC3_SYMLINK:
+		return x.Symlink
+	case msg.NFSPROC3_MKNOD:
+		return x.Mknod
+	case msg.NFSPROC3_REMOVE:
+		return x.Remove
+	case msg.NFSPROC3_RMDIR:
+		return x.Rmdir
+	case msg.NFSPROC3_RENAME:
+		return x.Rename
+	case msg.NFSPROC3_LINK:
+		return x.Link
+	case msg.NFSPROC3_READDIR:
+		return x.ReadDir
+	case msg.NFSPROC3_READDIRPLUS:
+		return x.ReadDirPlus
+	case msg.NFSPROC3_FSSTAT:
+		return x.FSStat
+	case msg.NFSPROC3_FSINFO:
+		return x.FSInfo
+	case msg.NFSPROC3_PATHCONF:
+		return x.PathConf
+	case msg.NFSPROC3_COMMIT:
+		return x.Commit
+	default:
+		return nil
+	}
+}
+
+func ProcedureResponse(out Bytes, proc, status uint32, data ...interface{}) (uint32, error) {
+	if status != msg.NFS3_OK {
+		logger.Logger.Warnf("Procedure [%s] failed with status %d", msg.Proc3Name(proc), status)
+	}
+
+	encoder := xdr.NewEncoder(out)
+
+	if err := encoder.Encode(status); err != nil {
+		return status, err
+	}
+
+	return status, encoder.EncodeAll(data...)
+}
+
+// resolve verifies a file handle sent by the client and looks up its path.
+func (x *Call3) resolve(fs *worker.Worker, fh []byte) (*FileHandle, uint32) {
+	if len(fh) > msg.NFS3_FHSIZE {
+		return nil, msg.NFS3ERR_BADHANDLE
+	}
+
+	h, err := x.Signer.Unwrap(fh)
+	if err != nil {
+		return nil, msg.Err2Status3(err)
+	}
+
+	// Attribute directories and named attributes only exist in NFSv4
+	if _, _, _, named := splitNamedAttrHandle(h); named {
+		return nil, msg.NFS3ERR_BADHANDLE
+	}
+
+	var path string
+
+	if fi, cached := fs.Cache.Get(h); cached {
+		path = fi.Path
+	} else {
+		path, err = fs.Path(h)
+	}
+
+	if err != nil {
+		DiscardOnServerFault(fs, err)
+
+		if errors.Is(err, syscall.EOPNOTSUPP) || errors.Is(err, os.ErrNotExist) {
+			return nil, msg.NFS3ERR_STALE
+		}
+
+		return nil, msg.Err2Status3(err)
+	}
+
+	return &FileHandle{
+		Handle: h,
+		Path:   path,
+	}, msg.NFS3_OK
+}
+
+// wrap signs a handle to send it to the client.
+func (x *Call3) wrap(h []byte) ([]byte, bool) {
+	fh := x.Signer.Wrap(h)
+
+	if len(fh) > msg.NFS3_FHSIZE {
+		x.Logger.Warnf("file handle of %d bytes is too long for nfs v3", len(fh))
+
+		return nil, false
+	}
+
+	return fh, true
+}
+
+// lstat returns the file info of a handle, using the cache if possible.
+func (x *Call3) lstat(fs *worker.Worker, fh *FileHandle) (vfs.FileInfo, error) {
+	if entry, cached := fs.Cache.Get(fh.Handle); cached {
+		return entry.FileInfo, nil
+	}
+
+	fi, err := fs.Lstat(fh.Path)
+	if err != nil {
+		DiscardOnServerFault(fs, err)
+
+		return nil, err
+	}
+
+	fs.Cache.Put(fh.Handle, worker.Entry{
+		Path:     fh.Path,
+		FileInfo: fi,
+	})
+
+	return fi, nil
+}
+
+// postOpAttr returns the attributes of a handle after the procedure, or none if they cannot be retrieved.
+func (x *Call3) postOpAttr(fs *worker.Worker, fh *FileHandle) msg.PostOpAttr3 {
+	if fh == nil {
+		return msg.PostOpAttr3{}
+	}
+
+	fi, err := x.lstat(fs, fh)
+	if err != nil {
+		return msg.PostOpAttr3{}
+	}
+
+	return postOpAttr3(fh.Handle, fi)
+}
+
+// preOpAttr returns the attributes of a handle before the procedure, used for weak cache consistency.
+func (x *Call3) preOpAttr(fs *worker.Worker, fh *FileHandle) msg.PreOpAttr3 {
+	fi, err := x.lstat(fs, fh)
+	if err != nil {
+		return msg.PreOpAttr3{}
+	}
+
+	return msg.PreOpAttr3{
+		AttributesFollow: 1,
+		Attributes: msg.WccAttr3{
+			Size:  uint64(fi.Size()),
+			Mtime: nfsTime3(fi.ModTime()),
+			Ctime: nfsTime3(fi.ModTime()),
+		},
+	}
+}
+
+// wcc invalidates the cached attributes of a modified handle and returns its weak cache consistency data.
+func (x *Call3) wcc(fs *worker.Worker, fh *FileHandle, before msg.PreOpAttr3) msg.WccData3 {
+	if fh == nil {
+		return msg.WccData3{}
+	}
+
+	fs.Cache.Invalidate(fh.Handle)
+
+	return msg.WccData3{
+		Before: before,
+		After:  x.postOpAttr(fs, fh),
+	}
+}
+
+// postOpFh returns the handle of a path, or none if it cannot be retrieved.
+func (x *Call3) postOpFh(fs *worker.Worker, path string) (msg.PostOpFh3, *FileHandle) {
+	h, err := fs.Handle(path)
+	if err != nil {
+		DiscardOnServerFault(fs, err)
+
+		return msg.PostOpFh3{}, nil
+	}
+
+	fh, ok := x.wrap(h)
+	if !ok {
+		return msg.PostOpFh3{}, nil
+	}
+
+	return msg.PostOpFh3{HandleFollows: 1, Handle: fh}, &FileHandle{Handle: h, Path: path}
+}
+
+func postOpAttr3(h []byte, fi vfs.FileInfo) msg.PostOpAttr3 {
+	return msg.PostOpAttr3{
+		AttributesFollow: 1,
+		Attributes:       fattr3(h, fi),
+	}
+}
+
+func fattr3(h []byte, fi vfs.FileInfo) msg.Fattr3 {
+	nlink := uint32(fi.NumLinks())
+
+	if nlink == 0 {
+		nlink = 1
+	}
+
+	mtime := nfsTime3(fi.ModTime())
+
+	return msg.Fattr3{
+		Type:   nfsType(fi),
+		Mode:   uint32(fi.Mode().Perm()),
+		NLink:  nlink,
+		Uid:    fi.Uid(),
+		Gid:    fi.Gid(),
+		Size:   uint64(fi.Size()),
+		Used:   uint64(1024*4 + fi.Size()),
+		Fsid:   1,
+		FileID: fileIDOf(h),
+		Atime:  mtime,
+		Mtime:  mtime,
+		Ctime:  mtime,
+	}
+}
+
+func nfsTime3(t time.Time) msg.NfsTime3 {
+	return msg.NfsTime3{
+		Seconds:  uint32(t.Unix()),
+		NSeconds: uint32(t.Nanosecond()),
+	}
+}
+
+// exclusiveTime returns the modification time in which the verifier of an exclusive CREATE is stored.
+// Nanoseconds hold 30 bits, so the two most significant bits of the lower half are lost.
+func exclusiveTime(verf uint64) time.Time {
+	return time.Unix(int64(verf>>32), int64(verf&(1<<30-1)))
+}
+
+// checkName validates a name of a new directory entry.
+func checkName(name string) uint32 {
+	switch {
+	case name == "" || name == "." || name == ".." || strings.Contains(name, "/"):
+		return msg.NFS3ERR_INVAL
+	case len(name) > maxName3:
+		return msg.NFS3ERR_NAMETOOLONG
+	default:
+		return msg.NFS3_OK
+	}
+}
+
+// setAttrs applies the attributes set by the client to path, of which fi is the current file info.
+func (x *Call3) setAttrs(fs *worker.Worker, path string, fi vfs.FileInfo, attrs msg.Sattr3) error { //nolint:funlen
+	changed := false
+
+	if attrs.Mode.SetIt != 0 {
+		if err := fs.Chmod(path, os.FileMode(attrs.Mode.Value)&os.ModePerm); err != nil {
+			DiscardOnServerFault(fs, err)
+
+			x.Logger.Warnf("failed to chmod: %v", err)
+
+			return err
+		}
+
+		changed = true
+	}
+
+	if attrs.Uid.SetIt != 0 || attrs.Gid.SetIt != 0 {
+		uid, gid := fi.Uid(), fi.Gid()
+
+		if attrs.Uid.SetIt != 0 {
+			uid = attrs.Uid.Value
+		}
+
+		if attrs.Gid.SetIt != 0 {
+			gid = attrs.Gid.Value
+		}
+
+		if err := fs.Chown(path, int(uid), int(gid)); err != nil {
+			DiscardOnServerFault(fs, err)
+
+			x.Logger.Warnf("failed to chown: %v", err)
+
+			return err
+		}
+
+		changed = true
+	}
+
+	if attrs.Size.SetIt != 0 {
+		if err := fs.Truncate(path, int64(attrs.Size.Size)); err != nil {
+			DiscardOnServerFault(fs, err)
+
+			x.Logger.Warnf("failed to truncate: %v", err)
+
+			return err
+		}
+
+		changed = true
+	}
+
+	if attrs.Atime.How == msg.DONT_CHANGE && attrs.Mtime.How == msg.DONT_CHANGE {
+		if !changed {
+			return nil
+		}
+
+		// The ctime reported to clients is the modification time, make sure it changes
+		return touchAfter(fs, path, fi.ModTime())
+	}
+
+	setTime := func(t msg.SetTime3, current time.Time) time.Time {
+		switch t.How {
+		case msg.SET_TO_SERVER_TIME:
+			return clock.Now()
+		case msg.SET_TO_CLIENT_TIME:
+			return time.Unix(int64(t.ClientTime.Seconds), int64(t.ClientTime.NSeconds))
+		default:
+			return current
+		}
+	}
+
+	mtime := setTime(attrs.Mtime, fi.ModTime())
+	atime := setTime(attrs.Atime, mtime)
+
+	if err := fs.Chtimes(path, atime, mtime); err != nil {
+		DiscardOnServerFault(fs, err)
+
+		x.Logger.Warnf("failed to set time: %v", err)
+
+		return err
+	}
+
+	return nil
+}
+
+func (x *Call3) GetAttr(in, out Bytes) (uint32, error) {
+	var args msg.GETATTR3args
+
+	if err := xdr.NewDecoder(in).Decode(&args); err != nil {
+		return 0, err
+	}
+
+	fs := x.FS(x.Creds, [16]byte{})
+
+	defer fs.Close()
+
+	fh, status := x.resolve(fs, args.Object)
+	if status != msg.NFS3_OK {
+		return ProcedureResponse(out, msg.NFSPROC3_GETATTR, status)
+	}
+
+	x.Logger.Tracef("GETATTR %s", fh.Path)
+
+	fi, err := x.lstat(fs, fh)
+	if err != nil {
+		return ProcedureResponse(out, msg.NFSPROC3_GETATTR, msg.Err2Status3(err))
+	}
+
+	return ProcedureResponse(out,
+		msg.NFSPROC3_GETATTR,
+		msg.NFS3_OK,
+		msg.GETATTR3resok{
+			ObjAttributes: fattr3(fh.Handle, fi),
+		},
+	)
+}
+
+func (x *Call3) SetAttr(in, out Bytes) (uint32, error) {
+	var args msg.SETATTR3args
+
+	if err := xdr.NewDecoder(in).Decode(&args); err != nil {
+		return 0, err
+	}
+
+	fs := x.FS(x.Creds, [16]byte{})
+
+	defer fs.Close()
+
+	fh, status := x.resolve(fs, args.Object)
+	if status != msg.NFS3_OK {
+		return ProcedureResponse(out, msg.NFSPROC3_SETATTR, status, msg.WccData3{})
+	}
+
+	x.Logger.Tracef("SETATTR %s", fh.Path)
+
+	fs.Cache.Invalidate(fh.Handle)
+
+	fi, err := x.lstat(fs, fh)
+	if err != nil {
+		return ProcedureResponse(out, msg.NFSPROC3_SETATTR, msg.Err2Status3(err), msg.WccData3{})
+	}
+
+	before := x.preOpAttr(fs, fh)
+
+	if args.Guard.Check != 0 && args.Guard.ObjCtime != nfsTime3(fi.ModTime()) {
+		return ProcedureResponse(out, msg.NFSPROC3_SETATTR, msg.NFS3ERR_NOT_SYNC, x.wcc(fs, fh, before))
+	}
+
+	err = x.setAttrs(fs, fh.Path, fi, args.NewAttributes)
+
+	return ProcedureResponse(out, msg.NFSPROC3_SETATTR, msg.Err2Status3(err), x.wcc(fs, fh, before))
+}
+
+func (x *Call3) Lookup(in, out Bytes) (uint32, error) {
+	var args msg.LOOKUP3args
+
+	if err := xdr.NewDecoder(in).Decode(&args); err != nil {
+		return 0, err
+	}
+
+	fs := x.FS(x.Creds, [16]byte{})
+
+	defer fs.Close()
+
+	dir, status := x.resolve(fs, args.What.Dir)
+	if status != msg.NFS3_OK {
+		return ProcedureResponse(out, msg.NFSPROC3_LOOKUP, status, msg.PostOpAttr3{})
+	}
+
+	x.Logger.Tracef("LOOKUP %s %s", dir.Path, args.What.Name)
+
+	var path string
+
+	switch args.What.Name {
+	case "", ".":
+		path = dir.Path
+	case "..":
+		path = vfs.Dir(dir.Path)
+	default:
+		if strings.Contains(args.What.Name, "/") {
+			return ProcedureResponse(out, msg.NFSPROC3_LOOKUP, msg.NFS3ERR_INVAL, x.postOpAttr(fs, dir))
+		}
+
+		path = vfs.Join(dir.Path, args.What.Name)
+	}
+
+	h, err := fs.Handle(path)
+	if err != nil {
+		DiscardOnServerFault(fs, err)
+
+		return ProcedureResponse(out, msg.NFSPROC3_LOOKUP, msg.Err2Status3(err), x.postOpAttr(fs, dir))
+	}
+
+	fh, ok := x.wrap(h)
+	if !ok {
+		return ProcedureResponse(out, msg.NFSPROC3_LOOKUP, msg.NFS3ERR_SERVERFAULT, x.postOpAttr(fs, dir))
+	}
+
+	return ProcedureResponse(out,
+		msg.NFSPROC3_LOOKUP,
+		msg.NFS3_OK,
+		msg.LOOKUP3resok{
+			Object:        fh,
+			ObjAttributes: x.postOpAttr(fs, &FileHandle{Handle: h, Path: path}),
+			DirAttributes: x.postOpAttr(fs, dir),
+		},
+	)
+}
+
+func (x *Call3) Access(in, out Bytes) (uint32, error) {
+	var args msg.ACCESS3args
+
+	if err := xdr.NewDecoder(in).Decode(&args); err != nil {
+		return 0, err
+	}
+
+	fs := x.FS(x.Creds, [16]byte{})
+
+	defer fs.Close()
+
+	fh, status := x.resolve(fs, args.Object)
+	if status != msg.NFS3_OK {
+		return ProcedureResponse(out, msg.NFSPROC3_ACCESS, status, msg.PostOpAttr3{})
+	}
+
+	x.Logger.Tracef("ACCESS %s %d", fh.Path, args.Access)
+
+	fi, err := x.lstat(fs, fh)
+	if err != nil {
+		return ProcedureResponse(out, msg.NFSPROC3_ACCESS, msg.Err2Status3(err), msg.PostOpAttr3{})
+	}
+
+	// The ACCESS3 bits are the same as the first ACCESS4 bits
+	_, granted := accessFor(fi, x.Creds)
+
+	return ProcedureResponse(out,
+		msg.NFSPROC3_ACCESS,
+		msg.NFS3_OK,
+		msg.ACCESS3resok{
+			ObjAttributes: postOpAttr3(fh.Handle, fi),
+			Access:        args.Access & granted & (msg.ACCESS3_EXECUTE<<1 - 1),
+		},
+	)
+}
+
+func (x *Call3) Readlink(in, out Bytes) (uint32, error) {
+	var args msg.READLINK3args
+
+	if err := xdr.NewDecoder(in).Decode(&args); err != nil {
+		return 0, err
+	}
+
+	fs := x.FS(x.Creds, [16]byte{})
+
+	defer fs.Close()
+
+	fh, status := x.resolve(fs, args.Symlink)
+	if status != msg.NFS3_OK {
+		return ProcedureResponse(out, msg.NFSPROC3_READLINK, status, msg.PostOpAttr3{})
+	}
+
+	x.Logger.Tracef("READLINK %s", fh.Path)
+
+	target, err := fs.Readlink(fh.Path)
+	if err != nil {
+		DiscardOnServerFault(fs, err)
+
+		return ProcedureResponse(out, msg.NFSPROC3_READLINK, msg.Err2Status3(err), x.postOpAttr(fs, fh))
+	}
+
+	return ProcedureResponse(out,
+		msg.NFSPROC3_READLINK,
+		msg.NFS3_OK,
+		msg.READLINK3resok{
+			SymlinkAttributes: x.postOpAttr(fs, fh),
+			Data:              target,
+		},
+	)
+}
+
+func (x *Call3) Read(in, out Bytes) (uint32, error) { //nolint:funlen
+	var args msg.READ3args
+
+	if err := xdr.NewDecoder(in).Decode(&args); err != nil {
+		return 0, err
+	}
+
+	fs := x.FS(x.Creds, [16]byte{})
+
+	defer fs.Close()
+
+	fh, status := x.resolve(fs, args.File)
+	if status != msg.NFS3_OK {
+		return ProcedureResponse(out, msg.NFSPROC3_READ, status, msg.PostOpAttr3{})
+	}
+
+	x.Logger.Tracef("READ %s %d %d", fh.Path, args.Offset, args.Count)
+
+	fi, err := x.lstat(fs, fh)
+	if err == nil && fi.IsDir() {
+		err = syscall.EISDIR
+	}
+
+	if err != nil {
+		return ProcedureResponse(out, msg.NFSPROC3_READ, msg.Err2Status3(err), msg.PostOpAttr3{})
+	}
+
+	f, err := fs.FileRead(fh.Path)
+	if err != nil {
+		DiscardOnServerFault(fs, err)
+
+		return ProcedureResponse(out, msg.NFSPROC3_READ, msg.Err2Status3(err), postOpAttr3(fh.Handle, fi))
+	}
+
+	defer f.Close()
+
+	buf := bufpool.Get()
+
+	defer buf.Discard()
+
+	b := buf.Allocate(int(min(args.Count, maxIO3)))
+
+	n, err := f.ReadAt(b, int64(args.Offset))
+	if err != nil && !errors.Is(err, io.EOF) {
+		x.Logger.Errorf("failed to read: %v", err)
+
+		return ProcedureResponse(out, msg.NFSPROC3_READ, msg.Err2Status3(err), postOpAttr3(fh.Handle, fi))
+	}
+
+	return ProcedureResponse(out,
+		msg.NFSPROC3_READ,
+		msg.NFS3_OK,
+		msg.READ3resok{
+			FileAttributes: postOpAttr3(fh.Handle, fi),
+			Count:          uint32(n),
+			Eof:            errors.Is(err, io.EOF) || int64(args.Offset)+int64(n) >= fi.Size(),
+			Data:           b[:n],
+		},
+	)
+}
+
+func (x *Call3) Write(in, out Bytes) (uint32, error) { //nolint:funlen
+	var args msg.WRITE3args
+
+	if err := xdr.NewDecoder(in).Decode(&args); err != nil {
+		return 0, err
+	}
+
+	fs := x.FS(x.Creds, [16]byte{})
+
+	defer fs.Close()
+
+	fh, status := x.resolve(fs, args.File)
+	if status != msg.NFS3_OK {
+		return ProcedureResponse(out, msg.NFSPROC3_WRITE, status, msg.WccData3{})
+	}
+
+	x.Logger.Tracef("WRITE %s %d %d %d", fh.Path, args.Offset, args.Count, args.Stable)
+
+	before := x.preOpAttr(fs, fh)
+
+	if args.Count > uint32(len(args.Data)) || args.Count > maxIO3 {
+		return ProcedureResponse(out, msg.NFSPROC3_WRITE, msg.NFS3ERR_INVAL, x.wcc(fs, fh, before))
+	}
+
+	f, err := fs.FileWrite(fh.Path, os.O_WRONLY)
+	if err != nil {
+		DiscardOnServerFault(fs, err)
+
+		return ProcedureResponse(out, msg.NFSPROC3_WRITE, msg.Err2Status3(err), x.wcc(fs, fh, before))
+	}
+
+	defer f.Close()
+
+	n, err := f.WriteAt(args.Data[:args.Count], int64(args.Offset))
+	if err != nil {
+		x.Logger.Errorf("failed to write: %v", err)
+
+		return ProcedureResponse(out, msg.NFSPROC3_WRITE, msg.Err2Status3(err), x.wcc(fs, fh, before))
+	}
+
+	// Files that cannot be synced are left to the file system, as for NFSv4
+	committed := msg.FILE_SYNC
+
+	if s, ok := findFile[syncer](f); ok {
+		if args.Stable == msg.UNSTABLE {
+			committed = msg.UNSTABLE
+		} else if err = s.Sync(); err != nil {
+			x.Logger.Errorf("failed to sync: %v", err)
+
+			return ProcedureResponse(out, msg.NFSPROC3_WRITE, msg.Err2Status3(err), x.wcc(fs, fh, before))
+		}
+	}
+
+	return ProcedureResponse(out,
+		msg.NFSPROC3_WRITE,
+		msg.NFS3_OK,
+		msg.WRITE3resok{
+			FileWcc:   x.wcc(fs, fh, before),
+			Count:     uint32(n),
+			Committed: committed,
+			Verf:      writeVerifier3,
+		},
+	)
+}
+
+func (x *Call3) Create(in, out Bytes) (uint32, error) { //nolint:funlen,gocognit
+	var args msg.CREATE3args
+
+	if err := xdr.NewDecoder(in).Decode(&args); err != nil {
+		return 0, err
+	}
+
+	fs := x.FS(x.Creds, [16]byte{})
+
+	defer fs.Close()
+
+	dir, status := x.resolve(fs, args.Where.Dir)
+	if status != msg.NFS3_OK {
+		return ProcedureResponse(out, msg.NFSPROC3_CREATE, status, msg.WccData3{})
+	}
+
+	x.Logger.Tracef("CREATE %s %s %d", dir.Path, args.Where.Name, args.How.Mode)
+
+	before := x.preOpAttr(fs, dir)
+
+	if status = checkName(args.Where.Name); status != msg.NFS3_OK {
+		return ProcedureResponse(out, msg.NFSPROC3_CREATE, status, x.wcc(fs, dir, before))
+	}
+
+	var (
+		path  = vfs.Join(dir.Path, args.Where.Name)
+		flag  = os.O_WRONLY | os.O_CREATE
+		mode  = os.FileMode(0o644)
+		attrs msg.Sattr3
+	)
+
+	switch args.How.Mode {
+	case msg.UNCHECKED:
+		attrs = args.How.ObjAttributes
+	case msg.GUARDED:
+		attrs = args.How.GuardedAttrs
+		flag |= os.O_EXCL
+	case msg.EXCLUSIVE:
+		flag |= os.O_EXCL
+	}
+
+	if attrs.Mode.SetIt != 0 {
+		mode = os.FileMode(attrs.Mode.Value) & os.ModePerm
+	}
+
+	// Only a file that is actually created inherits the default ACL of the directory
+	_, statErr := fs.Lstat(path)
+
+	f, err := fs.OpenFile(path, flag, mode)
+
+	switch {
+	case err == nil:
+		err = f.Close()
+	case args.How.Mode == msg.EXCLUSIVE && errors.Is(err, os.ErrExist):
+		// A retransmission of the same exclusive create succeeds
+		if fi, statErr := fs.Lstat(path); statErr == nil && fi.ModTime().Equal(exclusiveTime(args.How.CreateVerifier)) {
+			obj, fh := x.postOpFh(fs, path)
+
+			return ProcedureResponse(out,
+				msg.NFSPROC3_CREATE,
+				msg.NFS3_OK,
+				msg.DIROP3resok{
+					Obj:           obj,
+					ObjAttributes: x.postOpAttr(fs, fh),
+					DirWcc:        x.wcc(fs, dir, before),
+				},
+			)
+		}
+	case args.How.Mode == msg.UNCHECKED && errors.Is(err, syscall.EISDIR):
+		err = os.ErrExist
+	}
+
+	if err != nil {
+		DiscardOnServerFault(fs, err)
+
+		return ProcedureResponse(out, msg.NFSPROC3_CREATE, msg.Err2Status3(err), x.wcc(fs, dir, before))
+	}
+
+	if errors.Is(statErr, os.ErrNotExist) {
+		if err = inheritACL(fs, x.Logger, dir.Path, path, mode); err != nil {
+			return ProcedureResponse(out, msg.NFSPROC3_CREATE, msg.Err2Status3(err), x.wcc(fs, dir, before))
+		}
+	}
+
+	// The verifier is stored in the modification time, the client sets the attributes afterwards
+	if args.How.Mode == msg.EXCLUSIVE {
+		mtime := exclusiveTime(args.How.CreateVerifier)
+
+		err = fs.Chtimes(path, mtime, mtime)
+		DiscardOnServerFault(fs, err)
+	} else if fi, statErr := fs.Lstat(path); statErr != nil {
+		err = statErr
+	} else {
+		attrs.Mode.SetIt = 0
+
+		err = x.setAttrs(fs, path, fi, attrs)
+	}
+
+	if err != nil {
+		return ProcedureResponse(out, msg.NFSPROC3_CREATE, msg.Err2Status3(err), x.wcc(fs, dir, before))
+	}
+
+	obj, fh := x.postOpFh(fs, path)
+
+	return ProcedureResponse(out,
+		msg.NFSPROC3_CREATE,
+		msg.NFS3_OK,
+		msg.DIROP3resok{
+			Obj:           obj,
+			ObjAttributes: x.postOpAttr(fs, fh),
+			DirWcc:        x.wcc(fs, dir, before),
+		},
+	)
+}
+
+func (x *Call3) Mkdir(in, out Bytes) (uint32, error) {
+	var args msg.MKDIR3args
+
+	if err := xdr.NewDecoder(in).Decode(&args); err != nil {
+		return 0, err
+	}
+
+	fs := x.FS(x.Creds, [16]byte{})
+
+	defer fs.Close()
+
+	dir, status := x.resolve(fs, args.Where.Dir)
+	if status != msg.NFS3_OK {
+		return ProcedureResponse(out, msg.NFSPROC3_MKDIR, status, msg.WccData3{})
+	}
+
+	x.Logger.Tracef("MKDIR %s %s", dir.Path, args.Where.Name)
+
+	before := x.preOpAttr(fs, dir)
+
+	if status = checkName(args.Where.Name); status != msg.NFS3_OK {
+		return ProcedureResponse(out, msg.NFSPROC3_MKDIR, status, x.wcc(fs, dir, before))
+	}
+
+	path := vfs.Join(dir.Path, args.Where.Name)
+	mode := os.FileMode(0o755)
+
+	if args.Attributes.Mode.SetIt != 0 {
+		mode = os.FileMode(args.Attributes.Mode.Value) & os.ModePerm
+	}
+
+	err := fs.Mkdir(path, mode|os.ModeDir)
+	if err != nil {
+		DiscardOnServerFault(fs, err)
+
+		return ProcedureResponse(out, msg.NFSPROC3_MKDIR, msg.Err2Status3(err), x.wcc(fs, dir, before))
+	}
+
+	if err = inheritACL(fs, x.Logger, dir.Path, path, mode); err == nil {
+		var fi vfs.FileInfo
+
+		if fi, err = fs.Lstat(path); err == nil {
+			args.Attributes.Mode.SetIt = 0
+
+			err = x.setAttrs(fs, path, fi, args.Attributes)
+		}
+	}
+
+	if err != nil {
+		return ProcedureResponse(out, msg.NFSPROC3_MKDIR, msg.Err2Status3(err), x.wcc(fs, dir, before))
+	}
+
+	obj, fh := x.postOpFh(fs, path)
+
+	return ProcedureResponse(out,
+		msg.NFSPROC3_MKDIR,
+		msg.NFS3_OK,
+		msg.DIROP3resok{
+			Obj:           obj,
+			ObjAttributes: x.postOpAttr(fs, fh),
+			DirWcc:        x.wcc(fs, dir, before),
+		},
+	)
+}
+
+func (x *Call3) Symlink(in, out Bytes) (uint32, error) {
+	var args msg.SYMLINK3args
+
+	if err := xdr.NewDecoder(in).Decode(&args); err != nil {
+		return 0, err
+	}
+
+	fs := x.FS(x.Creds, [16]byte{})
+
+	defer fs.Close()
+
+	dir, status := x.resolve(fs, args.Where.Dir)
+	if status != msg.NFS3_OK {
+		return ProcedureResponse(out, msg.NFSPROC3_SYMLINK, status, msg.WccData3{})
+	}
+
+	x.Logger.Tracef("SYMLINK %s %s %s", dir.Path, args.Where.Name, args.Symlink.SymlinkData)
+
+	before := x.preOpAttr(fs, dir)
+
+	if status = checkName(args.Where.Name); status != msg.NFS3_OK {
+		return ProcedureResponse(out, msg.NFSPROC3_SYMLINK, status, x.wcc(fs, dir, before))
+	}
+
+	path := vfs.Join(dir.Path, args.Where.Name)
+
+	// The attributes of symbolic links are not used
+	if err := fs.Symlink(args.Symlink.SymlinkData, path); err != nil {
+		DiscardOnServerFault(fs, err)
+
+		return ProcedureResponse(out, msg.NFSPROC3_SYMLINK, msg.Err2Status3(err), x.wcc(fs, dir, before))
+	}
+
+	obj, fh := x.postOpFh(fs, path)
+
+	return ProcedureResponse(out,
+		msg.NFSPROC3_SYMLINK,
+		msg.NFS3_OK,
+		msg.DIROP3resok{
+			Obj:           obj,
+			ObjAttributes: x.postOpAttr(fs, fh),
+			DirWcc:        x.wcc(fs, dir, before),
+		},
+	)
+}
+
+func (x *Call3) Mknod(in, out Bytes) (uint32, error) {
+	var args msg.MKNOD3args
+
+	if err := xdr.NewDecoder(in).Decode(&args); err != nil {
+		return 0, err
+	}
+
+	fs := x.FS(x.Creds, [16]byte{})
+
+	defer fs.Close()
+
+	dir, status := x.resolve(fs, args.Where.Dir)
+	if status != msg.NFS3_OK {
+		return ProcedureResponse(out, msg.NFSPROC3_MKNOD, status, msg.WccData3{})
+	}
+
+	x.Logger.Tracef("MKNOD %s %s %d", dir.Path, args.Where.Name, args.What.Type)
+
+	// The file system has no way to create special files
+	status = msg.NFS3ERR_NOTSUPP
+
+	switch args.What.Type {
+	case msg.NF3REG, msg.NF3DIR, msg.NF3LNK:
+		status = msg.NFS3ERR_BADTYPE
+	}
+
+	return ProcedureResponse(out, msg.NFSPROC3_MKNOD, status, x.wcc(fs, dir, x.preOpAttr(fs, dir)))
+}
+
+func (x *Call3) Remove(in, out Bytes) (uint32, error) {
+	return x.remove(in, out, msg.NFSPROC3_REMOVE)
+}
+
+func (x *Call3) Rmdir(in, out Bytes) (uint32, error) {
+	return x.remove(in, out, msg.NFSPROC3_RMDIR)
+}
+
+// remove implements REMOVE and RMDIR, which have the same arguments and results.
+func (x *Call3) remove(in, out Bytes, proc uint32) (uint32, error) {
+	var args msg.REMOVE3args
+
+	if err := xdr.NewDecoder(in).Decode(&args); err != nil {
+		return 0, err
+	}
+
+	fs := x.FS(x.Creds, [16]byte{})
+
+	defer fs.Close()
+
+	dir, status := x.resolve(fs, args.Object.Dir)
+	if status != msg.NFS3_OK {
+		return ProcedureResponse(out, proc, status, msg.WccData3{})
+	}
+
+	x.Logger.Tracef("%s %s %s", strings.ToUpper(msg.Proc3Name(proc)), dir.Path, args.Object.Name)
+
+	before := x.preOpAttr(fs, dir)
+
+	switch args.Object.Name {
+	case ".":
+		return ProcedureResponse(out, proc, msg.NFS3ERR_INVAL, x.wcc(fs, dir, before))
+	case "..":
+		return ProcedureResponse(out, proc, msg.NFS3ERR_NOTEMPTY, x.wcc(fs, dir, before))
+	}
+
+	path := vfs.Join(dir.Path, args.Object.Name)
+
+	fi, err := fs.Lstat(path)
+
+	switch {
+	case err != nil:
+	case proc == msg.NFSPROC3_REMOVE && fi.IsDir():
+		err = syscall.EISDIR
+	case proc == msg.NFSPROC3_REMOVE:
+		err = fs.Remove(path)
+	case !fi.IsDir():
+		err = syscall.ENOTDIR
+	default:
+		err = fs.Rmdir(path)
+	}
+
+	if err != nil {
+		DiscardOnServerFault(fs, err)
+
+		return ProcedureResponse(out, proc, msg.Err2Status3(err), x.wcc(fs, dir, before))
+	}
+
+	return ProcedureResponse(out, proc, msg.NFS3_OK, x.wcc(fs, dir, before))
+}
+
+func (x *Call3) Rename(in, out Bytes) (uint32, error) { //nolint:funlen
+	var args msg.RENAME3args
+
+	if err := xdr.NewDecoder(in).Decode(&args); err != nil {
+		return 0, err
+	}
+
+	fs := x.FS(x.Creds, [16]byte{})
+
+	defer fs.Close()
+
+	from, status := x.resolve(fs, args.From.Dir)
+	if status != msg.NFS3_OK {
+		return ProcedureResponse(out, msg.NFSPROC3_RENAME, status, msg.RENAME3res{})
+	}
+
+	to, status := x.resolve(fs, args.To.Dir)
+	if status != msg.NFS3_OK {
+		return ProcedureResponse(out, msg.NFSPROC3_RENAME, status, msg.RENAME3res{})
+	}
+
+	x.Logger.Tracef("RENAME %s %s %s %s", from.Path, args.From.Name, to.Path, args.To.Name)
+
+	fromBefore := x.preOpAttr(fs, from)
+	toBefore := x.preOpAttr(fs, to)
+
+	result := func() msg.RENAME3res {
+		return msg.RENAME3res{
+			FromDirWcc: x.wcc(fs, from, fromBefore),
+			ToDirWcc:   x.wcc(fs, to, toBefore),
+		}
+	}
+
+	if status = checkName(args.From.Name); status == msg.NFS3_OK {
+		status = checkName(args.To.Name)
+	}
+
+	if status != msg.NFS3_OK {
+		return ProcedureResponse(out, msg.NFSPROC3_RENAME, status, result())
+	}
+
+	newPath := vfs.Join(to.Path, args.To.Name)
+
+	if err := fs.Rename(vfs.Join(from.Path, args.From.Name), newPath); err != nil {
+		DiscardOnServerFault(fs, err)
+
+		status = msg.Err2Status3(err)
+
+		if status == msg.NFS3ERR_NOTSUPP {
+			status = msg.NFS3ERR_XDEV
+		}
+
+		return ProcedureResponse(out, msg.NFSPROC3_RENAME, status, result())
+	}
+
+	// Clients keep using the handle of the renamed file, which is cached with the old path
+	if h, err := fs.Handle(newPath); err == nil {
+		fs.Cache.Invalidate(h)
+	}
+
+	return ProcedureResponse(out, msg.NFSPROC3_RENAME, msg.NFS3_OK, result())
+}
+
+func (x *Call3) Link(in, out Bytes) (uint32, error) {
+	var args msg.LINK3args
+
+	if err := xdr.NewDecoder(in).Decode(&args); err != nil {
+		return 0, err
+	}
+
+	fs := x.FS(x.Creds, [16]byte{})
+
+	defer fs.Close()
+
+	file, status := x.resolve(fs, args.File)
+	if status != msg.NFS3_OK {
+		return ProcedureResponse(out, msg.NFSPROC3_LINK, status, msg.LINK3res{})
+	}
+
+	dir, status := x.resolve(fs, args.Link.Dir)
+	if status != msg.NFS3_OK {
+		return ProcedureResponse(out, msg.NFSPROC3_LINK, status, msg.LINK3res{FileAttributes: x.postOpAttr(fs, file)})
+	}
+
+	x.Logger.Tracef("LINK %s %s %s", file.Path, dir.Path, args.Link.Name)
+
+	before := x.preOpAttr(fs, dir)
+
+	result := func() msg.LINK3res {
+		fs.Cache.Invalidate(file.Handle)
+
+		return msg.LINK3res{
+			FileAttributes: x.postOpAttr(fs, file),
+			LinkDirWcc:     x.wcc(fs, dir, before),
+		}
+	}
+
+	if status = checkName(args.Link.Name); status != msg.NFS3_OK {
+		return ProcedureResponse(out, msg.NFSPROC3_LINK, status, result())
+	}
+
+	if err := fs.Link(file.Path, vfs.Join(dir.Path, args.Link.Name)); err != nil {
+		DiscardOnServerFault(fs, err)
+
+		status = msg.Err2Status3(err)
+
+		if status == msg.NFS3ERR_NOTSUPP {
+			status = msg.NFS3ERR_XDEV
+		}
+
+		return ProcedureResponse(out, msg.NFSPROC3_LINK, status, result())
+	}
+
+	return ProcedureResponse(out, msg.NFSPROC3_LINK, msg.NFS3_OK, result())
+}
+
+// lister returns the lister of a directory to continue at the given cookie, and its cookie verifier.
+// NFSv3 clients keep cookies much longer than listers are kept, so listers that are already closed
+// are opened again and continue at the offset of the cookie.
+func (x *Call3) lister(fs *worker.Worker, dir *FileHandle, cookie, verf uint64) (vfs.ListerAt, uint64, int64, uint32) {
+	var offset int64
+
+	if cookie != 0 {
+		if cookie <= 1000 {
+			return nil, 0, 0, msg.NFS3ERR_BAD_COOKIE
+		}
+
+		offset = int64(cookie - 1000)
+
+		if stored, ok := fs.GetLister(verf); ok && verf != worker.EOFLister {
+			return stored.Lister, verf, offset, msg.NFS3_OK
+		}
+	}
+
+	lister, err := fs.List(dir.Path)
+	if err != nil {
+		DiscardOnServerFault(fs, err)
+
+		return nil, 0, 0, msg.Err2Status3(err)
+	}
+
+	return lister, fs.AddLister(&worker.Lister{Lister: lister}), offset, msg.NFS3_OK
+}
+
+// listAt lists the next batch of a directory. Listers are closed at the end of the directory,
+// and the verifier is replaced with worker.EOFLister, as for NFSv4.
+func (x *Call3) listAt(fs *worker.Worker, lister vfs.ListerAt, verf uint64, offset int64) ([]vfs.FileInfo, uint64, bool, error) {
+	batch := make([]vfs.FileInfo, 128)
+
+	n, err := lister.ListAt(batch, offset)
+	if err != nil && !errors.Is(err, io.EOF) {
+		fs.Discard()
+
+		return nil, verf, false, err
+	}
+
+	if errors.Is(err, io.EOF) {
+		if err = fs.CloseLister(verf); err != nil {
+			return nil, verf, false, err
+		}
+
+		verf = worker.EOFLister
+	}
+
+	return batch[:n], verf, verf == worker.EOFLister, nil
+}
+
+func (x *Call3) ReadDir(in, out Bytes) (uint32, error) { //nolint:funlen
+	var args msg.READDIR3args
+
+	if err := xdr.NewDecoder(in).Decode(&args); err != nil {
+		return 0, err
+	}
+
+	fs := x.FS(x.Creds, [16]byte{})
+
+	defer fs.Close()
+
+	dir, status := x.resolve(fs, args.Dir)
+	if status != msg.NFS3_OK {
+		return ProcedureResponse(out, msg.NFSPROC3_READDIR, status, msg.PostOpAttr3{})
+	}
+
+	x.Logger.Tracef("READDIR %s %d %d %d", dir.Path, args.Cookie, args.CookieVerf, args.Count)
+
+	lister, verf, offset, status := x.lister(fs, dir, args.Cookie, args.CookieVerf)
+	if status != msg.NFS3_OK {
+		return ProcedureResponse(out, msg.NFSPROC3_READDIR, status, x.postOpAttr(fs, dir))
+	}
+
+	list, verf, eof, err := x.listAt(fs, lister, verf, offset)
+	if err != nil {
+		return ProcedureResponse(out, msg.NFSPROC3_READDIR, msg.Err2Status3(err), x.postOpAttr(fs, dir))
+	}
+
+	dirAttrs := x.postOpAttr(fs, dir)
+
+	// status, attributes, verifier, end of list and eof
+	size := 4 + SizeOf(xdr.Marshal(dirAttrs)) + 8 + 4 + 4
+
+	var first, prev *msg.Entry3
+
+	for i, fi := range list {
+		path := vfs.Join(dir.Path, fi.Name())
+
+		h, err := fs.Handle(path)
+		if err != nil {
+			x.Logger.Warnf("failed to get handle: %s", err)
+		} else {
+			fs.Cache.Put(h, worker.Entry{
+				Path:     path,
+				FileInfo: fi,
+			})
+		}
+
+		entry := &msg.Entry3{
+			FileID: fileIDOf(h),
+			Name:   fi.Name(),
+			Cookie: uint64(offset) + 1000 + uint64(i) + 1, // the offset of the next entry if existing
+		}
+
+		if size += 4 + 8 + 4 + uint32(len(entry.Name)+xdr.Pad(len(entry.Name))) + 8; size > args.Count {
+			eof = false
+
+			break
+		}
+
+		if first == nil {
+			first = entry
+		} else {
+			prev.Next = entry
+		}
+
+		prev = entry
+	}
+
+	if first == nil && len(list) > 0 {
+		return ProcedureResponse(out, msg.NFSPROC3_READDIR, msg.NFS3ERR_TOOSMALL, dirAttrs)
+	}
+
+	return ProcedureResponse(out,
+		msg.NFSPROC3_READDIR,
+		msg.NFS3_OK,
+		msg.READDIR3resok{
+			DirAttributes: dirAttrs,
+			CookieVerf:    verf,
+			Reply: msg.DirList3{
+				Entries: first,
+				Eof:     eof,
+			},
+		},
+	)
+}
+
+func (x *Call3) ReadDirPlus(in, out Bytes) (uint32, error) { //nolint:funlen
+	var args msg.READDIRPLUS3args
+
+	if err := xdr.NewDecoder(in).Decode(&args); err != nil {
+		return 0, err
+	}
+
+	fs := x.FS(x.Creds, [16]byte{})
+
+	defer fs.Close()
+
+	dir, status := x.resolve(fs, args.Dir)
+	if status != msg.NFS3_OK {
+		return ProcedureResponse(out, msg.NFSPROC3_READDIRPLUS, status, msg.PostOpAttr3{})
+	}
+
+	x.Logger.Tracef("READDIRPLUS %s %d %d %d %d", dir.Path, args.Cookie, args.CookieVerf, args.DirCount, args.MaxCount)
+
+	lister, verf, offset, status := x.lister(fs, dir, args.Cookie, args.CookieVerf)
+	if status != msg.NFS3_OK {
+		return ProcedureResponse(out, msg.NFSPROC3_READDIRPLUS, status, x.postOpAttr(fs, dir))
+	}
+
+	list, verf, eof, err := x.listAt(fs, lister, verf, offset)
+	if err != nil {
+		return ProcedureResponse(out, msg.NFSPROC3_READDIRPLUS, msg.Err2Status3(err), x.postOpAttr(fs, dir))
+	}
+
+	dirAttrs := x.postOpAttr(fs, dir)
+
+	var (
+		first, prev *msg.EntryPlus3
+		dirCount    uint32
+		maxCount    = 4 + SizeOf(xdr.Marshal(dirAttrs)) + 8 + 4 + 4
+	)
+
+	for i, fi := range list {
+		path := vfs.Join(dir.Path, fi.Name())
+
+		entry := &msg.EntryPlus3{
+			Name:   fi.Name(),
+			Cookie: uint64(offset) + 1000 + uint64(i) + 1, // the offset of the next entry if existing
+		}
+
+		if h, err := fs.Handle(path); err != nil {
+			x.Logger.Warnf("failed to get handle: %s", err)
+		} else {
+			fs.Cache.Put(h, worker.Entry{
+				Path:     path,
+				FileInfo: fi,
+			})
+
+			entry.FileID = fileIDOf(h)
+			entry.NameAttributes = postOpAttr3(h, fi)
+
+			if fh, ok := x.wrap(h); ok {
+				entry.NameHandle = msg.PostOpFh3{HandleFollows: 1, Handle: fh}
+			}
+		}
+
+		dirCount += 8 + 4 + uint32(len(entry.Name)+xdr.Pad(len(entry.Name))) + 8
+		maxCount += 4 + SizeOf(xdr.Marshal(entry.FileID, entry.Name, entry.Cookie, entry.NameAttributes, entry.NameHandle))
+
+		if dirCount > args.DirCount || maxCount > args.MaxCount {
+			eof = false
+
+			break
+		}
+
+		if first == nil {
+			first = entry
+		} else {
+			prev.Next = entry
+		}
+
+		prev = entry
+	}
+
+	if first == nil && len(list) > 0 {
+		return ProcedureResponse(out, msg.NFSPROC3_READDIRPLUS, msg.NFS3ERR_TOOSMALL, dirAttrs)
+	}
+
+	return ProcedureResponse(out,
+		msg.NFSPROC3_READDIRPLUS,
+		msg.NFS3_OK,
+		msg.READDIRPLUS3resok{
+			DirAttributes: dirAttrs,
+			CookieVerf:    verf,
+			Reply: msg.DirListPlus3{
+				Entries: first,
+				Eof:     eof,
+			},
+		},
+	)
+}
+
+func (x *Call3) FSStat(in, out Bytes) (uint32, error) {
+	var args msg.FSSTAT3args
+
+	if err := xdr.NewDecoder(in).Decode(&args); err != nil {
+		return 0, err
+	}
+
+	fs := x.FS(x.Creds, [16]byte{})
+
+	defer fs.Close()
+
+	fh, status := x.resolve(fs, args.FsRoot)
+	if status != msg.NFS3_OK {
+		return ProcedureResponse(out, msg.NFSPROC3_FSSTAT, status, msg.PostOpAttr3{})
+	}
+
+	x.Logger.Tracef("FSSTAT %s", fh.Path)
+
+	// The file system does not report its capacity, as for the space attributes of NFSv4
+	const (
+		space = uint64(1) << 50
+		files = uint64(1) << 32
+	)
+
+	return ProcedureResponse(out,
+		msg.NFSPROC3_FSSTAT,
+		msg.NFS3_OK,
+		msg.FSSTAT3resok{
+			ObjAttributes: x.postOpAttr(fs, fh),
+			Tbytes:        space,
+			Fbytes:        space,
+			Abytes:        space,
+			Tfiles:        files,
+			Ffiles:        files,
+			Afiles:        files,
+		},
+	)
+}
+
+func (x *Call3) FSInfo(in, out Bytes) (uint32, error) {
+	var args msg.FSINFO3args
+
+	if err := xdr.NewDecoder(in).Decode(&args); err != nil {
+		return 0, err
+	}
+
+	fs := x.FS(x.Creds, [16]byte{})
+
+	defer fs.Close()
+
+	fh, status := x.resolve(fs, args.FsRoot)
+	if status != msg.NFS3_OK {
+		return ProcedureResponse(out, msg.NFSPROC3_FSINFO, status, msg.PostOpAttr3{})
+	}
+
+	x.Logger.Tracef("FSINFO %s", fh.Path)
+
+	return ProcedureResponse(out,
+		msg.NFSPROC3_FSINFO,
+		msg.NFS3_OK,
+		msg.FSINFO3resok{
+			ObjAttributes: x.postOpAttr(fs, fh),
+			Rtmax:         maxIO3,
+			Rtpref:        maxIO3,
+			Rtmult:        4096,
+			Wtmax:         maxIO3,
+			Wtpref:        maxIO3,
+			Wtmult:        4096,
+			Dtpref:        64 * 1024,
+			MaxFileSize:   math.MaxInt64,
+			TimeDelta:     msg.NfsTime3{NSeconds: 1},
+			Properties:    msg.FSF3_LINK | msg.FSF3_SYMLINK | msg.FSF3_HOMOGENEOUS | msg.FSF3_CANSETTIME,
+		},
+	)
+}
+
+func (x *Call3) PathConf(in, out Bytes) (uint32, error) {
+	var args msg.PATHCONF3args
+
+	if err := xdr.NewDecoder(in).Decode(&args); err != nil {
+		return 0, err
+	}
+
+	fs := x.FS(x.Creds, [16]byte{})
+
+	defer fs.Close()
+
+	fh, status := x.resolve(fs, args.Object)
+	if status != msg.NFS3_OK {
+		return ProcedureResponse(out, msg.NFSPROC3_PATHCONF, status, msg.PostOpAttr3{})
+	}
+
+	x.Logger.Tracef("PATHCONF %s", fh.Path)
+
+	return ProcedureResponse(out,
+		msg.NFSPROC3_PATHCONF,
+		msg.NFS3_OK,
+		msg.PATHCONF3resok{
+			ObjAttributes:   x.postOpAttr(fs, fh),
+			LinkMax:         math.MaxUint16,
+			NameMax:         maxName3,
+			NoTrunc:         true,
+			ChownRestricted: true,
+			CaseInsensitive: false,
+			CasePreserving:  true,
+		},
+	)
+}
+
+func (x *Call3) Commit(in, out Bytes) (uint32, error) {
+	var args msg.COMMIT3args
+
+	if err := xdr.NewDecoder(in).Decode(&args); err != nil {
+		return 0, err
+	}
+
+	fs := x.FS(x.Creds, [16]byte{})
+
+	defer fs.Close()
+
+	fh, status := x.resolve(fs, args.File)
+	if status != msg.NFS3_OK {
+		return ProcedureResponse(out, msg.NFSPROC3_COMMIT, status, msg.WccData3{})
+	}
+
+	x.Logger.Tracef("COMMIT %s %d %d", fh.Path, args.Offset, args.Count)
+
+	before := x.preOpAttr(fs, fh)
+
+	f, err := fs.FileRead(fh.Path)
+	if err != nil {
+		DiscardOnServerFault(fs, err)
+
+		return ProcedureResponse(out, msg.NFSPROC3_COMMIT, msg.Err2Status3(err), x.wcc(fs, fh, before))
+	}
+
+	defer f.Close()
+
+	// Syncing any file descriptor flushes the data written through the others
+	if s, ok := findFile[syncer](f); ok {
+		if err = s.Sync(); err != nil {
+			x.Logger.Errorf("failed to sync: %v", err)
+
+			return ProcedureResponse(out, msg.NFSPROC3_COMMIT, msg.Err2Status3(err), x.wcc(fs, fh, before))
+		}
+	}
+
+	return ProcedureResponse(out,
+		msg.NFSPROC3_COMMIT,
+		msg.NFS3_OK,
+		msg.COMMIT3resok{
+			FileWcc: x.wcc(fs, fh, before),
+			Verf:    writeVerifier3,
+		},
+	)
+}
@@ -2,21 +2,28 @@ package nfs4go
 
 import (
 	"bytes"
+	"context"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
+	"math"
+	"math/bits"
+	"net"
 	"os"
 	"slices"
-	"strconv"
-	"strings"
+	"sync/atomic"
 	"syscall"
 	"time"
 
+	"github.com/kuleuven/nfs4go/acl"
 	"github.com/kuleuven/nfs4go/auth"
 	"github.com/kuleuven/nfs4go/bufpool"
 	"github.com/kuleuven/nfs4go/clients"
 	"github.com/kuleuven/nfs4go/clock"
+	"github.com/kuleuven/nfs4go/handle"
+	"github.com/kuleuven/nfs4go/idmap"
+	"github.com/kuleuven/nfs4go/locks"
 	"github.com/kuleuven/nfs4go/logger"
 	"github.com/kuleuven/nfs4go/msg"
 	"github.com/kuleuven/nfs4go/worker"
@@ -26,8 +33,27 @@ import (
 )
 
 type Muxv4 struct {
-	Clients *clients.Clients
-	Logger  *logrus.Entry
+	Clients  *clients.Clients
+	Logger   *logrus.Entry
+	IDMapper *idmap.Mapper  // Translates owner and owner_group attributes
+	Signer   *handle.Signer // Signs file handles sent to clients, if set
+	Copies   *CopyEngine    // Keeps track of asynchronous copies
+	Addr     net.Addr       // Local address, returned by COPY_NOTIFY
+	Remote   net.Addr       // Remote address, checked for reads with a stateid from COPY_NOTIFY
+	Layout   *FlexLayout    // Layout offered to clients for pNFS, if set
+	Layouts  *Layouts       // Keeps track of the layouts handed out to clients
+	Xattrs   *XattrMapping  // Extended attributes exposed to clients, if not the default
+	Locks    *locks.Manager // Byte-range locks, shared with NLM
+
+	// Source servers of inter-server copies, see Export.CopySources
+	CopySources      []string
+	CopyReservedPort bool
+
+	// Act as a pNFS data server, which accepts I/O with the anonymous stateid
+	DataServer bool
+
+	// Connection used for callbacks to the client, if supported
+	Backchannel clients.Backchannel
 
 	// Retrieve a FS for the specified creds and sessionID.
 	// In case of a fatal error, Discard() is called to avoid to keep the FS in the pool.
@@ -36,8 +62,11 @@ type Muxv4 struct {
 }
 
 type FileHandle struct {
-	Handle []byte
-	Path   string
+	Handle   []byte
+	Path     string
+	Foreign  bool   // Handle of a file on the source server of an inter-server copy
+	AttrDir  bool   // Handle of the attribute directory of the file at Path
+	AttrName string // Handle of a named attribute of the file at Path
 }
 
 func (x *Muxv4) Handle(request Request, response chan<- Response) {
@@ -64,7 +93,13 @@ func (x *Muxv4) HandleProc(header *msg.RPCMsgCall, data Bytes) (*msg.RPCMsgReply
 	case msg.PROC4_COMPOUND:
 		return x.Compound(header, data)
 	default:
-		return nil, nil, fmt.Errorf("not implemented: %s", msg.Proc4Name(header.Proc))
+		data.Reset()
+
+		return &msg.RPCMsgReply{
+			Xid:       header.Xid,
+			MsgType:   msg.RPC_REPLY,
+			ReplyStat: msg.MSG_ACCEPTED,
+		}, data, xdr.NewEncoder(data).EncodeAll(msg.Auth{Flavor: msg.AUTH_FLAVOR_NULL, Body: []byte{}}, msg.ACCEPT_PROC_UNAVAIL)
 	}
 }
 
@@ -112,18 +147,22 @@ func (x *Muxv4) Compound(header *msg.RPCMsgCall, data Bytes) (*msg.RPCMsgReply,
 		opsCnt   uint32
 	)
 
+	reply := &msg.RPCMsgReply{
+		Xid:       header.Xid,
+		MsgType:   msg.RPC_REPLY,
+		ReplyStat: msg.MSG_ACCEPTED,
+	}
+
 	err = xdr.NewDecoder(data).DecodeAll(&tag, &minorVer, &opsCnt)
-	if err != nil {
+	if xdr.IsDecodeError(err) {
+		x.Logger.Warnf("failed to decode compound: %v", err)
+
+		return garbageArgs(reply, resp, bufpool.Get())
+	} else if err != nil {
 		return nil, nil, err
 	}
 
 	if minorVer > 2 {
-		reply := &msg.RPCMsgReply{
-			Xid:       header.Xid,
-			MsgType:   msg.RPC_REPLY,
-			ReplyStat: msg.MSG_ACCEPTED,
-		}
-
 		seq := []interface{}{
 			resp,
 			msg.ACCEPT_SUCCESS,
@@ -151,15 +190,20 @@ func (x *Muxv4) Compound(header *msg.RPCMsgCall, data Bytes) (*msg.RPCMsgReply,
 	dataOut := bufpool.Get()
 
 	err = compound.Run(data, dataOut)
-	if err != nil {
+
+	compound.closeOpened()
+
+	if xdr.IsDecodeError(err) {
+		x.Logger.Warnf("failed to decode compound: %v", err)
+
+		return garbageArgs(reply, resp, dataOut)
+	} else if err != nil {
+		dataOut.Discard()
+
 		return nil, nil, err
 	}
 
-	return &msg.RPCMsgReply{
-		Xid:       header.Xid,
-		MsgType:   msg.RPC_REPLY,
-		ReplyStat: msg.MSG_ACCEPTED,
-	}, dataOut, err
+	return reply, dataOut, nil
 }
 
 type Compound struct {
@@ -171,10 +215,25 @@ type Compound struct {
 	Creds    *auth.Creds // Credentials used for authentication
 
 	// Fields only valid within Compound
-	CurrentHandle *FileHandle
-	SavedHandle   *FileHandle
-	SessionID     [16]byte      // v4.1
-	Slot          *clients.Slot // v4.1
+	CurrentHandle  *FileHandle
+	SavedHandle    *FileHandle
+	CurrentStateID *msg.StateId4 // Stateid returned by the last OPEN, v4.1
+	SessionID      [16]byte      // v4.1
+	Slot           *clients.Slot // v4.1
+
+	opened []io.Closer // Files opened for I/O with the anonymous stateid
+}
+
+// attrContext returns the context used to encode attributes in this compound.
+func (x *Compound) attrContext(fs *worker.Worker) attrContext {
+	return attrContext{
+		Creds:     x.Creds,
+		SessionID: fs.SessionID,
+		IDMapper:  x.IDMapper,
+		Signer:    x.Signer,
+		Layout:    x.Layout,
+		Xattrs:    x.Xattrs,
+	}
 }
 
 var ErrNotImplemented = errors.New("not implemented")
@@ -185,6 +244,7 @@ var AllowedFirstOps41 = []uint32{
 	msg.OP4_SEQUENCE,
 	msg.OP4_BIND_CONN_TO_SESSION,
 	msg.OP4_EXCHANGE_ID,
+	msg.OP4_DESTROY_CLIENTID,
 }
 
 var FatalStatuses = []uint32{
@@ -194,9 +254,16 @@ var FatalStatuses = []uint32{
 	msg.NFS4ERR_NOTSUPP,
 	msg.NFS4ERR_FHEXPIRED,
 	msg.NFS4ERR_STALE,
+	msg.NFS4ERR_WRONG_TYPE,
+	msg.NFS4ERR_BADXDR,
 }
 
 func (x *Compound) Run(in, out Bytes) error {
+	// An empty compound is valid and has no results
+	if x.OpsCount == 0 {
+		return x.WriteHeader(out, 0, msg.NFS4_OK)
+	}
+
 	// Read first operation
 	var op uint32
 
@@ -244,7 +311,11 @@ func (x *Compound) Run(in, out Bytes) error {
 func (x *Compound) RunSequence(in, out Bytes) error { //nolint:funlen
 	var args msg.SEQUENCE4args
 
-	if err := xdr.NewDecoder(in).Decode(&args); err != nil {
+	if err := xdr.NewDecoder(in).Decode(&args); xdr.IsDecodeError(err) {
+		x.Logger.Warnf("failed to decode arguments of SEQUENCE: %v", err)
+
+		return x.WriteHeaderAndSingleOperation(out, msg.OP4_SEQUENCE, msg.NFS4ERR_BADXDR)
+	} else if err != nil {
 		return err
 	}
 
@@ -384,50 +455,55 @@ var NotImplementedRequiredOps = []uint32{
 	msg.OP4_BIND_CONN_TO_SESSION,
 	msg.OP4_FREE_STATEID,
 	msg.OP4_ILLEGAL,
-	msg.OP4_LOCK,
-	msg.OP4_LOCKT,
-	msg.OP4_LOCKU,
 	msg.OP4_SET_SSV,
 	msg.OP4_TEST_STATEID,
 }
 
 var NotImplementedOptionalOps = []uint32{
-	msg.OP4_ALLOCATE,
-	msg.OP4_CLONE,
-	msg.OP4_COPY,
-	msg.OP4_COPY_NOTIFY,
-	msg.OP4_DEALLOCATE,
 	msg.OP4_DELEGPURGE,
 	msg.OP4_DELEGRETURN,
-	msg.OP4_GETDEVICEINFO,
 	msg.OP4_GET_DIR_DELEGATION,
-	msg.OP4_IO_ADVISE,
-	msg.OP4_LAYOUTCOMMIT,
-	msg.OP4_LAYOUTERROR,
-	msg.OP4_LAYOUTGET,
-	msg.OP4_LAYOUTRETURN,
-	msg.OP4_LAYOUTSTATS,
-	msg.OP4_OFFLOAD_CANCEL,
-	msg.OP4_OFFLOAD_STATUS,
-	msg.OP4_OPENATTR,
-	msg.OP4_READ_PLUS,
-	msg.OP4_SEEK,
 	msg.OP4_WANT_DELEGATION,
-	msg.OP4_WRITE_SAME,
 }
 
 func (x *Compound) Operation(in, out Bytes) (uint32, error) {
 	var op uint32
 
 	err := xdr.NewDecoder(in).Decode(&op)
-	if err != nil {
+	if xdr.IsDecodeError(err) {
+		// The compound contains less operations than announced
+		x.Logger.Warnf("failed to decode operation: %v", err)
+
+		return OperationResponse(out, msg.OP4_ILLEGAL, msg.NFS4ERR_BADXDR)
+	} else if err != nil {
 		return 0, err
 	}
 
 	return x.doOperation(in, out, op)
 }
 
-func (x *Compound) doOperation(in, out Bytes, op uint32) (uint32, error) { //nolint:funlen,gocyclo
+// doOperation runs an operation. If its arguments cannot be decoded, its result is
+// replaced by NFS4ERR_BADXDR, which ends the compound but keeps the connection.
+func (x *Compound) doOperation(in, out Bytes, op uint32) (uint32, error) {
+	mark := len(out.Bytes())
+
+	status, err := x.runOperation(in, out, op)
+	if !xdr.IsDecodeError(err) {
+		return status, err
+	}
+
+	x.Logger.Warnf("failed to decode arguments of %s: %v", msg.Proc4Name(op), err)
+
+	out.SeekWrite(mark)
+
+	return OperationResponse(out, op, msg.NFS4ERR_BADXDR)
+}
+
+func (x *Compound) runOperation(in, out Bytes, op uint32) (uint32, error) { //nolint:funlen,gocyclo
+	if status, ok, err := x.namedAttrOperation(in, out, op); ok {
+		return status, err
+	}
+
 	switch op {
 	case msg.OP4_SETCLIENTID:
 		return x.SetClientID(in, out)
@@ -485,12 +561,24 @@ func (x *Compound) doOperation(in, out Bytes, op uint32) (uint32, error) { //nol
 		return x.SetAttr(in, out)
 	case msg.OP4_OPEN:
 		return x.Open(in, out)
+	case msg.OP4_OPENATTR:
+		return x.OpenAttr(in, out)
 	case msg.OP4_OPEN_DOWNGRADE:
 		return x.OpenDowngrade(in, out)
 	case msg.OP4_CLOSE:
 		return x.Close(in, out)
+	case msg.OP4_LOCK:
+		return x.Lock(in, out)
+	case msg.OP4_LOCKT:
+		return x.LockTest(in, out)
+	case msg.OP4_LOCKU:
+		return x.Unlock(in, out)
+	case msg.OP4_RELEASE_LOCKOWNER:
+		return x.ReleaseLockOwner(in, out)
 	case msg.OP4_READ:
 		return x.Read(in, out)
+	case msg.OP4_READ_PLUS:
+		return x.ReadPlus(in, out)
 	case msg.OP4_WRITE:
 		return x.Write(in, out)
 	case msg.OP4_COMMIT:
@@ -507,6 +595,40 @@ func (x *Compound) doOperation(in, out Bytes, op uint32) (uint32, error) { //nol
 		return x.ListXAttrs(in, out)
 	case msg.OP4_REMOVEXATTR:
 		return x.RemoveXAttr(in, out)
+	case msg.OP4_SEEK:
+		return x.Seek(in, out)
+	case msg.OP4_ALLOCATE:
+		return x.Allocate(in, out)
+	case msg.OP4_DEALLOCATE:
+		return x.Deallocate(in, out)
+	case msg.OP4_WRITE_SAME:
+		return x.WriteSame(in, out)
+	case msg.OP4_IO_ADVISE:
+		return x.IOAdvise(in, out)
+	case msg.OP4_COPY:
+		return x.Copy(in, out)
+	case msg.OP4_OFFLOAD_STATUS:
+		return x.OffloadStatus(in, out)
+	case msg.OP4_OFFLOAD_CANCEL:
+		return x.OffloadCancel(in, out)
+	case msg.OP4_COPY_NOTIFY:
+		return x.CopyNotify(in, out)
+	case msg.OP4_CLONE:
+		return x.Clone(in, out)
+	case msg.OP4_LAYOUTGET:
+		return x.LayoutGet(in, out)
+	case msg.OP4_LAYOUTRETURN:
+		return x.LayoutReturn(in, out)
+	case msg.OP4_LAYOUTCOMMIT:
+		return x.LayoutCommit(in, out)
+	case msg.OP4_GETDEVICEINFO:
+		return x.GetDeviceInfo(in, out)
+	case msg.OP4_GETDEVICELIST:
+		return x.GetDeviceList(in, out)
+	case msg.OP4_LAYOUTERROR:
+		return x.LayoutError(in, out)
+	case msg.OP4_LAYOUTSTATS:
+		return x.LayoutStats(in, out)
 	default: // Note: only return statuses listed in FatalStatuses
 		if slices.Contains(NotImplementedOptionalOps, op) {
 			x.Logger.Infof("we did not implement optional operation: %v", op)
@@ -600,7 +722,7 @@ func (x *Compound) ExchangeID(in, out Bytes) (uint32, error) {
 	if clientID, ok := x.Clients.GetByName(args.ClientOwner.OwnerId, args.ClientOwner.Verifier, x.Creds); ok {
 		return OperationResponse(out, msg.OP4_EXCHANGE_ID, msg.NFS4_OK, msg.EXCHANGE_ID4resok{
 			ClientID: clientID,
-			Flags:    msg.EXCHGID4_FLAG_USE_NON_PNFS | msg.EXCHGID4_FLAG_BIND_PRINC_STATEID | msg.EXCHGID4_FLAG_CONFIRMED_R,
+			Flags:    x.pnfsFlags() | msg.EXCHGID4_FLAG_BIND_PRINC_STATEID | msg.EXCHGID4_FLAG_CONFIRMED_R,
 			ServerOwner: msg.ServerOwner4{
 				MajorId: "sftp-nfs-4.1",
 			},
@@ -625,7 +747,7 @@ func (x *Compound) ExchangeID(in, out Bytes) (uint32, error) {
 	return OperationResponse(out, msg.OP4_EXCHANGE_ID, msg.NFS4_OK, msg.EXCHANGE_ID4resok{
 		ClientID:   clientID,
 		SequenceID: seqID,
-		Flags:      msg.EXCHGID4_FLAG_USE_NON_PNFS | msg.EXCHGID4_FLAG_BIND_PRINC_STATEID,
+		Flags:      x.pnfsFlags() | msg.EXCHGID4_FLAG_BIND_PRINC_STATEID,
 		ServerOwner: msg.ServerOwner4{
 			MajorId: "sftp-nfs-4.1",
 		},
@@ -652,6 +774,14 @@ func (x *Compound) CreateSession(in, out Bytes) (uint32, error) {
 
 	sessionID := client.BuildSession(args.Flags&msg.CREATE_SESSION4_FLAG_PERSIST != 0)
 
+	if args.Flags&msg.CREATE_SESSION4_FLAG_CONN_BACK_CHAN != 0 && x.Backchannel != nil {
+		client.SetCallback(sessionID, &clients.Callback{
+			Backchannel:  x.Backchannel,
+			Program:      args.CbProgram,
+			MinorVersion: x.MinorVer,
+		})
+	}
+
 	args.ForeChanAttrs.HeaderPadSize = 0
 	args.ForeChanAttrs.RdmaIrd = nil
 	args.BackChanAttrs.HeaderPadSize = 0
@@ -777,19 +907,49 @@ func (x *Compound) PutFH(in, out Bytes) (uint32, error) {
 
 	x.Logger.Tracef("PUTFH %s", hex.EncodeToString(args.Fh))
 
+	if len(args.Fh) > msg.NFS4_FHSIZE {
+		return OperationResponse(out, msg.OP4_PUTFH, msg.NFS4ERR_BADHANDLE)
+	}
+
+	// The handle of the source file of an inter-server copy belongs to the source server,
+	// which must be one of the configured source servers
+	if x.MinorVer >= 2 && len(x.copySources(interServerCopySource(in.Bytes()))) > 0 {
+		x.CurrentHandle = &FileHandle{
+			Handle:  args.Fh,
+			Foreign: true,
+		}
+
+		return OperationResponse(out, msg.OP4_PUTFH, msg.NFS4_OK)
+	}
+
+	// Verify the handle before it is passed to the file system
+	fh, err := x.Signer.Unwrap(args.Fh)
+	if err != nil {
+		return OperationResponse(out, msg.OP4_PUTFH, msg.Err2Status(err))
+	}
+
 	fs := x.FS(x.Creds, x.SessionID)
 
 	defer fs.Close()
 
-	var (
-		path string
-		err  error
-	)
+	// Attribute directories and named attributes are found through the handle of their file
+	base, _, _, named := splitNamedAttrHandle(fh)
+	if !named {
+		base = fh
+	}
+
+	var path string
 
-	if fi, cached := fs.Cache.Get(args.Fh); cached {
+	if fi, cached := fs.Cache.Get(base); cached {
 		path = fi.Path
 	} else {
-		path, err = fs.Path(args.Fh)
+		path, err = fs.Path(base)
+	}
+
+	var current *FileHandle
+
+	if err == nil && named {
+		current, err = resolveNamedAttr(fs, fh, path, x.Xattrs)
 	}
 
 	if err != nil {
@@ -802,11 +962,15 @@ func (x *Compound) PutFH(in, out Bytes) (uint32, error) {
 		return OperationResponse(out, msg.OP4_PUTFH, msg.Err2Status(err))
 	}
 
-	x.CurrentHandle = &FileHandle{
-		Handle: args.Fh,
-		Path:   path,
+	if current == nil {
+		current = &FileHandle{
+			Handle: fh,
+			Path:   path,
+		}
 	}
 
+	x.CurrentHandle = current
+
 	return OperationResponse(out, msg.OP4_PUTFH, msg.NFS4_OK)
 }
 
@@ -817,15 +981,33 @@ func (x *Compound) GetFH(in, out Bytes) (uint32, error) {
 		return OperationResponse(out, msg.OP4_GETFH, msg.NFS4ERR_NOFILEHANDLE)
 	}
 
+	fh, ok := x.wrap(x.CurrentHandle.Handle)
+	if !ok {
+		return OperationResponse(out, msg.OP4_GETFH, msg.NFS4ERR_SERVERFAULT)
+	}
+
 	return OperationResponse(out,
 		msg.OP4_GETFH,
 		msg.NFS4_OK,
 		msg.GETFH4resok{
-			Fh: x.CurrentHandle.Handle,
+			Fh: fh,
 		},
 	)
 }
 
+// wrap signs a handle to send it to the client.
+func (x *Compound) wrap(h []byte) ([]byte, bool) {
+	fh := x.Signer.Wrap(h)
+
+	if len(fh) > msg.NFS4_FHSIZE {
+		x.Logger.Warnf("file handle of %d bytes is too long for nfs v4", len(fh))
+
+		return nil, false
+	}
+
+	return fh, true
+}
+
 func (x *Compound) SaveFH(in, out Bytes) (uint32, error) {
 	x.Logger.Trace("SAVEFH")
 
@@ -854,6 +1036,14 @@ func (x *Compound) RestoreFH(in, out Bytes) (uint32, error) {
 		)
 	}
 
+	// Handles of files on another server can only be used by COPY
+	if x.SavedHandle.Foreign {
+		return OperationResponse(out,
+			msg.OP4_RESTOREFH,
+			msg.NFS4ERR_STALE,
+		)
+	}
+
 	x.CurrentHandle = x.SavedHandle
 
 	return OperationResponse(out,
@@ -885,7 +1075,7 @@ func (x *Compound) GetAttr(in, out Bytes) (uint32, error) {
 	defer fs.Close()
 
 	if fi, cached := fs.Cache.Get(x.CurrentHandle.Handle); cached {
-		attrs := fileInfoToAttrs(x.CurrentHandle.Handle, fi, nil, idxReq, x.Creds, fs.SessionID)
+		attrs := fileInfoToAttrs(x.CurrentHandle.Handle, fi, nil, idxReq, x.attrContext(fs))
 
 		return OperationResponse(out,
 			msg.OP4_GETATTR,
@@ -896,7 +1086,7 @@ func (x *Compound) GetAttr(in, out Bytes) (uint32, error) {
 		)
 	}
 
-	fi, err := fs.Lstat(x.CurrentHandle.Path)
+	fi, err := x.lstat(fs, x.CurrentHandle)
 	if err != nil {
 		DiscardOnServerFault(fs, err)
 
@@ -911,7 +1101,7 @@ func (x *Compound) GetAttr(in, out Bytes) (uint32, error) {
 		FileInfo: fi,
 	})
 
-	attrs := fileInfoToAttrs(x.CurrentHandle.Handle, fi, nil, idxReq, x.Creds, fs.SessionID)
+	attrs := fileInfoToAttrs(x.CurrentHandle.Handle, fi, nil, idxReq, x.attrContext(fs))
 
 	return OperationResponse(out,
 		msg.OP4_GETATTR,
@@ -961,6 +1151,13 @@ func (x *Compound) Lookup(in, out Bytes) (uint32, error) {
 		)
 	}
 
+	if _, ok := x.wrap(handle); !ok {
+		return OperationResponse(out,
+			msg.OP4_LOOKUP,
+			msg.NFS4ERR_NAMETOOLONG,
+		)
+	}
+
 	x.CurrentHandle = &FileHandle{
 		Handle: handle,
 		Path:   path,
@@ -1043,7 +1240,7 @@ func (x *Compound) Access(in, out Bytes) (uint32, error) { //nolint:funlen
 	if !cached {
 		var err error
 
-		fi, err = fs.Lstat(x.CurrentHandle.Path)
+		fi, err = x.lstat(fs, x.CurrentHandle)
 		if err != nil {
 			DiscardOnServerFault(fs, err)
 
@@ -1059,45 +1256,15 @@ func (x *Compound) Access(in, out Bytes) (uint32, error) { //nolint:funlen
 		})
 	}
 
-	support := msg.ACCESS4_READ
-	support |= msg.ACCESS4_LOOKUP
-	support |= msg.ACCESS4_MODIFY
-	support |= msg.ACCESS4_EXTEND
-	support |= msg.ACCESS4_DELETE
-	support |= msg.ACCESS4_EXECUTE
-	support |= msg.ACCESS4_XAREAD
-	support |= msg.ACCESS4_XAWRITE
-	support |= msg.ACCESS4_XALIST
-
-	perm := (uint32(fi.Mode()) >> 6) & uint32(0b0111)
-
-	r := perm & (uint32(1) << 2)
-	w := perm & (uint32(1) << 1)
-	xe := perm & uint32(1)
-
-	accForFh := uint32(0)
-
-	if r > 0 {
-		accForFh |= msg.ACCESS4_READ
-		accForFh |= msg.ACCESS4_LOOKUP
-		accForFh |= msg.ACCESS4_XAREAD
-		accForFh |= msg.ACCESS4_XALIST
-	}
-
-	if w > 0 {
-		accForFh |= msg.ACCESS4_MODIFY
-		accForFh |= msg.ACCESS4_EXTEND
-		accForFh |= msg.ACCESS4_DELETE
-		accForFh |= msg.ACCESS4_XAWRITE
-	}
+	support, accForFh := accessFor(fi, x.Creds)
 
-	if xe > 0 {
-		accForFh |= msg.ACCESS4_LOOKUP
-		accForFh |= msg.ACCESS4_EXECUTE
+	// The bits for extended attributes are an extension of v4.2
+	if x.MinorVer < 2 {
+		support &^= msg.ACCESS4_XAREAD | msg.ACCESS4_XAWRITE | msg.ACCESS4_XALIST
 	}
 
 	support &= args.Access
-	accForFh &= args.Access
+	accForFh &= support
 
 	return OperationResponse(out,
 		msg.OP4_ACCESS,
@@ -1215,7 +1382,7 @@ func (x *Compound) ReadDir(in, out Bytes) (uint32, error) { //nolint:funlen,goco
 		entry := &msg.Entry4{
 			Cookie: offset + 1000 + uint64(i) + 1, // the offset of the next entry if existing
 			Name:   fi.Name(),
-			Attrs:  fileInfoToAttrs(fh, fi, handleErr, idxReq, x.Creds, fs.SessionID),
+			Attrs:  fileInfoToAttrs(fh, fi, handleErr, idxReq, x.attrContext(fs)),
 		}
 
 		if first == nil {
@@ -1401,6 +1568,15 @@ func (x *Compound) Create(in, out Bytes) (uint32, error) { //nolint:funlen
 				msg.Err2Status(err),
 			)
 		}
+
+		if err = inheritACL(fs, x.Logger, x.CurrentHandle.Path, path, mode); err != nil {
+			x.removeCreated(fs, path)
+
+			return OperationResponse(out,
+				msg.OP4_CREATE,
+				msg.Err2Status(err),
+			)
+		}
 	case msg.NF4REG: // Create is not allowed for regular files
 		return OperationResponse(out,
 			msg.OP4_CREATE,
@@ -1430,6 +1606,36 @@ func (x *Compound) Create(in, out Bytes) (uint32, error) { //nolint:funlen
 
 	fs.Cache.Invalidate(x.CurrentHandle.Handle)
 
+	attrSet := map[int]bool{A_mode: true}
+
+	owners, err := x.setOwner(fs, path, decAttrs)
+	if err != nil {
+		x.removeCreated(fs, path)
+
+		return OperationResponse(out,
+			msg.OP4_CREATE,
+			msg.Err2Status(err),
+		)
+	}
+
+	for _, a := range owners {
+		attrSet[int(a)] = true
+	}
+
+	posix, err := x.setPosixACLs(fs, path, decAttrs)
+	if err != nil {
+		x.removeCreated(fs, path)
+
+		return OperationResponse(out,
+			msg.OP4_CREATE,
+			msg.Err2Status(err),
+		)
+	}
+
+	for _, a := range posix {
+		attrSet[int(a)] = true
+	}
+
 	handle, err := fs.Handle(path)
 	if err != nil {
 		DiscardOnServerFault(fs, err)
@@ -1440,6 +1646,15 @@ func (x *Compound) Create(in, out Bytes) (uint32, error) { //nolint:funlen
 		)
 	}
 
+	if _, ok := x.wrap(handle); !ok {
+		x.removeCreated(fs, path)
+
+		return OperationResponse(out,
+			msg.OP4_CREATE,
+			msg.NFS4ERR_NAMETOOLONG,
+		)
+	}
+
 	x.CurrentHandle = &FileHandle{
 		Handle: handle,
 		Path:   path,
@@ -1450,7 +1665,7 @@ func (x *Compound) Create(in, out Bytes) (uint32, error) { //nolint:funlen
 		msg.NFS4_OK,
 		msg.CREATE4resok{
 			CInfo:   msg.ChangeInfo4{}, // non-atomic change
-			AttrSet: []uint32{A_mode},  // We only support mode
+			AttrSet: bitmap4Encode(attrSet),
 		},
 	)
 }
@@ -1709,54 +1924,24 @@ func (x *Compound) SetAttr(in, out Bytes) (uint32, error) { //nolint:funlen,goco
 		changed = append(changed, A_mode)
 	}
 
-	var uid, gid uint32
-
-	if (decAttrs.Owner != "") != (decAttrs.OwnerGroup != "") {
-		fi, argErr := fs.Lstat(x.CurrentHandle.Path)
-		if argErr != nil {
-			DiscardOnServerFault(fs, argErr)
-
-			return OperationResponse(out, msg.OP4_SETATTR, msg.Err2Status(argErr))
-		}
-
-		uid = fi.Uid()
-		gid = fi.Gid()
-	}
-
-	if decAttrs.Owner != "" {
-		uid64, err := strconv.ParseUint(decAttrs.Owner, 10, 32)
-		if err != nil {
-			x.Logger.Warnf("failed to parse uid: %v", err)
-
-			return OperationResponse(out, msg.OP4_SETATTR, msg.Err2Status(err))
-		}
-
-		uid = uint32(uid64)
-
-		changed = append(changed, A_owner)
+	if owners, err := x.setOwner(fs, x.CurrentHandle.Path, decAttrs); err != nil {
+		return OperationResponse(out, msg.OP4_SETATTR, msg.Err2Status(err))
+	} else {
+		changed = append(changed, owners...)
 	}
 
-	if decAttrs.OwnerGroup != "" {
-		gid64, err := strconv.ParseUint(decAttrs.OwnerGroup, 10, 32)
-		if err != nil {
-			x.Logger.Warnf("failed to parse uid: %v", err)
-
+	if decAttrs.ACL != nil {
+		if err := x.setACL(fs, x.CurrentHandle.Path, decAttrs.ACL); err != nil {
 			return OperationResponse(out, msg.OP4_SETATTR, msg.Err2Status(err))
 		}
 
-		gid = uint32(gid64)
-
-		changed = append(changed, A_owner_group)
+		changed = append(changed, A_acl)
 	}
 
-	if decAttrs.Owner != "" || decAttrs.OwnerGroup != "" {
-		if err := fs.Chown(x.CurrentHandle.Path, int(uid), int(gid)); err != nil {
-			DiscardOnServerFault(fs, err)
-
-			x.Logger.Warnf("failed to chown: %v", err)
-
-			return OperationResponse(out, msg.OP4_SETATTR, msg.Err2Status(err))
-		}
+	if posix, err := x.setPosixACLs(fs, x.CurrentHandle.Path, decAttrs); err != nil {
+		return OperationResponse(out, msg.OP4_SETATTR, msg.Err2Status(err))
+	} else {
+		changed = append(changed, posix...)
 	}
 
 	if decAttrs.Size != nil {
@@ -1794,87 +1979,337 @@ func (x *Compound) SetAttr(in, out Bytes) (uint32, error) { //nolint:funlen,goco
 	return OperationResponse(out, msg.OP4_SETATTR, msg.NFS4_OK, changed)
 }
 
-func (x *Compound) Open(in, out Bytes) (uint32, error) { //nolint:funlen,gocognit,gocyclo
-	var args msg.OPEN4args
-
-	if err := xdr.NewDecoder(in).Decode(&args); err != nil {
-		return 0, err
-	}
-
-	if x.MinorVer > 0 {
-		args.Owner.ClientId = clients.ClientIDFromSessionID(x.SessionID)
-		args.SeqID = x.Slot.SequenceID*clients.MaxSlotID + x.Slot.SlotID
+// setOwner changes the owner and group of the given path, if requested in decAttrs.
+// Owner strings are translated using the IDMapper. It returns the changed attributes.
+func (x *Compound) setOwner(fs *worker.Worker, path string, decAttrs *Attr) ([]uint32, error) {
+	if decAttrs.Owner == "" && decAttrs.OwnerGroup == "" {
+		return nil, nil
 	}
 
-	x.Logger.Tracef("OPEN %d %+v", args.SeqID, args)
-
 	var (
-		flag int
-		mode = os.FileMode(0o644)
+		uid, gid uint32
+		changed  []uint32
 	)
 
-	switch args.ShareAccess & msg.OPEN4_SHARE_ACCESS_BOTH {
-	case msg.OPEN4_SHARE_ACCESS_READ:
-		flag |= os.O_RDONLY
-	case msg.OPEN4_SHARE_ACCESS_WRITE:
-		flag |= os.O_WRONLY
-	case msg.OPEN4_SHARE_ACCESS_BOTH:
-		flag |= os.O_RDWR
+	if decAttrs.Owner == "" || decAttrs.OwnerGroup == "" {
+		fi, err := fs.Lstat(path)
+		if err != nil {
+			DiscardOnServerFault(fs, err)
+
+			return nil, err
+		}
+
+		uid = fi.Uid()
+		gid = fi.Gid()
 	}
 
-	if args.OpenHow.How == msg.OPEN4_CREATE {
-		flag |= os.O_CREATE
+	if decAttrs.Owner != "" {
+		var err error
 
-		var decAttrs *Attr
+		uid, err = x.IDMapper.ParseOwner(decAttrs.Owner)
+		if err != nil {
+			x.Logger.Warnf("failed to parse owner %s: %v", decAttrs.Owner, err)
 
-		switch args.OpenHow.Claim.CreateMode {
-		case msg.EXCLUSIVE4:
-			// TODO: If file already exists and verifier matches, continue
-			flag |= os.O_EXCL
-		case msg.GUARDED4:
-			flag |= os.O_EXCL
+			return nil, err
+		}
 
-			var err error
+		changed = append(changed, A_owner)
+	}
 
-			decAttrs, err = decodeFAttrs4(args.OpenHow.Claim.CreateAttrsGuarded)
-			if err != nil {
-				return 0, err
-			}
+	if decAttrs.OwnerGroup != "" {
+		var err error
 
-			mode = os.FileMode(*decAttrs.Mode) & os.ModePerm
+		gid, err = x.IDMapper.ParseGroup(decAttrs.OwnerGroup)
+		if err != nil {
+			x.Logger.Warnf("failed to parse owner group %s: %v", decAttrs.OwnerGroup, err)
 
-			if decAttrs.Size != nil && *decAttrs.Size == 0 {
-				flag |= os.O_TRUNC
-			}
-		case msg.UNCHECKED4:
-			var err error
+			return nil, err
+		}
 
-			decAttrs, err = decodeFAttrs4(args.OpenHow.Claim.CreateAttrsUnchecked)
-			if err != nil {
-				return 0, err
-			}
+		changed = append(changed, A_owner_group)
+	}
 
-			mode = os.FileMode(*decAttrs.Mode) & os.ModePerm
+	if err := fs.Chown(path, int(uid), int(gid)); err != nil {
+		DiscardOnServerFault(fs, err)
 
-		case msg.EXCLUSIVE4_1:
-			// TODO: If file already exists and verifier matches, continue
-			flag |= os.O_EXCL
+		x.Logger.Warnf("failed to chown: %v", err)
 
-			var err error
+		return nil, err
+	}
 
-			decAttrs, err = decodeFAttrs4(args.OpenHow.Claim.CreateVerf41.Attrs)
-			if err != nil {
-				return 0, err
-			}
+	return changed, nil
+}
 
-			mode = os.FileMode(*decAttrs.Mode) & os.ModePerm
+// removeCreated removes an object that was just created by CREATE or OPEN, if its attributes
+// cannot be set, so that the failed operation does not leave it behind with default attributes.
+func (x *Compound) removeCreated(fs *worker.Worker, path string) {
+	err := fs.Remove(path)
+	if err != nil && fs.Rmdir(path) == nil {
+		err = nil
+	}
 
-		default:
-			return 0, fmt.Errorf("unsupported create mode: %d", args.OpenHow.Claim.CreateMode)
-		}
+	if err != nil {
+		DiscardOnServerFault(fs, err)
+
+		x.Logger.Warnf("failed to remove %s: %v", path, err)
 	}
+}
 
-	path := x.CurrentHandle.Path
+// setACL stores an NFSv4 ACL as POSIX ACL extended attributes.
+func (x *Compound) setACL(fs *worker.Worker, path string, aces []msg.NfsAce4) error {
+	fi, err := fs.Lstat(path)
+	if err != nil {
+		DiscardOnServerFault(fs, err)
+
+		return err
+	}
+
+	access, def, err := acl.FromNFS4(aces, fi.IsDir(), x.IDMapper)
+	if err != nil {
+		x.Logger.Warnf("failed to convert acl %v: %v", aces, err)
+
+		return err
+	}
+
+	return storeACLs(fs, x.Logger, path, fi, access, def, fi.IsDir())
+}
+
+// setPosixACLs stores the posix_access_acl and posix_default_acl attributes, if requested
+// in decAttrs. An empty posix_default_acl removes the default ACL. It returns the changed attributes.
+func (x *Compound) setPosixACLs(fs *worker.Worker, path string, decAttrs *Attr) ([]uint32, error) {
+	if decAttrs.PosixACL == nil && decAttrs.PosixDefaultACL == nil {
+		return nil, nil
+	}
+
+	fi, err := fs.Lstat(path)
+	if err != nil {
+		DiscardOnServerFault(fs, err)
+
+		return nil, err
+	}
+
+	var (
+		access, def acl.ACL
+		changed     []uint32
+	)
+
+	if decAttrs.PosixACL != nil {
+		access, err = acl.FromPosix(decAttrs.PosixACL, x.IDMapper)
+		if err != nil {
+			x.Logger.Warnf("failed to convert posix acl %v: %v", decAttrs.PosixACL, err)
+
+			return nil, err
+		}
+
+		changed = append(changed, A_posix_access_acl)
+	}
+
+	if len(decAttrs.PosixDefaultACL) > 0 {
+		if !fi.IsDir() {
+			return nil, acl.ErrInvalidPosix
+		}
+
+		def, err = acl.FromPosix(decAttrs.PosixDefaultACL, x.IDMapper)
+		if err != nil {
+			x.Logger.Warnf("failed to convert posix default acl %v: %v", decAttrs.PosixDefaultACL, err)
+
+			return nil, err
+		}
+	}
+
+	if decAttrs.PosixDefaultACL != nil {
+		changed = append(changed, A_posix_default_acl)
+	}
+
+	return changed, storeACLs(fs, x.Logger, path, fi, access, def, decAttrs.PosixDefaultACL != nil && fi.IsDir())
+}
+
+// storeACLs stores the access ACL, if not nil, and the default ACL, if setDefault is set,
+// as POSIX ACL extended attributes. The mode is updated to reflect the access ACL,
+// and access ACLs that are equivalent to the mode are not stored.
+func storeACLs(fs *worker.Worker, log *logrus.Entry, path string, fi vfs.FileInfo, access, def acl.ACL, setDefault bool) error {
+	attrs, err := fi.Extended()
+	if err != nil {
+		DiscardOnServerFault(fs, err)
+
+		return err
+	}
+
+	if access != nil {
+		mode, equivalent := access.Mode()
+
+		if err = fs.Chmod(path, fi.Mode()&(os.ModeSetuid|os.ModeSetgid|os.ModeSticky)|mode); err != nil {
+			DiscardOnServerFault(fs, err)
+
+			log.Warnf("failed to chmod: %v", err)
+
+			return err
+		}
+
+		if err = setPosixACL(fs, path, attrs, acl.XattrAccess, access, !equivalent); err != nil {
+			log.Warnf("failed to set access acl: %v", err)
+
+			return err
+		}
+	}
+
+	if !setDefault {
+		return nil
+	}
+
+	if err = setPosixACL(fs, path, attrs, acl.XattrDefault, def, def != nil); err != nil {
+		log.Warnf("failed to set default acl: %v", err)
+
+		return err
+	}
+
+	return nil
+}
+
+// inheritACL applies the default ACL of the parent directory to a newly created file or
+// directory, if the file system did not already do so. The mode is the requested create mode.
+func inheritACL(fs *worker.Worker, log *logrus.Entry, parent, path string, mode os.FileMode) error {
+	pfi, err := fs.Lstat(parent)
+	if err != nil {
+		DiscardOnServerFault(fs, err)
+
+		return err
+	}
+
+	_, def := posixACLs(pfi)
+	if def == nil {
+		return nil
+	}
+
+	fi, err := fs.Lstat(path)
+	if err != nil {
+		DiscardOnServerFault(fs, err)
+
+		return err
+	}
+
+	if fi.Mode()&os.ModeSymlink != 0 {
+		return nil
+	}
+
+	access := def.Inherit(mode)
+
+	current, currentDef := posixACLs(fi)
+
+	if slices.Equal(current, access) && (!fi.IsDir() || slices.Equal(currentDef, def)) {
+		return nil
+	}
+
+	return storeACLs(fs, log, path, fi, access, def, fi.IsDir())
+}
+
+// setPosixACL stores the given ACL in the named extended attribute if store is set,
+// otherwise the extended attribute is removed if the file has it.
+func setPosixACL(fs *worker.Worker, path string, attrs vfs.Attributes, name string, list acl.ACL, store bool) error {
+	var err error
+
+	if store {
+		err = fs.SetExtendedAttr(path, name, list.Bytes())
+	} else if _, ok := attrs[name]; ok {
+		err = fs.UnsetExtendedAttr(path, name)
+	}
+
+	DiscardOnServerFault(fs, err)
+
+	if errors.Is(err, syscall.EOPNOTSUPP) {
+		return acl.ErrNotSupported
+	}
+
+	return err
+}
+
+func (x *Compound) Open(in, out Bytes) (uint32, error) { //nolint:funlen,gocognit,gocyclo
+	var args msg.OPEN4args
+
+	if err := xdr.NewDecoder(in).Decode(&args); err != nil {
+		return 0, err
+	}
+
+	if x.MinorVer > 0 {
+		args.Owner.ClientId = clients.ClientIDFromSessionID(x.SessionID)
+		args.SeqID = x.Slot.SequenceID*clients.MaxSlotID + x.Slot.SlotID
+	}
+
+	x.Logger.Tracef("OPEN %d %+v", args.SeqID, args)
+
+	var (
+		flag int
+		mode = os.FileMode(0o644)
+	)
+
+	switch args.ShareAccess & msg.OPEN4_SHARE_ACCESS_BOTH {
+	case msg.OPEN4_SHARE_ACCESS_READ:
+		flag |= os.O_RDONLY
+	case msg.OPEN4_SHARE_ACCESS_WRITE:
+		flag |= os.O_WRONLY
+	case msg.OPEN4_SHARE_ACCESS_BOTH:
+		flag |= os.O_RDWR
+	}
+
+	var decAttrs *Attr
+
+	if args.OpenHow.How == msg.OPEN4_CREATE {
+		flag |= os.O_CREATE
+
+		switch args.OpenHow.Claim.CreateMode {
+		case msg.EXCLUSIVE4:
+			// TODO: If file already exists and verifier matches, continue
+			flag |= os.O_EXCL
+		case msg.GUARDED4:
+			flag |= os.O_EXCL
+
+			var err error
+
+			decAttrs, err = decodeFAttrs4(args.OpenHow.Claim.CreateAttrsGuarded)
+			if err != nil {
+				return 0, err
+			}
+
+			if decAttrs.Mode != nil {
+				mode = os.FileMode(*decAttrs.Mode) & os.ModePerm
+			}
+
+			if decAttrs.Size != nil && *decAttrs.Size == 0 {
+				flag |= os.O_TRUNC
+			}
+		case msg.UNCHECKED4:
+			var err error
+
+			decAttrs, err = decodeFAttrs4(args.OpenHow.Claim.CreateAttrsUnchecked)
+			if err != nil {
+				return 0, err
+			}
+
+			if decAttrs.Mode != nil {
+				mode = os.FileMode(*decAttrs.Mode) & os.ModePerm
+			}
+
+		case msg.EXCLUSIVE4_1:
+			// TODO: If file already exists and verifier matches, continue
+			flag |= os.O_EXCL
+
+			var err error
+
+			decAttrs, err = decodeFAttrs4(args.OpenHow.Claim.CreateVerf41.Attrs)
+			if err != nil {
+				return 0, err
+			}
+
+			if decAttrs.Mode != nil {
+				mode = os.FileMode(*decAttrs.Mode) & os.ModePerm
+			}
+
+		default:
+			return 0, fmt.Errorf("unsupported create mode: %d", args.OpenHow.Claim.CreateMode)
+		}
+	}
+
+	path := x.CurrentHandle.Path
 
 	switch args.OpenClaim.Claim {
 	case msg.CLAIM_NULL:
@@ -1927,7 +2362,7 @@ func (x *Compound) Open(in, out Bytes) (uint32, error) { //nolint:funlen,gocogni
 					Other: FileOther(fileID, args.SeqID),
 				},
 				CInfo:   msg.ChangeInfo4{},
-				Rflags:  msg.OPEN4_RESULT_PRESERVE_UNLINKED,
+				Rflags:  msg.OPEN4_RESULT_PRESERVE_UNLINKED | msg.OPEN4_RESULT_LOCKTYPE_POSIX,
 				AttrSet: []uint32{A_mode},
 			},
 		)
@@ -2016,6 +2451,68 @@ func (x *Compound) Open(in, out Bytes) (uint32, error) { //nolint:funlen,gocogni
 		)
 	}
 
+	if _, ok := x.wrap(handle); !ok {
+		f.Close()
+
+		if errors.Is(statErr, os.ErrNotExist) {
+			x.removeCreated(fs, path)
+		}
+
+		return OperationResponse(out,
+			msg.OP4_OPEN,
+			msg.NFS4ERR_NAMETOOLONG,
+		)
+	}
+
+	attrSet := map[int]bool{}
+
+	if args.OpenHow.How == msg.OPEN4_CREATE {
+		attrSet[A_mode] = true
+	}
+
+	if errors.Is(statErr, os.ErrNotExist) {
+		if err := inheritACL(fs, x.Logger, vfs.Dir(path), path, mode); err != nil {
+			f.Close()
+
+			x.removeCreated(fs, path)
+
+			return OperationResponse(out,
+				msg.OP4_OPEN,
+				msg.Err2Status(err),
+			)
+		}
+	}
+
+	if errors.Is(statErr, os.ErrNotExist) && decAttrs != nil {
+		owners, err := x.setOwner(fs, path, decAttrs)
+		if err != nil {
+			f.Close()
+
+			x.removeCreated(fs, path)
+
+			return OperationResponse(out,
+				msg.OP4_OPEN,
+				msg.Err2Status(err),
+			)
+		}
+
+		posix, err := x.setPosixACLs(fs, path, decAttrs)
+		if err != nil {
+			f.Close()
+
+			x.removeCreated(fs, path)
+
+			return OperationResponse(out,
+				msg.OP4_OPEN,
+				msg.Err2Status(err),
+			)
+		}
+
+		for _, a := range append(owners, posix...) {
+			attrSet[int(a)] = true
+		}
+	}
+
 	if args.OpenHow.How == msg.OPEN4_CREATE {
 		fs.Cache.Invalidate(x.CurrentHandle.Handle)
 	} else if flag&os.O_RDWR != 0 || flag&os.O_WRONLY != 0 {
@@ -2034,17 +2531,19 @@ func (x *Compound) Open(in, out Bytes) (uint32, error) { //nolint:funlen,gocogni
 		Path:   path,
 	}
 
+	x.CurrentStateID = &msg.StateId4{
+		SeqId: 1,
+		Other: FileOther(fileID, args.SeqID),
+	}
+
 	return OperationResponse(out,
 		msg.OP4_OPEN,
 		msg.NFS4_OK,
 		msg.OPEN4resok{
-			StateId: msg.StateId4{
-				SeqId: 1,
-				Other: FileOther(fileID, args.SeqID),
-			},
+			StateId: *x.CurrentStateID,
 			CInfo:   msg.ChangeInfo4{},
-			Rflags:  0, // msg.OPEN4_RESULT_PRESERVE_UNLINKED (only supported if GetAttr continuous to work with Current Handle)
-			AttrSet: []uint32{A_mode},
+			Rflags:  msg.OPEN4_RESULT_LOCKTYPE_POSIX, // msg.OPEN4_RESULT_PRESERVE_UNLINKED (only supported if GetAttr continuous to work with Current Handle)
+			AttrSet: bitmap4Encode(attrSet),
 		},
 	)
 }
@@ -2132,6 +2631,13 @@ func (x *Compound) Close(in, out Bytes) (uint32, error) { //nolint:funlen
 
 	fs.Cache.Invalidate(f.Handle)
 
+	x.releaseLocks(f)
+
+	// Layouts are returned when the last open file of the client is closed
+	if x.MinorVer > 0 {
+		x.closeLayout(fs, f)
+	}
+
 	err = f.File.Close()
 	if err != nil {
 		return OperationResponse(out,
@@ -2150,6 +2656,45 @@ func (x *Compound) Close(in, out Bytes) (uint32, error) { //nolint:funlen
 	)
 }
 
+// openFile returns the open file that is referred to by the stateid.
+// The file must belong to the current file handle.
+func (x *Compound) openFile(fs *worker.Worker, stateID msg.StateId4) (*worker.File, uint32) {
+	return x.openHandle(fs, stateID, x.CurrentHandle)
+}
+
+// openHandle returns the open file for the given stateid, which must belong to the given handle.
+func (x *Compound) openHandle(fs *worker.Worker, stateID msg.StateId4, fh *FileHandle) (*worker.File, uint32) {
+	// Clients of data servers use the anonymous stateid given in the layout
+	if x.DataServer && isAnonymousStateID(stateID) {
+		return x.openAnonymous(fs, fh)
+	}
+
+	if stateID.SeqId > 1 {
+		x.Logger.Warnf("bad seqid: %d", stateID.SeqId)
+
+		return nil, msg.NFS4ERR_BAD_SEQID
+	}
+
+	f, ok := fs.GetFile(FileID(stateID.Other))
+
+	if ok && !bytes.Equal(f.Handle, fh.Handle) {
+		ok = false
+	}
+
+	// Destination servers of inter-server copies read with the stateid from COPY_NOTIFY
+	if !ok && x.Copies != nil {
+		f, ok = x.Copies.Notified(stateID, fh.Handle, x.Creds, x.Remote, clients.ClientExpiration)
+	}
+
+	if !ok {
+		x.Logger.Warn("file handle is not equal to current handle")
+
+		return nil, msg.NFS4ERR_BAD_SEQID
+	}
+
+	return f, msg.NFS4_OK
+}
+
 func (x *Compound) Read(in, out Bytes) (uint32, error) { //nolint:funlen
 	var args msg.READ4args
 
@@ -2166,128 +2711,827 @@ func (x *Compound) Read(in, out Bytes) (uint32, error) { //nolint:funlen
 
 	x.Logger.Tracef("READ %d %d %d", args.StateId.Other[0], args.Offset, args.Count)
 
-	if args.StateId.SeqId > 1 {
-		x.Logger.Warnf("bad seqid: %d", args.StateId.SeqId)
+	fs := x.FS(x.Creds, x.SessionID)
 
-		return OperationResponse(out,
-			msg.OP4_READ,
-			msg.NFS4ERR_BAD_SEQID,
-		)
+	defer fs.Close()
+
+	f, status := x.openFile(fs, args.StateId)
+	if status != msg.NFS4_OK {
+		return OperationResponse(out, msg.OP4_READ, status)
+	}
+
+	buf := bufpool.Get()
+
+	b := buf.Allocate(int(min(args.Count, maxIO4)))
+
+	n, err := f.File.ReadAt(b, int64(args.Offset))
+	if err != nil && !errors.Is(err, io.EOF) {
+		x.Logger.Errorf("failed to read: %v", err)
+
+		buf.Discard()
+
+		return OperationResponse(out,
+			msg.OP4_READ,
+			msg.Err2Status(err),
+		)
+	}
+
+	defer buf.Discard()
+
+	return OperationResponse(out,
+		msg.OP4_READ,
+		msg.NFS4_OK,
+		msg.READ4resok{
+			Eof:  errors.Is(err, io.EOF),
+			Data: b[:n],
+		},
+	)
+}
+
+func (x *Compound) ReadPlus(in, out Bytes) (uint32, error) {
+	var args msg.READ_PLUS4args
+
+	if err := xdr.NewDecoder(in).Decode(&args); err != nil {
+		return 0, err
+	}
+
+	if x.CurrentHandle == nil {
+		return OperationResponse(out,
+			msg.OP4_READ_PLUS,
+			msg.NFS4ERR_NOFILEHANDLE,
+		)
 	}
 
+	x.Logger.Tracef("READ_PLUS %d %d %d", args.StateId.Other[0], args.Offset, args.Count)
+
 	fs := x.FS(x.Creds, x.SessionID)
 
 	defer fs.Close()
 
-	f, ok := fs.GetFile(FileID(args.StateId.Other))
+	f, status := x.openFile(fs, args.StateId)
+	if status != msg.NFS4_OK {
+		return OperationResponse(out, msg.OP4_READ_PLUS, status)
+	}
 
-	if ok && !bytes.Equal(f.Handle, x.CurrentHandle.Handle) {
-		ok = false
+	fi, err := fs.Lstat(x.CurrentHandle.Path)
+	if err != nil {
+		DiscardOnServerFault(fs, err)
+
+		return OperationResponse(out, msg.OP4_READ_PLUS, msg.Err2Status(err))
 	}
 
-	if !ok {
-		x.Logger.Warn("file handle is not equal to current handle")
+	buf := bufpool.Get()
+
+	defer buf.Discard()
+
+	contents, eof, err := readPlus(f.File, buf.Allocate(int(min(args.Count, maxIO4))), int64(args.Offset), fi.Size())
+	if err != nil {
+		x.Logger.Errorf("failed to read: %v", err)
 
 		return OperationResponse(out,
-			msg.OP4_READ,
-			msg.NFS4ERR_BAD_SEQID,
+			msg.OP4_READ_PLUS,
+			msg.Err2Status(err),
 		)
 	}
 
-	buf := bufpool.Get()
+	return OperationResponse(out,
+		msg.OP4_READ_PLUS,
+		msg.NFS4_OK,
+		msg.READ_PLUS4resok{
+			Eof:      eof,
+			Contents: contents,
+		},
+	)
+}
+
+func (x *Compound) Write(in, out Bytes) (uint32, error) { //nolint:funlen
+	var args msg.WRITE4args
+
+	if err := xdr.NewDecoder(in).Decode(&args); err != nil {
+		return 0, err
+	}
+
+	x.Logger.Tracef("WRITE %d %d %d", args.StateId.Other[0], args.Offset, len(args.Data))
+
+	if x.CurrentHandle == nil {
+		return OperationResponse(out,
+			msg.OP4_WRITE,
+			msg.NFS4ERR_NOFILEHANDLE,
+		)
+	}
+
+	fs := x.FS(x.Creds, x.SessionID)
+
+	defer fs.Close()
+
+	f, status := x.openFile(fs, args.StateId)
+	if status != msg.NFS4_OK {
+		return OperationResponse(out, msg.OP4_WRITE, status)
+	}
+
+	// TODO: args.Stable is USTABLE4 | DATA_SYNC4 | FILE_SYNC4, we expect the underlying filesystem to handle syncing
+
+	n, err := f.File.WriteAt(args.Data, int64(args.Offset))
+	if err != nil {
+		x.Logger.Errorf("failed to write: %v", err)
+
+		return OperationResponse(out,
+			msg.OP4_WRITE,
+			msg.Err2Status(err),
+		)
+	}
+
+	// Don't invalidate cache immediately while writing, unless FILE_SYNC4 is required
+	if args.Stable == msg.FILE_SYNC4 {
+		fs.Cache.Invalidate(f.Handle)
+	}
+
+	return OperationResponse(out,
+		msg.OP4_WRITE,
+		msg.NFS4_OK,
+		msg.WRITE4resok{
+			Count:     uint32(n),
+			Committed: msg.FILE_SYNC4, // Pretend it's FILE_SYNC4 even if it's DATA_SYNC4 (TODO: why is this a problem?)
+			WriteVerf: fs.SessionID,
+		},
+	)
+}
+
+func (x *Compound) Allocate(in, out Bytes) (uint32, error) {
+	var args msg.ALLOCATE4args
+
+	if err := xdr.NewDecoder(in).Decode(&args); err != nil {
+		return 0, err
+	}
+
+	x.Logger.Tracef("ALLOCATE %d %d %d", args.StateId.Other[0], args.Offset, args.Length)
+
+	return x.changeSpace(out, msg.OP4_ALLOCATE, args.StateId, args.Offset, args.Length, allocateFile)
+}
+
+func (x *Compound) Deallocate(in, out Bytes) (uint32, error) {
+	var args msg.DEALLOCATE4args
+
+	if err := xdr.NewDecoder(in).Decode(&args); err != nil {
+		return 0, err
+	}
+
+	x.Logger.Tracef("DEALLOCATE %d %d %d", args.StateId.Other[0], args.Offset, args.Length)
+
+	return x.changeSpace(out, msg.OP4_DEALLOCATE, args.StateId, args.Offset, args.Length, deallocateFile)
+}
+
+func (x *Compound) WriteSame(in, out Bytes) (uint32, error) {
+	var args msg.WRITE_SAME4args
+
+	if err := xdr.NewDecoder(in).Decode(&args); err != nil {
+		return 0, err
+	}
+
+	x.Logger.Tracef("WRITE_SAME %d %d %d %d", args.StateId.Other[0], args.Adb.Offset, args.Adb.BlockSize, args.Adb.BlockCount)
+
+	if x.CurrentHandle == nil {
+		return OperationResponse(out, msg.OP4_WRITE_SAME, msg.NFS4ERR_NOFILEHANDLE)
+	}
+
+	if status := checkAppData(&args.Adb); status != msg.NFS4_OK {
+		return OperationResponse(out, msg.OP4_WRITE_SAME, status)
+	}
+
+	fs := x.FS(x.Creds, x.SessionID)
+
+	defer fs.Close()
+
+	f, status := x.openFile(fs, args.StateId)
+	if status != msg.NFS4_OK {
+		return OperationResponse(out, msg.OP4_WRITE_SAME, status)
+	}
+
+	if !writable(f) {
+		return OperationResponse(out, msg.OP4_WRITE_SAME, msg.NFS4ERR_OPENMODE)
+	}
+
+	defer fs.Cache.Invalidate(f.Handle)
+
+	// Large requests are shortened, the reply tells the client how much was written
+	args.Adb.BlockCount = min(args.Adb.BlockCount, WriteSameLimit/args.Adb.BlockSize)
+
+	n, err := writeSame(f.File, &args.Adb)
+	if err != nil {
+		DiscardOnServerFault(fs, err)
+
+		x.Logger.Errorf("failed to write: %v", err)
+
+		return OperationResponse(out, msg.OP4_WRITE_SAME, msg.Err2Status(err))
+	}
+
+	return OperationResponse(out, msg.OP4_WRITE_SAME, msg.NFS4_OK, msg.WriteResponse4{
+		Count:     uint64(n),
+		Committed: msg.FILE_SYNC4, // As for WRITE, syncing is left to the underlying filesystem
+		WriteVerf: fs.SessionID,
+	})
+}
+
+func (x *Compound) IOAdvise(in, out Bytes) (uint32, error) {
+	var args msg.IO_ADVISE4args
+
+	if err := xdr.NewDecoder(in).Decode(&args); err != nil {
+		return 0, err
+	}
+
+	x.Logger.Tracef("IO_ADVISE %d %d %d %v", args.StateId.Other[0], args.Offset, args.Count, args.Hints)
+
+	if x.CurrentHandle == nil {
+		return OperationResponse(out, msg.OP4_IO_ADVISE, msg.NFS4ERR_NOFILEHANDLE)
+	}
+
+	// All hints are in the first word of the bitmap
+	var hints uint32
+
+	if len(args.Hints) > 0 {
+		hints = args.Hints[0]
+	}
+
+	if args.Offset > math.MaxInt64 || bits.OnesCount32(hints&accessPatternHints) > 1 {
+		return OperationResponse(out, msg.OP4_IO_ADVISE, msg.NFS4ERR_INVAL)
+	}
+
+	// A count of zero means up to the end of the file
+	length := int64(min(args.Count, math.MaxInt64-args.Offset))
+
+	fs := x.FS(x.Creds, x.SessionID)
+
+	defer fs.Close()
+
+	f, status := x.openFile(fs, args.StateId)
+	if status != msg.NFS4_OK {
+		return OperationResponse(out, msg.OP4_IO_ADVISE, status)
+	}
+
+	honoured := adviseHints(f.File, int64(args.Offset), length, hints)
+
+	// Otherwise, the data is read in advance by the server
+	if hints&^honoured&(1<<msg.IO_ADVISE4_WILLNEED) != 0 && ReadAheadLimit > 0 && fs.Use() == nil {
+		if length == 0 || length > ReadAheadLimit {
+			length = ReadAheadLimit
+		}
+
+		go func() {
+			defer fs.Close()
+
+			readAhead(f.File, int64(args.Offset), length)
+		}()
+
+		honoured |= 1 << msg.IO_ADVISE4_WILLNEED
+	}
+
+	return OperationResponse(out, msg.OP4_IO_ADVISE, msg.NFS4_OK, msg.IO_ADVISE4resok{
+		Hints: []uint32{honoured},
+	})
+}
+
+// changeSpace implements ALLOCATE and DEALLOCATE, calling fn with the open file and its size.
+func (x *Compound) changeSpace(out Bytes, op uint32, stateID msg.StateId4, offset, length uint64, fn func(f vfs.WriterAtReaderAt, offset, length, size int64) error) (uint32, error) {
+	if x.CurrentHandle == nil {
+		return OperationResponse(out, op, msg.NFS4ERR_NOFILEHANDLE)
+	}
+
+	if length == 0 {
+		return OperationResponse(out, op, msg.NFS4ERR_INVAL)
+	}
+
+	if offset > math.MaxInt64-length {
+		return OperationResponse(out, op, msg.NFS4ERR_FBIG)
+	}
+
+	fs := x.FS(x.Creds, x.SessionID)
+
+	defer fs.Close()
+
+	f, status := x.openFile(fs, stateID)
+	if status != msg.NFS4_OK {
+		return OperationResponse(out, op, status)
+	}
+
+	if !writable(f) {
+		return OperationResponse(out, op, msg.NFS4ERR_OPENMODE)
+	}
+
+	fi, err := fs.Lstat(x.CurrentHandle.Path)
+	if err != nil {
+		DiscardOnServerFault(fs, err)
+
+		return OperationResponse(out, op, msg.Err2Status(err))
+	}
+
+	defer fs.Cache.Invalidate(f.Handle)
+
+	if err = fn(f.File, int64(offset), int64(length), fi.Size()); err != nil {
+		DiscardOnServerFault(fs, err)
+
+		x.Logger.Errorf("failed to change allocation: %v", err)
+
+		return OperationResponse(out, op, msg.Err2Status(err))
+	}
+
+	// Make sure the change attribute is updated
+	if err = touch(fs, x.CurrentHandle.Path); err != nil {
+		DiscardOnServerFault(fs, err)
+
+		return OperationResponse(out, op, msg.Err2Status(err))
+	}
+
+	return OperationResponse(out, op, msg.NFS4_OK)
+}
+
+func (x *Compound) Copy(in, out Bytes) (uint32, error) { //nolint:funlen
+	var args msg.COPY4args
+
+	if err := xdr.NewDecoder(in).Decode(&args); err != nil {
+		return 0, err
+	}
+
+	x.Logger.Tracef("COPY %d %d %d %d %d", args.SrcStateId.Other[0], args.DstStateId.Other[0], args.SrcOffset, args.DstOffset, args.Count)
+
+	if x.CurrentHandle == nil || x.SavedHandle == nil {
+		return OperationResponse(out, msg.OP4_COPY, msg.NFS4ERR_NOFILEHANDLE)
+	}
+
+	if args.SrcOffset > math.MaxInt64 || args.DstOffset > math.MaxInt64 {
+		return OperationResponse(out, msg.OP4_COPY, msg.NFS4ERR_INVAL)
+	}
+
+	fs := x.FS(x.Creds, x.SessionID)
+
+	defer fs.Close()
+
+	dst, status := x.openHandle(fs, args.DstStateId, x.CurrentHandle)
+	if status != msg.NFS4_OK {
+		return OperationResponse(out, msg.OP4_COPY, status)
+	}
+
+	if !writable(dst) {
+		return OperationResponse(out, msg.OP4_COPY, msg.NFS4ERR_OPENMODE)
+	}
+
+	// Copies from other servers read the source file as a client of the source server
+	if len(args.SourceServer) > 0 {
+		return x.interServerCopy(out, fs, dst, args)
+	}
+
+	src, status := x.openHandle(fs, args.SrcStateId, x.SavedHandle)
+	if status != msg.NFS4_OK {
+		return OperationResponse(out, msg.OP4_COPY, status)
+	}
+
+	if !readable(src) {
+		return OperationResponse(out, msg.OP4_COPY, msg.NFS4ERR_OPENMODE)
+	}
+
+	fi, err := fs.Lstat(x.SavedHandle.Path)
+	if err != nil {
+		DiscardOnServerFault(fs, err)
+
+		return OperationResponse(out, msg.OP4_COPY, msg.Err2Status(err))
+	}
+
+	size := uint64(fi.Size())
+
+	if args.SrcOffset > size {
+		return OperationResponse(out, msg.OP4_COPY, msg.NFS4ERR_INVAL)
+	}
+
+	// A count of zero means up to the end of the source file
+	count := args.Count
+
+	if count == 0 || count > size-args.SrcOffset {
+		count = size - args.SrcOffset
+	}
+
+	if args.DstOffset > math.MaxInt64-count {
+		return OperationResponse(out, msg.OP4_COPY, msg.NFS4ERR_FBIG)
+	}
+
+	if bytes.Equal(src.Handle, dst.Handle) && args.SrcOffset < args.DstOffset+count && args.DstOffset < args.SrcOffset+count {
+		return OperationResponse(out, msg.OP4_COPY, msg.NFS4ERR_INVAL)
+	}
+
+	return x.runCopy(out, fs, dst, src.File, args, count, func() {})
+}
+
+// interServerCopy copies the saved file handle, which refers to a file on the source server,
+// by reading it from the source server with the stateid that was returned by COPY_NOTIFY.
+func (x *Compound) interServerCopy(out Bytes, fs *worker.Worker, dst *worker.File, args msg.COPY4args) (uint32, error) {
+	if !x.SavedHandle.Foreign {
+		return OperationResponse(out, msg.OP4_COPY, msg.NFS4ERR_INVAL)
+	}
+
+	// A count of zero means up to the end of the source file, which is unknown here
+	count := args.Count
+
+	if count == 0 {
+		count = math.MaxInt64 - max(args.SrcOffset, args.DstOffset)
+	}
+
+	if args.SrcOffset > math.MaxInt64-count || args.DstOffset > math.MaxInt64-count {
+		return OperationResponse(out, msg.OP4_COPY, msg.NFS4ERR_FBIG)
+	}
+
+	client, err := dialRemote(context.Background(), x.copySources(args.SourceServer), x.Creds, x.CopyReservedPort)
+	if err != nil {
+		x.Logger.Errorf("failed to connect to source server: %v", err)
+
+		return OperationResponse(out, msg.OP4_COPY, msg.Err2Status(errOffloadDenied))
+	}
+
+	src := NopWriterAt(&remoteFile{
+		client:  client,
+		handle:  x.SavedHandle.Handle,
+		stateID: args.SrcStateId,
+	})
+
+	logger := x.Logger
+
+	return x.runCopy(out, fs, dst, src, args, count, func() {
+		if err := src.Close(); err != nil {
+			logger.Warnf("failed to disconnect from source server: %v", err)
+		}
+	})
+}
+
+// runCopy copies count bytes from src to the open destination file, and writes the
+// response of COPY. Large copies run in the background if the client allows it.
+// The done function is called once the copy has finished.
+func (x *Compound) runCopy(out Bytes, fs *worker.Worker, dst *worker.File, src vfs.WriterAtReaderAt, args msg.COPY4args, count uint64, done func()) (uint32, error) {
+	var callback *clients.Callback
+
+	if !args.Synchronous && count >= AsyncCopyThreshold && x.Copies != nil {
+		callback = x.callback()
+	}
+
+	path := x.CurrentHandle.Path
+
+	// Asynchronous copies keep using the worker after COPY has returned
+	if callback != nil && fs.Use() == nil {
+		c := x.Copies.Start(clients.ClientIDFromSessionID(x.SessionID), dst.Handle, func(ctx context.Context, c *AsyncCopy) error {
+			defer fs.Close()
+			defer done()
+
+			return copyAndTouch(ctx, fs, dst, src, path, int64(args.SrcOffset), int64(args.DstOffset), int64(count), &c.Copied)
+		}, x.notifyOffload(callback, x.SessionID, x.Signer.Wrap(x.CurrentHandle.Handle), fs.SessionID))
+
+		return OperationResponse(out, msg.OP4_COPY, msg.NFS4_OK, msg.COPY4resok{
+			Response: msg.WriteResponse4{
+				CallbackID: &c.StateID,
+				Committed:  msg.FILE_SYNC4,
+				WriteVerf:  fs.SessionID,
+			},
+			Requirements: msg.CopyRequirements4{
+				Consecutive: true,
+			},
+		})
+	}
+
+	defer done()
+
+	var copied atomic.Int64
+
+	err := copyAndTouch(context.Background(), fs, dst, src, path, int64(args.SrcOffset), int64(args.DstOffset), int64(count), &copied)
+
+	// A partial copy is reported as a short copy
+	if err != nil && copied.Load() == 0 {
+		DiscardOnServerFault(fs, err)
+
+		x.Logger.Errorf("failed to copy: %v", err)
+
+		return OperationResponse(out, msg.OP4_COPY, msg.Err2Status(err))
+	}
+
+	return OperationResponse(out, msg.OP4_COPY, msg.NFS4_OK, msg.COPY4resok{
+		Response: msg.WriteResponse4{
+			Count:     uint64(copied.Load()),
+			Committed: msg.FILE_SYNC4,
+			WriteVerf: fs.SessionID,
+		},
+		Requirements: msg.CopyRequirements4{
+			Consecutive: true,
+			Synchronous: true,
+		},
+	})
+}
+
+func (x *Compound) Clone(in, out Bytes) (uint32, error) { //nolint:funlen
+	var args msg.CLONE4args
+
+	if err := xdr.NewDecoder(in).Decode(&args); err != nil {
+		return 0, err
+	}
+
+	x.Logger.Tracef("CLONE %d %d %d %d %d", args.SrcStateId.Other[0], args.DstStateId.Other[0], args.SrcOffset, args.DstOffset, args.Count)
+
+	if x.CurrentHandle == nil || x.SavedHandle == nil {
+		return OperationResponse(out, msg.OP4_CLONE, msg.NFS4ERR_NOFILEHANDLE)
+	}
+
+	if args.SrcOffset > math.MaxInt64 {
+		return OperationResponse(out, msg.OP4_CLONE, msg.NFS4ERR_INVAL)
+	}
+
+	fs := x.FS(x.Creds, x.SessionID)
+
+	defer fs.Close()
+
+	src, status := x.openHandle(fs, args.SrcStateId, x.SavedHandle)
+	if status != msg.NFS4_OK {
+		return OperationResponse(out, msg.OP4_CLONE, status)
+	}
+
+	dst, status := x.openHandle(fs, args.DstStateId, x.CurrentHandle)
+	if status != msg.NFS4_OK {
+		return OperationResponse(out, msg.OP4_CLONE, status)
+	}
+
+	if !readable(src) || !writable(dst) {
+		return OperationResponse(out, msg.OP4_CLONE, msg.NFS4ERR_OPENMODE)
+	}
+
+	cloner, ok := cloneRangeFile(dst.File)
+	if !ok {
+		return OperationResponse(out, msg.OP4_CLONE, msg.NFS4ERR_NOTSUPP)
+	}
+
+	fi, err := fs.Lstat(x.SavedHandle.Path)
+	if err != nil {
+		DiscardOnServerFault(fs, err)
+
+		return OperationResponse(out, msg.OP4_CLONE, msg.Err2Status(err))
+	}
+
+	size := uint64(fi.Size())
+
+	// A count of zero means up to the end of the source file
+	count := args.Count
+
+	if count == 0 && args.SrcOffset <= size {
+		count = size - args.SrcOffset
+	}
+
+	if args.SrcOffset > size || count > size-args.SrcOffset {
+		return OperationResponse(out, msg.OP4_CLONE, msg.NFS4ERR_INVAL)
+	}
+
+	if args.DstOffset > math.MaxInt64-count {
+		return OperationResponse(out, msg.OP4_CLONE, msg.NFS4ERR_FBIG)
+	}
+
+	// Ranges must be aligned to clone_blksize, except for a range up to the end of the source file
+	if args.SrcOffset%uint64(CloneBlockSize) != 0 || args.DstOffset%uint64(CloneBlockSize) != 0 || (count%uint64(CloneBlockSize) != 0 && args.SrcOffset+count != size) {
+		return OperationResponse(out, msg.OP4_CLONE, msg.NFS4ERR_INVAL)
+	}
+
+	if bytes.Equal(src.Handle, dst.Handle) && args.SrcOffset < args.DstOffset+count && args.DstOffset < args.SrcOffset+count {
+		return OperationResponse(out, msg.OP4_CLONE, msg.NFS4ERR_INVAL)
+	}
+
+	defer fs.Cache.Invalidate(dst.Handle)
+
+	if err = cloner.CloneRange(src.File, int64(args.SrcOffset), int64(args.DstOffset), int64(count)); err != nil {
+		DiscardOnServerFault(fs, err)
+
+		x.Logger.Errorf("failed to clone: %v", err)
+
+		return OperationResponse(out, msg.OP4_CLONE, msg.Err2Status(err))
+	}
+
+	// Make sure the change attribute is updated
+	if err = touch(fs, x.CurrentHandle.Path); err != nil {
+		DiscardOnServerFault(fs, err)
+
+		return OperationResponse(out, msg.OP4_CLONE, msg.Err2Status(err))
+	}
+
+	return OperationResponse(out, msg.OP4_CLONE, msg.NFS4_OK)
+}
+
+// copyAndTouch copies data between two open files, and updates the modification
+// time of the destination file at the given path if anything was copied.
+func copyAndTouch(ctx context.Context, fs *worker.Worker, dst *worker.File, src vfs.WriterAtReaderAt, path string, srcOffset, dstOffset, length int64, progress *atomic.Int64) error {
+	defer fs.Cache.Invalidate(dst.Handle)
+
+	n, err := copyFile(ctx, dst.File, src, srcOffset, dstOffset, length, progress)
+	if n == 0 {
+		return err
+	}
+
+	if terr := touch(fs, path); err == nil {
+		err = terr
+	}
+
+	return err
+}
+
+// notifyOffload returns a function that sends the result of an asynchronous copy
+// to the client with CB_OFFLOAD. The handle is the destination file as known by the client.
+func (x *Compound) notifyOffload(callback *clients.Callback, sessionID [16]byte, fh []byte, verf uint64) func(c *AsyncCopy) {
+	logger := x.Logger
+
+	return func(c *AsyncCopy) {
+		_, err := c.Result()
+
+		args := msg.CB_OFFLOAD4args{
+			Fh:      fh,
+			StateId: c.StateID,
+			Info: msg.OffloadInfo4{
+				Status:      msg.Err2Status(err),
+				BytesCopied: uint64(c.Copied.Load()),
+			},
+		}
+
+		if err == nil {
+			args.Info.Response = msg.WriteResponse4{
+				Count:     uint64(c.Copied.Load()),
+				Committed: msg.FILE_SYNC4,
+				WriteVerf: verf,
+			}
+		}
+
+		status, err := sendCallback(callback, sessionID, msg.OP4_CB_OFFLOAD, args)
+		if err != nil {
+			logger.Warnf("failed to send CB_OFFLOAD: %v", err)
+		} else if status != msg.NFS4_OK {
+			logger.Warnf("CB_OFFLOAD failed with status %d", status)
+		}
+	}
+}
+
+// callback returns the callback path of the current session, or nil if there is none.
+func (x *Compound) callback() *clients.Callback {
+	client, ok := x.Clients.Get(clients.ClientIDFromSessionID(x.SessionID))
+	if !ok {
+		return nil
+	}
+
+	return client.GetCallback(x.SessionID)
+}
+
+func (x *Compound) OffloadStatus(in, out Bytes) (uint32, error) {
+	var stateID msg.StateId4
+
+	if err := xdr.NewDecoder(in).Decode(&stateID); err != nil {
+		return 0, err
+	}
+
+	x.Logger.Tracef("OFFLOAD_STATUS %d", stateID.Other[0])
+
+	c, status := x.asyncCopy(stateID)
+	if status != msg.NFS4_OK {
+		return OperationResponse(out, msg.OP4_OFFLOAD_STATUS, status)
+	}
+
+	res := msg.OFFLOAD_STATUS4resok{
+		Count: uint64(c.Copied.Load()),
+	}
+
+	if done, err := c.Result(); done {
+		complete := msg.Err2Status(err)
+
+		res.Complete = &complete
+	}
+
+	return OperationResponse(out, msg.OP4_OFFLOAD_STATUS, msg.NFS4_OK, res)
+}
+
+func (x *Compound) OffloadCancel(in, out Bytes) (uint32, error) {
+	var stateID msg.StateId4
 
-	b := buf.Allocate(int(args.Count))
+	if err := xdr.NewDecoder(in).Decode(&stateID); err != nil {
+		return 0, err
+	}
 
-	n, err := f.File.ReadAt(b, int64(args.Offset))
-	if err != nil && !errors.Is(err, io.EOF) {
-		x.Logger.Errorf("failed to read: %v", err)
+	x.Logger.Tracef("OFFLOAD_CANCEL %d", stateID.Other[0])
 
-		buf.Discard()
+	// On the source server of an inter-server copy, the stateid from COPY_NOTIFY is revoked
+	if x.CurrentHandle != nil && x.Copies != nil && x.Copies.Revoke(stateID, x.CurrentHandle.Handle) {
+		return OperationResponse(out, msg.OP4_OFFLOAD_CANCEL, msg.NFS4_OK)
+	}
 
-		return OperationResponse(out,
-			msg.OP4_READ,
-			msg.Err2Status(err),
-		)
+	if _, status := x.asyncCopy(stateID); status != msg.NFS4_OK {
+		return OperationResponse(out, msg.OP4_OFFLOAD_CANCEL, status)
 	}
 
-	defer buf.Discard()
+	x.Copies.Cancel(stateID)
 
-	return OperationResponse(out,
-		msg.OP4_READ,
-		msg.NFS4_OK,
-		msg.READ4resok{
-			Eof:  errors.Is(err, io.EOF),
-			Data: b[:n],
-		},
-	)
+	return OperationResponse(out, msg.OP4_OFFLOAD_CANCEL, msg.NFS4_OK)
 }
 
-func (x *Compound) Write(in, out Bytes) (uint32, error) { //nolint:funlen
-	var args msg.WRITE4args
+// CopyNotify allows the destination server of an inter-server copy to read the current file,
+// using the returned stateid. The stateid remains valid while it is used within the lease time.
+func (x *Compound) CopyNotify(in, out Bytes) (uint32, error) {
+	var args msg.COPY_NOTIFY4args
 
 	if err := xdr.NewDecoder(in).Decode(&args); err != nil {
 		return 0, err
 	}
 
-	x.Logger.Tracef("WRITE %d %d %d", args.StateId.Other[0], args.Offset, len(args.Data))
+	x.Logger.Tracef("COPY_NOTIFY %d %d", args.SrcStateId.Other[0], args.DestinationServer.Type)
 
-	if args.StateId.SeqId > 1 {
-		return OperationResponse(out,
-			msg.OP4_WRITE,
-			msg.NFS4ERR_BAD_SEQID,
-		)
+	if x.CurrentHandle == nil {
+		return OperationResponse(out, msg.OP4_COPY_NOTIFY, msg.NFS4ERR_NOFILEHANDLE)
 	}
 
-	if x.CurrentHandle == nil {
-		return OperationResponse(out,
-			msg.OP4_WRITE,
-			msg.NFS4ERR_NOFILEHANDLE,
-		)
+	if x.Copies == nil {
+		return OperationResponse(out, msg.OP4_COPY_NOTIFY, msg.NFS4ERR_NOTSUPP)
 	}
 
 	fs := x.FS(x.Creds, x.SessionID)
 
 	defer fs.Close()
 
-	f, ok := fs.GetFile(FileID(args.StateId.Other))
+	// Only stateids of files opened by the client can be passed to another server
+	fileID := FileID(args.SrcStateId.Other)
 
-	if ok && !bytes.Equal(f.Handle, x.CurrentHandle.Handle) {
-		ok = false
+	f, ok := fs.GetFile(fileID)
+	if !ok || args.SrcStateId.SeqId > 1 || !bytes.Equal(f.Handle, x.CurrentHandle.Handle) {
+		return OperationResponse(out, msg.OP4_COPY_NOTIFY, msg.NFS4ERR_BAD_STATEID)
 	}
 
+	source, ok := universalAddress(x.Addr)
 	if !ok {
-		return OperationResponse(out,
-			msg.OP4_WRITE,
-			msg.NFS4ERR_BAD_SEQID,
-		)
+		return OperationResponse(out, msg.OP4_COPY_NOTIFY, msg.NFS4ERR_NOTSUPP)
 	}
 
-	// TODO: args.Stable is USTABLE4 | DATA_SYNC4 | FILE_SYNC4, we expect the underlying filesystem to handle syncing
+	// Only the destination server may read with the returned stateid
+	destinations := resolveNetloc(context.Background(), args.DestinationServer)
+	if len(destinations) == 0 {
+		return OperationResponse(out, msg.OP4_COPY_NOTIFY, msg.NFS4ERR_INVAL)
+	}
 
-	n, err := f.File.WriteAt(args.Data, int64(args.Offset))
-	if err != nil {
-		x.Logger.Errorf("failed to write: %v", err)
+	// The worker is released when the stateid expires
+	if err := fs.Use(); err != nil {
+		return OperationResponse(out, msg.OP4_COPY_NOTIFY, msg.NFS4ERR_DELAY)
+	}
 
-		return OperationResponse(out,
-			msg.OP4_WRITE,
-			msg.Err2Status(err),
-		)
+	stateID := x.Copies.Notify(fs, fileID, f.Handle, destinations, clients.ClientExpiration)
+
+	return OperationResponse(out, msg.OP4_COPY_NOTIFY, msg.NFS4_OK, msg.COPY_NOTIFY4resok{
+		LeaseTime: msg.NfsTime4{
+			Seconds: uint64(clients.ClientExpiration.Seconds()),
+		},
+		StateId:      stateID,
+		SourceServer: msg.Netlocs{source},
+	})
+}
+
+// asyncCopy returns the asynchronous copy with the given stateid to the current file,
+// which must have been started by the same client.
+func (x *Compound) asyncCopy(stateID msg.StateId4) (*AsyncCopy, uint32) {
+	if x.CurrentHandle == nil {
+		return nil, msg.NFS4ERR_NOFILEHANDLE
 	}
 
-	// Don't invalidate cache immediately while writing, unless FILE_SYNC4 is required
-	if args.Stable == msg.FILE_SYNC4 {
-		fs.Cache.Invalidate(f.Handle)
+	if x.Copies == nil {
+		return nil, msg.NFS4ERR_BAD_STATEID
 	}
 
-	return OperationResponse(out,
-		msg.OP4_WRITE,
-		msg.NFS4_OK,
-		msg.WRITE4resok{
-			Count:     uint32(n),
-			Committed: msg.FILE_SYNC4, // Pretend it's FILE_SYNC4 even if it's DATA_SYNC4 (TODO: why is this a problem?)
-			WriteVerf: fs.SessionID,
-		},
-	)
+	c, ok := x.Copies.Get(stateID)
+	if !ok || !bytes.Equal(c.Handle, x.CurrentHandle.Handle) || c.ClientID != clients.ClientIDFromSessionID(x.SessionID) {
+		return nil, msg.NFS4ERR_BAD_STATEID
+	}
+
+	return c, msg.NFS4_OK
+}
+
+// writable returns whether the open file was opened for writing.
+func writable(f *worker.File) bool {
+	_, readOnly := f.File.(*nopWriterAt)
+
+	return !readOnly
+}
+
+func readable(f *worker.File) bool {
+	_, writeOnly := f.File.(*nopReaderAt)
+
+	return !writeOnly
+}
+
+// touch updates the modification time of a file that was modified through an open file,
+// in the same way as SETATTR, so that clients notice that the change attribute has changed.
+func touch(fs *worker.Worker, path string) error {
+	mtime := clock.MustIncrement(clock.Now())
+
+	return fs.Chtimes(path, mtime, mtime)
+}
+
+// touchAfter is like touch, but makes sure that the new modification time is later than prev,
+// the previous modification time, so that the change attribute differs even within the same second.
+func touchAfter(fs *worker.Worker, path string, prev time.Time) error {
+	mtime := clock.Now()
+
+	if !mtime.After(prev) {
+		mtime = prev.Add(time.Microsecond)
+	}
+
+	return fs.Chtimes(path, mtime, mtime)
 }
 
 func (x *Compound) Commit(in, out Bytes) (uint32, error) {
@@ -2311,6 +3555,58 @@ func (x *Compound) Commit(in, out Bytes) (uint32, error) {
 	)
 }
 
+func (x *Compound) Seek(in, out Bytes) (uint32, error) {
+	var args msg.SEEK4args
+
+	if err := xdr.NewDecoder(in).Decode(&args); err != nil {
+		return 0, err
+	}
+
+	x.Logger.Tracef("SEEK %d %d %d", args.StateId.Other[0], args.Offset, args.What)
+
+	if x.CurrentHandle == nil {
+		return OperationResponse(out, msg.OP4_SEEK, msg.NFS4ERR_NOFILEHANDLE)
+	}
+
+	if args.What != msg.NFS4_CONTENT_DATA && args.What != msg.NFS4_CONTENT_HOLE {
+		return OperationResponse(out, msg.OP4_SEEK, msg.NFS4ERR_UNION_NOTSUPP)
+	}
+
+	fs := x.FS(x.Creds, x.SessionID)
+
+	defer fs.Close()
+
+	f, status := x.openFile(fs, args.StateId)
+	if status != msg.NFS4_OK {
+		return OperationResponse(out, msg.OP4_SEEK, status)
+	}
+
+	fi, err := fs.Lstat(x.CurrentHandle.Path)
+	if err != nil {
+		DiscardOnServerFault(fs, err)
+
+		return OperationResponse(out, msg.OP4_SEEK, msg.Err2Status(err))
+	}
+
+	offset, err := seekFile(f.File, int64(args.Offset), args.What, fi.Size())
+	if errors.Is(err, io.EOF) {
+		return OperationResponse(out, msg.OP4_SEEK, msg.NFS4ERR_NXIO)
+	} else if err != nil {
+		x.Logger.Errorf("failed to seek: %v", err)
+
+		return OperationResponse(out, msg.OP4_SEEK, msg.Err2Status(err))
+	}
+
+	return OperationResponse(out,
+		msg.OP4_SEEK,
+		msg.NFS4_OK,
+		msg.SEEK4resok{
+			Eof:    offset >= fi.Size(),
+			Offset: uint64(offset),
+		},
+	)
+}
+
 func (x *Compound) Verify(in, out Bytes) (uint32, error) { //nolint:funlen
 	var args msg.FAttr4
 
@@ -2346,7 +3642,7 @@ func (x *Compound) Verify(in, out Bytes) (uint32, error) { //nolint:funlen
 	defer fs.Close()
 
 	if fi, cached := fs.Cache.Get(x.CurrentHandle.Handle); cached {
-		attrs := fileInfoToAttrs(x.CurrentHandle.Handle, fi, nil, verifyAttrs, x.Creds, fs.SessionID)
+		attrs := fileInfoToAttrs(x.CurrentHandle.Handle, fi, nil, verifyAttrs, x.attrContext(fs))
 
 		if !bytes.Equal(attrs.Vals, args.Vals) {
 			return OperationResponse(out,
@@ -2376,7 +3672,7 @@ func (x *Compound) Verify(in, out Bytes) (uint32, error) { //nolint:funlen
 		FileInfo: fi,
 	})
 
-	attrs := fileInfoToAttrs(x.CurrentHandle.Handle, fi, nil, verifyAttrs, x.Creds, fs.SessionID)
+	attrs := fileInfoToAttrs(x.CurrentHandle.Handle, fi, nil, verifyAttrs, x.attrContext(fs))
 
 	if !bytes.Equal(attrs.Vals, args.Vals) {
 		return OperationResponse(out,
@@ -2426,7 +3722,7 @@ func (x *Compound) NVerify(in, out Bytes) (uint32, error) { //nolint:funlen
 	defer fs.Close()
 
 	if fi, cached := fs.Cache.Get(x.CurrentHandle.Handle); cached {
-		attrs := fileInfoToAttrs(x.CurrentHandle.Handle, fi, nil, verifyAttrs, x.Creds, fs.SessionID)
+		attrs := fileInfoToAttrs(x.CurrentHandle.Handle, fi, nil, verifyAttrs, x.attrContext(fs))
 
 		if bytes.Equal(attrs.Vals, args.Vals) {
 			return OperationResponse(out,
@@ -2456,7 +3752,7 @@ func (x *Compound) NVerify(in, out Bytes) (uint32, error) { //nolint:funlen
 		FileInfo: fi,
 	})
 
-	attrs := fileInfoToAttrs(x.CurrentHandle.Handle, fi, nil, verifyAttrs, x.Creds, fs.SessionID)
+	attrs := fileInfoToAttrs(x.CurrentHandle.Handle, fi, nil, verifyAttrs, x.attrContext(fs))
 
 	if bytes.Equal(attrs.Vals, args.Vals) {
 		return OperationResponse(out,
@@ -2480,6 +3776,21 @@ func (x *Compound) GetXAttr(in, out Bytes) (uint32, error) {
 
 	x.Logger.Tracef("GETXATTR %s", args.Name)
 
+	if x.CurrentHandle == nil {
+		return OperationResponse(out,
+			msg.OP4_GETXATTR,
+			msg.NFS4ERR_NOFILEHANDLE,
+		)
+	}
+
+	name, status := x.Xattrs.xattrName(args.Name)
+	if status != msg.NFS4_OK {
+		return OperationResponse(out,
+			msg.OP4_GETXATTR,
+			status,
+		)
+	}
+
 	fs := x.FS(x.Creds, x.SessionID)
 
 	defer fs.Close()
@@ -2514,7 +3825,7 @@ func (x *Compound) GetXAttr(in, out Bytes) (uint32, error) {
 		)
 	}
 
-	if val, ok := attrs.Get(attrPrefix + args.Name); ok {
+	if val, ok := attrs.Get(name); ok {
 		return OperationResponse(out,
 			msg.OP4_GETXATTR,
 			msg.NFS4_OK,
@@ -2530,16 +3841,94 @@ func (x *Compound) GetXAttr(in, out Bytes) (uint32, error) {
 	)
 }
 
-const attrPrefix = "user."
+const (
+	// xattrMaxName is the maximum length of the name of an extended attribute in the file system,
+	// including its namespace, and xattrMaxSize the maximum size of its value, as on linux.
+	xattrMaxName = 255
+	xattrMaxSize = 64 * 1024
+)
+
+// xattrSupported returns whether a file supports extended attributes, the value of xattr_support.
+// Attribute directories and named attributes have none.
+func xattrSupported(fi vfs.FileInfo) bool {
+	if _, ok := fi.(*namedAttrInfo); ok {
+		return false
+	}
+
+	_, err := fi.Extended()
+
+	return err == nil
+}
+
+// xattrStatus returns the status for an error of setting or removing an extended attribute.
+func xattrStatus(err error) uint32 {
+	switch {
+	case errors.Is(err, syscall.ENODATA):
+		return msg.NFS4ERR_NOXATTR
+	case errors.Is(err, syscall.E2BIG), errors.Is(err, syscall.ERANGE), errors.Is(err, syscall.ENOSPC):
+		// Linux file systems return ENOSPC if the extended attributes do not fit in the inode
+		return msg.NFS4ERR_XATTR2BIG
+	default:
+		return msg.Err2Status(err)
+	}
+}
+
+// changeInfo returns the change_info4 for a modification of the current file,
+// given its attributes before and after. Modifications are not atomic.
+func (x *Compound) changeInfo(fs *worker.Worker, before, after vfs.FileInfo) msg.ChangeInfo4 {
+	ctx := x.attrContext(fs)
 
-func (x *Compound) SetXAttr(in, out Bytes) (uint32, error) {
+	return msg.ChangeInfo4{
+		Before: changeID(before, ctx),
+		After:  changeID(after, ctx),
+	}
+}
+
+func (x *Compound) SetXAttr(in, out Bytes) (uint32, error) { //nolint:funlen
 	var args msg.SETXATTR4args
 
 	if err := xdr.NewDecoder(in).Decode(&args); err != nil {
 		return 0, err
 	}
 
-	x.Logger.Tracef("SETXATTR %s", args.Name)
+	x.Logger.Tracef("SETXATTR %d %s", args.Option, args.Name)
+
+	if x.CurrentHandle == nil {
+		return OperationResponse(out,
+			msg.OP4_SETXATTR,
+			msg.NFS4ERR_NOFILEHANDLE,
+		)
+	}
+
+	name, status := x.Xattrs.xattrName(args.Name)
+
+	switch {
+	case status == msg.NFS4ERR_NOXATTR, status == msg.NFS4_OK && !x.Xattrs.writable(name):
+		// Names that are not exposed cannot be created either
+		return OperationResponse(out,
+			msg.OP4_SETXATTR,
+			msg.NFS4ERR_PERM,
+		)
+	case status != msg.NFS4_OK:
+		return OperationResponse(out,
+			msg.OP4_SETXATTR,
+			status,
+		)
+	}
+
+	if args.Option > msg.SETXATTR4_REPLACE {
+		return OperationResponse(out,
+			msg.OP4_SETXATTR,
+			msg.NFS4ERR_INVAL,
+		)
+	}
+
+	if len(args.Value) > xattrMaxSize {
+		return OperationResponse(out,
+			msg.OP4_SETXATTR,
+			msg.NFS4ERR_XATTR2BIG,
+		)
+	}
 
 	fs := x.FS(x.Creds, x.SessionID)
 
@@ -2555,29 +3944,57 @@ func (x *Compound) SetXAttr(in, out Bytes) (uint32, error) {
 		)
 	}
 
-	if err = fs.SetExtendedAttr(x.CurrentHandle.Path, attrPrefix+args.Name, args.Value); err != nil {
+	attrs, err := fi.Extended()
+	if err != nil {
 		return OperationResponse(out,
 			msg.OP4_SETXATTR,
 			msg.Err2Status(err),
 		)
 	}
 
-	now := clock.MustIncrement(fi.ModTime())
+	_, exists := attrs.Get(name)
+
+	switch {
+	case args.Option == msg.SETXATTR4_CREATE && exists:
+		return OperationResponse(out,
+			msg.OP4_SETXATTR,
+			msg.NFS4ERR_EXIST,
+		)
+	case args.Option == msg.SETXATTR4_REPLACE && !exists:
+		return OperationResponse(out,
+			msg.OP4_SETXATTR,
+			msg.NFS4ERR_NOXATTR,
+		)
+	}
+
+	if err = fs.SetExtendedAttr(x.CurrentHandle.Path, name, args.Value); err != nil {
+		return OperationResponse(out,
+			msg.OP4_SETXATTR,
+			xattrStatus(err),
+		)
+	}
 
 	// Make sure changeID is updated
-	fs.Chtimes(x.CurrentHandle.Path, now, now) //nolint:errcheck
+	touchAfter(fs, x.CurrentHandle.Path, fi.ModTime()) //nolint:errcheck
 
 	fs.Cache.Invalidate(x.CurrentHandle.Handle)
 
+	after, err := fs.Lstat(x.CurrentHandle.Path)
+	if err != nil {
+		after = fi
+	}
+
 	return OperationResponse(out,
 		msg.OP4_SETXATTR,
 		msg.NFS4_OK,
 		msg.SETXATTR4resok{
-			CInfo: msg.ChangeInfo4{},
+			CInfo: x.changeInfo(fs, fi, after),
 		},
 	)
 }
 
+// ListXAttrs returns the names of the extended attributes in sorted order. The cookie is the
+// number of names returned by previous calls, and the reply is limited to MaxCount bytes.
 func (x *Compound) ListXAttrs(in, out Bytes) (uint32, error) { //nolint:funlen
 	var args msg.LISTXATTRS4args
 
@@ -2585,13 +4002,13 @@ func (x *Compound) ListXAttrs(in, out Bytes) (uint32, error) { //nolint:funlen
 		return 0, err
 	}
 
-	x.Logger.Tracef("LISTXATTRS")
+	x.Logger.Tracef("LISTXATTRS %d %d", args.Cookie, args.MaxCount)
 
-	if args.Cookie > 0 {
-		return OperationResponse(out, msg.OP4_LISTXATTRS, msg.NFS4_OK, msg.LISTXATTRS4resok{
-			Cookie: 1,
-			EOF:    true,
-		})
+	if x.CurrentHandle == nil {
+		return OperationResponse(out,
+			msg.OP4_LISTXATTRS,
+			msg.NFS4ERR_NOFILEHANDLE,
+		)
 	}
 
 	fs := x.FS(x.Creds, x.SessionID)
@@ -2620,32 +4037,54 @@ func (x *Compound) ListXAttrs(in, out Bytes) (uint32, error) { //nolint:funlen
 		})
 	}
 
-	var names []string
-
-	attrs, err := fi.Extended()
-	if err != nil {
+	if _, err := fi.Extended(); err != nil {
 		return OperationResponse(out,
 			msg.OP4_LISTXATTRS,
 			msg.Err2Status(err),
 		)
 	}
 
-	for name := range attrs {
-		names = append(names, strings.TrimPrefix(name, attrPrefix))
+	names := x.Xattrs.names(fi)
+
+	// Attributes might have been removed since the previous call
+	start := min(args.Cookie, uint64(len(names)))
+
+	res := msg.LISTXATTRS4resok{
+		Names: []string{},
+	}
+
+	size := uint32(8 + 4 + 4) // cookie, length of names, eof
+
+	for _, name := range names[start:] {
+		entry := uint32(4 + (len(name)+3)/4*4)
+
+		if size+entry > args.MaxCount {
+			break
+		}
+
+		size += entry
+
+		res.Names = append(res.Names, name)
+	}
+
+	if len(res.Names) == 0 && start < uint64(len(names)) {
+		return OperationResponse(out,
+			msg.OP4_LISTXATTRS,
+			msg.NFS4ERR_TOOSMALL,
+		)
 	}
 
+	res.Cookie = start + uint64(len(res.Names))
+	res.EOF = res.Cookie == uint64(len(names))
+
 	return OperationResponse(out,
 		msg.OP4_LISTXATTRS,
 		msg.NFS4_OK,
-		msg.LISTXATTRS4resok{
-			Cookie: 1,
-			Names:  names,
-			EOF:    true,
-		},
+		res,
 	)
 }
 
-func (x *Compound) RemoveXAttr(in, out Bytes) (uint32, error) {
+func (x *Compound) RemoveXAttr(in, out Bytes) (uint32, error) { //nolint:funlen
 	var args msg.REMOVEXATTR4args
 
 	if err := xdr.NewDecoder(in).Decode(&args); err != nil {
@@ -2654,6 +4093,21 @@ func (x *Compound) RemoveXAttr(in, out Bytes) (uint32, error) {
 
 	x.Logger.Tracef("REMOVEXATTR %s", args.Name)
 
+	if x.CurrentHandle == nil {
+		return OperationResponse(out,
+			msg.OP4_REMOVEXATTR,
+			msg.NFS4ERR_NOFILEHANDLE,
+		)
+	}
+
+	name, status := x.Xattrs.xattrName(args.Name)
+	if status != msg.NFS4_OK {
+		return OperationResponse(out,
+			msg.OP4_REMOVEXATTR,
+			status,
+		)
+	}
+
 	fs := x.FS(x.Creds, x.SessionID)
 
 	defer fs.Close()
@@ -2668,26 +4122,50 @@ func (x *Compound) RemoveXAttr(in, out Bytes) (uint32, error) {
 		)
 	}
 
-	if err = fs.UnsetExtendedAttr(x.CurrentHandle.Path, attrPrefix+args.Name); err != nil {
+	attrs, err := fi.Extended()
+	if err != nil {
 		return OperationResponse(out,
 			msg.OP4_REMOVEXATTR,
 			msg.Err2Status(err),
 		)
 	}
 
-	now := clock.MustIncrement(fi.ModTime())
+	if _, ok := attrs.Get(name); !ok {
+		return OperationResponse(out,
+			msg.OP4_REMOVEXATTR,
+			msg.NFS4ERR_NOXATTR,
+		)
+	}
+
+	if !x.Xattrs.writable(name) {
+		return OperationResponse(out,
+			msg.OP4_REMOVEXATTR,
+			msg.NFS4ERR_PERM,
+		)
+	}
+
+	if err = fs.UnsetExtendedAttr(x.CurrentHandle.Path, name); err != nil {
+		return OperationResponse(out,
+			msg.OP4_REMOVEXATTR,
+			xattrStatus(err),
+		)
+	}
 
 	// Make sure changeID is updated
-	// TODO: incorporate in other way in changeid
-	fs.Chtimes(x.CurrentHandle.Path, now, now) //nolint:errcheck
+	touchAfter(fs, x.CurrentHandle.Path, fi.ModTime()) //nolint:errcheck
 
 	fs.Cache.Invalidate(x.CurrentHandle.Handle)
 
+	after, err := fs.Lstat(x.CurrentHandle.Path)
+	if err != nil {
+		after = fi
+	}
+
 	return OperationResponse(out,
 		msg.OP4_REMOVEXATTR,
 		msg.NFS4_OK,
 		msg.REMOVEXATTR4resok{
-			CInfo: msg.ChangeInfo4{},
+			CInfo: x.changeInfo(fs, fi, after),
 		},
 	)
 }
@@ -2714,8 +4192,14 @@ var NonFatalErrors = []error{
 	os.ErrExist,
 	os.ErrPermission,
 	os.ErrInvalid,
+	syscall.EINVAL,
 	syscall.EISDIR,
 	syscall.ENOTDIR,
 	syscall.EOPNOTSUPP,
+	syscall.ENOSPC,
+	syscall.EDQUOT,
+	syscall.EFBIG,
+	syscall.EXDEV,
 	io.EOF,
+	errOffloadDenied,
 }
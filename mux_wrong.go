@@ -5,7 +5,12 @@ import (
 	"github.com/kuleuven/nfs4go/xdr"
 )
 
-type MuxMismatch struct{}
+// MuxMismatch answers calls to a version of a program that is not served,
+// with the lowest and highest version that are served.
+type MuxMismatch struct {
+	Low  uint32
+	High uint32
+}
 
 func (x *MuxMismatch) Handle(request Request, response chan<- Response) {
 	reply, data, err := x.HandleProc(request.Header, request.Data)
@@ -21,8 +26,8 @@ func (x *MuxMismatch) HandleProc(header *msg.RPCMsgCall, data Bytes) (*msg.RPCMs
 	seq := []interface{}{
 		msg.Auth{},
 		msg.ACCEPT_PROG_MISMATCH,
-		uint32(4), // low:  v4
-		uint32(4), // high: v4
+		x.Low,
+		x.High,
 	}
 
 	data.Reset()
@@ -35,3 +40,35 @@ func (x *MuxMismatch) HandleProc(header *msg.RPCMsgCall, data Bytes) (*msg.RPCMs
 		ReplyStat: msg.MSG_ACCEPTED,
 	}, data, err
 }
+
+// MuxUnavailable answers calls to a program that is not served.
+type MuxUnavailable struct{}
+
+func (x *MuxUnavailable) Handle(request Request, response chan<- Response) {
+	reply, data, err := x.HandleProc(request.Header, request.Data)
+
+	response <- Response{
+		Reply: reply,
+		Data:  data,
+		Error: err,
+	}
+}
+
+func (x *MuxUnavailable) HandleProc(header *msg.RPCMsgCall, data Bytes) (*msg.RPCMsgReply, Bytes, error) {
+	data.Reset()
+
+	err := xdr.NewEncoder(data).EncodeAll(msg.Auth{}, msg.ACCEPT_PROG_UNAVAIL)
+
+	return &msg.RPCMsgReply{
+		Xid:       header.Xid,
+		MsgType:   msg.RPC_REPLY,
+		ReplyStat: msg.MSG_ACCEPTED,
+	}, data, err
+}
+
+// garbageArgs answers a call of which the arguments cannot be decoded.
+func garbageArgs(reply *msg.RPCMsgReply, resp msg.Auth, out Bytes) (*msg.RPCMsgReply, Bytes, error) {
+	out.Reset()
+
+	return reply, out, xdr.NewEncoder(out).EncodeAll(resp, msg.ACCEPT_GARBAGE_ARGS)
+}
@@ -0,0 +1,933 @@
+package nfs4go
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"slices"
+	"sync"
+	"syscall"
+
+	"github.com/kuleuven/nfs4go/clients"
+	"github.com/kuleuven/nfs4go/msg"
+	"github.com/kuleuven/nfs4go/worker"
+	"github.com/kuleuven/nfs4go/xdr"
+	"github.com/kuleuven/vfs"
+)
+
+// Named attributes are the extended attributes of a file exposed by the XattrMapping, in a
+// hidden attribute directory that is opened with OPENATTR. The handles of the attribute
+// directory and of the named attributes consist of the handle of the file, followed by
+// namedAttrMagic, a kind and a hash that identifies the named attribute.
+var namedAttrMagic = []byte("\xffnattr")
+
+const (
+	namedAttrDirKind = byte(1)
+	namedAttrKind    = byte(2)
+
+	namedAttrSuffix = 6 + 1 + 8 // len(namedAttrMagic), kind, hash
+
+	// namedAttrMaxSize is the maximum size of a named attribute
+	namedAttrMaxSize = xattrMaxSize
+)
+
+// namedAttrDirHandle returns the handle of the attribute directory of a file.
+func namedAttrDirHandle(base []byte) []byte {
+	return appendNamedAttr(base, namedAttrDirKind, namedAttrHash(base, ""))
+}
+
+// namedAttrHandle returns the handle of a named attribute of a file.
+func namedAttrHandle(base []byte, name string) []byte {
+	return appendNamedAttr(base, namedAttrKind, namedAttrHash(base, name))
+}
+
+func appendNamedAttr(base []byte, kind byte, hash uint64) []byte {
+	fh := make([]byte, 0, len(base)+namedAttrSuffix)
+	fh = append(fh, base...)
+	fh = append(fh, namedAttrMagic...)
+	fh = append(fh, kind)
+
+	// The hash comes last, so that the fileid attribute differs from the file
+	return binary.BigEndian.AppendUint64(fh, hash)
+}
+
+// namedAttrHash identifies a named attribute of a file, or the attribute directory if name is empty.
+func namedAttrHash(base []byte, name string) uint64 {
+	h := fnv.New64a()
+
+	h.Write(base)         //nolint:errcheck
+	h.Write([]byte(name)) //nolint:errcheck
+
+	return h.Sum64()
+}
+
+// splitNamedAttrHandle splits the handle of an attribute directory or a named attribute
+// into the handle of the file, the kind and the hash.
+func splitNamedAttrHandle(fh []byte) ([]byte, byte, uint64, bool) {
+	if len(fh) <= namedAttrSuffix {
+		return nil, 0, 0, false
+	}
+
+	base, suffix := fh[:len(fh)-namedAttrSuffix], fh[len(fh)-namedAttrSuffix:]
+
+	if !bytes.Equal(suffix[:6], namedAttrMagic) || (suffix[6] != namedAttrDirKind && suffix[6] != namedAttrKind) {
+		return nil, 0, 0, false
+	}
+
+	return base, suffix[6], binary.BigEndian.Uint64(suffix[7:]), true
+}
+
+// IsNamedAttr returns whether the handle refers to an attribute directory or a named attribute.
+func (fh *FileHandle) IsNamedAttr() bool {
+	return fh.AttrDir || fh.AttrName != ""
+}
+
+// base returns the handle of the file to which an attribute directory or a named attribute belongs.
+func (fh *FileHandle) base() []byte {
+	if base, _, _, ok := splitNamedAttrHandle(fh.Handle); ok {
+		return base
+	}
+
+	return fh.Handle
+}
+
+// resolveNamedAttr returns the file handle for the handle of an attribute directory or a named
+// attribute of the file at the given path. Named attributes that no longer exist are not found.
+func resolveNamedAttr(fs *worker.Worker, fh []byte, path string, m *XattrMapping) (*FileHandle, error) {
+	base, kind, hash, _ := splitNamedAttrHandle(fh)
+
+	if kind == namedAttrDirKind {
+		return &FileHandle{Handle: fh, Path: path, AttrDir: true}, nil
+	}
+
+	fi, err := fs.Lstat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range m.names(fi) {
+		if namedAttrHash(base, name) == hash {
+			return &FileHandle{Handle: fh, Path: path, AttrName: name}, nil
+		}
+	}
+
+	return nil, os.ErrNotExist
+}
+
+// hasNamedAttrs returns the value of the named_attr attribute of a file.
+func hasNamedAttrs(fi vfs.FileInfo, m *XattrMapping) bool {
+	if _, ok := fi.(*namedAttrInfo); ok {
+		return false
+	}
+
+	return len(m.names(fi)) > 0
+}
+
+// namedAttrInfo describes an attribute directory or a named attribute. Other properties,
+// such as the owner, permissions and times, are those of the file they belong to.
+type namedAttrInfo struct {
+	vfs.FileInfo
+	name string
+	size int64
+	dir  bool
+}
+
+func (i *namedAttrInfo) Name() string {
+	return i.name
+}
+
+func (i *namedAttrInfo) Size() int64 {
+	return i.size
+}
+
+func (i *namedAttrInfo) Mode() os.FileMode {
+	perm := i.FileInfo.Mode().Perm()
+
+	if i.dir {
+		// Named attributes can be looked up by everyone who can read them
+		return os.ModeDir | perm | (perm&0o444)>>2
+	}
+
+	return perm
+}
+
+func (i *namedAttrInfo) IsDir() bool {
+	return i.dir
+}
+
+func (i *namedAttrInfo) NumLinks() uint64 {
+	if i.dir {
+		return 2
+	}
+
+	return 1
+}
+
+// Extended returns no extended attributes, as named attributes have no named attributes themselves.
+func (i *namedAttrInfo) Extended() (vfs.Attributes, error) {
+	return vfs.Attributes{}, nil
+}
+
+// nfsType returns the nfs_ftype4 of the attribute directory or the named attribute.
+func (i *namedAttrInfo) nfsType() uint32 {
+	if i.dir {
+		return msg.NF4ATTRDIR
+	}
+
+	return msg.NF4NAMEDATTR
+}
+
+// namedAttrStat returns the file info of an attribute directory or a named attribute,
+// given the file info of the file it belongs to.
+func namedAttrStat(fi vfs.FileInfo, fh *FileHandle, m *XattrMapping) (vfs.FileInfo, error) {
+	if fh.AttrDir {
+		return &namedAttrInfo{FileInfo: fi, dir: true}, nil
+	}
+
+	attrs, err := fi.Extended()
+	if err != nil {
+		return nil, err
+	}
+
+	value, ok := m.get(attrs, fh.AttrName)
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+
+	return &namedAttrInfo{FileInfo: fi, name: fh.AttrName, size: int64(len(value))}, nil
+}
+
+// lstat returns the file info of a file handle, which can refer to a named attribute.
+func (x *Compound) lstat(fs *worker.Worker, fh *FileHandle) (vfs.FileInfo, error) {
+	fi, err := fs.Lstat(fh.Path)
+	if err != nil || !fh.IsNamedAttr() {
+		return fi, err
+	}
+
+	return namedAttrStat(fi, fh, x.Xattrs)
+}
+
+// invalidateNamedAttr invalidates the cached file info of a file after one of its named attributes changed.
+func invalidateNamedAttr(fs *worker.Worker, base []byte, name string) {
+	fs.Cache.Invalidate(base)
+	fs.Cache.Invalidate(namedAttrDirHandle(base))
+	fs.Cache.Invalidate(namedAttrHandle(base, name))
+}
+
+// namedAttrFile is an open named attribute. The value is read and written through on each call,
+// so that other opens and the xattr operations see the same value.
+type namedAttrFile struct {
+	fs    *worker.Worker
+	path  string
+	base  []byte
+	name  string
+	xattr string // Name of the extended attribute in the file system
+	sync.Mutex
+}
+
+func (f *namedAttrFile) load() ([]byte, error) {
+	fi, err := f.fs.Lstat(f.path)
+	if err != nil {
+		return nil, err
+	}
+
+	attrs, err := fi.Extended()
+	if err != nil {
+		return nil, err
+	}
+
+	value, _ := attrs.Get(f.xattr)
+
+	return value, nil
+}
+
+func (f *namedAttrFile) store(value []byte) error {
+	if err := f.fs.SetExtendedAttr(f.path, f.xattr, value); err != nil {
+		if errors.Is(err, syscall.E2BIG) || errors.Is(err, syscall.ERANGE) {
+			return msg.Error(msg.NFS4ERR_FBIG)
+		}
+
+		return err
+	}
+
+	invalidateNamedAttr(f.fs, f.base, f.name)
+
+	return touch(f.fs, f.path)
+}
+
+func (f *namedAttrFile) ReadAt(p []byte, off int64) (int, error) {
+	f.Lock()
+	defer f.Unlock()
+
+	value, err := f.load()
+	if err != nil {
+		return 0, err
+	}
+
+	if off >= int64(len(value)) {
+		return 0, io.EOF
+	}
+
+	n := copy(p, value[off:])
+
+	if n < len(p) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+func (f *namedAttrFile) WriteAt(p []byte, off int64) (int, error) {
+	f.Lock()
+	defer f.Unlock()
+
+	if off+int64(len(p)) > namedAttrMaxSize {
+		return 0, msg.Error(msg.NFS4ERR_FBIG)
+	}
+
+	value, err := f.load()
+	if err != nil {
+		return 0, err
+	}
+
+	if end := off + int64(len(p)); end > int64(len(value)) {
+		value = append(value, make([]byte, end-int64(len(value)))...)
+	}
+
+	copy(value[off:], p)
+
+	if err := f.store(value); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// Truncate changes the size of the named attribute.
+func (f *namedAttrFile) Truncate(size int64) error {
+	f.Lock()
+	defer f.Unlock()
+
+	if size > namedAttrMaxSize {
+		return msg.Error(msg.NFS4ERR_FBIG)
+	}
+
+	value, err := f.load()
+	if err != nil {
+		return err
+	}
+
+	if size <= int64(len(value)) {
+		value = value[:size]
+	} else {
+		value = append(value, make([]byte, size-int64(len(value)))...)
+	}
+
+	return f.store(value)
+}
+
+func (f *namedAttrFile) Close() error {
+	return nil
+}
+
+// OpenAttr changes the current file handle to the attribute directory of the current file.
+// The attribute directory always exists if the file system supports extended attributes.
+func (x *Compound) OpenAttr(in, out Bytes) (uint32, error) {
+	var args msg.OPENATTR4args
+
+	if err := xdr.NewDecoder(in).Decode(&args); err != nil {
+		return 0, err
+	}
+
+	x.Logger.Tracef("OPENATTR %v", args.CreateDir)
+
+	if x.CurrentHandle == nil {
+		return OperationResponse(out, msg.OP4_OPENATTR, msg.NFS4ERR_NOFILEHANDLE)
+	}
+
+	fs := x.FS(x.Creds, x.SessionID)
+
+	defer fs.Close()
+
+	fi, err := fs.Lstat(x.CurrentHandle.Path)
+	if err != nil {
+		DiscardOnServerFault(fs, err)
+
+		return OperationResponse(out, msg.OP4_OPENATTR, msg.Err2Status(err))
+	}
+
+	if _, err := fi.Extended(); err != nil {
+		x.Logger.Debugf("no extended attributes: %v", err)
+
+		return OperationResponse(out, msg.OP4_OPENATTR, msg.NFS4ERR_NOTSUPP)
+	}
+
+	handle := namedAttrDirHandle(x.CurrentHandle.Handle)
+
+	if _, ok := x.wrap(handle); !ok {
+		return OperationResponse(out, msg.OP4_OPENATTR, msg.NFS4ERR_NOTSUPP)
+	}
+
+	x.CurrentHandle = &FileHandle{
+		Handle:  handle,
+		Path:    x.CurrentHandle.Path,
+		AttrDir: true,
+	}
+
+	return OperationResponse(out, msg.OP4_OPENATTR, msg.NFS4_OK)
+}
+
+// namedAttrUnsupportedOps are refused with NFS4ERR_WRONG_TYPE for attribute directories and named attributes.
+var namedAttrUnsupportedOps = []uint32{
+	msg.OP4_CREATE,
+	msg.OP4_LINK,
+	msg.OP4_RENAME,
+	msg.OP4_READLINK,
+	msg.OP4_OPENATTR,
+	msg.OP4_VERIFY,
+	msg.OP4_NVERIFY,
+	msg.OP4_READ_PLUS,
+	msg.OP4_SEEK,
+	msg.OP4_ALLOCATE,
+	msg.OP4_DEALLOCATE,
+	msg.OP4_COPY,
+	msg.OP4_COPY_NOTIFY,
+	msg.OP4_CLONE,
+	msg.OP4_GETXATTR,
+	msg.OP4_SETXATTR,
+	msg.OP4_LISTXATTRS,
+	msg.OP4_REMOVEXATTR,
+	msg.OP4_LAYOUTGET,
+	msg.OP4_LAYOUTCOMMIT,
+}
+
+// namedAttrOperation handles the operations that behave differently if the current or saved file handle
+// refers to an attribute directory or a named attribute. It returns false for other operations.
+func (x *Compound) namedAttrOperation(in, out Bytes, op uint32) (uint32, bool, error) {
+	current := x.CurrentHandle != nil && x.CurrentHandle.IsNamedAttr()
+	saved := x.SavedHandle != nil && x.SavedHandle.IsNamedAttr()
+
+	if !current && !saved {
+		return 0, false, nil
+	}
+
+	var (
+		status uint32
+		err    error
+	)
+
+	switch {
+	case current && slices.Contains(namedAttrUnsupportedOps, op),
+		saved && slices.Contains([]uint32{msg.OP4_LINK, msg.OP4_RENAME, msg.OP4_COPY, msg.OP4_CLONE}, op):
+		// Note: WRONG_TYPE is listed in FatalStatuses, as the arguments are not decoded
+		status, err = OperationResponse(out, op, msg.NFS4ERR_WRONG_TYPE)
+	case !current:
+		return 0, false, nil
+	case op == msg.OP4_LOOKUP:
+		status, err = x.namedAttrLookup(in, out)
+	case op == msg.OP4_LOOKUPP:
+		status, err = x.namedAttrLookupParent(out)
+	case op == msg.OP4_READDIR:
+		status, err = x.namedAttrReadDir(in, out)
+	case op == msg.OP4_OPEN:
+		status, err = x.namedAttrOpen(in, out)
+	case op == msg.OP4_REMOVE:
+		status, err = x.namedAttrRemove(in, out)
+	case op == msg.OP4_SETATTR:
+		status, err = x.namedAttrSetAttr(in, out)
+	default:
+		return 0, false, nil
+	}
+
+	return status, true, err
+}
+
+// namedAttrValue returns the value of a named attribute of the current file.
+func (x *Compound) namedAttrValue(fs *worker.Worker, name string) (vfs.FileInfo, []byte, bool, error) {
+	fi, err := fs.Lstat(x.CurrentHandle.Path)
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	attrs, err := fi.Extended()
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	value, ok := x.Xattrs.get(attrs, name)
+
+	return fi, value, ok, nil
+}
+
+func (x *Compound) namedAttrLookup(in, out Bytes) (uint32, error) {
+	args := msg.LOOKUP4args{}
+
+	if err := xdr.NewDecoder(in).Decode(&args); err != nil {
+		return 0, err
+	}
+
+	x.Logger.Tracef("LOOKUP %s (named attribute)", args.ObjName)
+
+	if args.ObjName == "" {
+		return OperationResponse(out, msg.OP4_LOOKUP, msg.NFS4ERR_INVAL)
+	}
+
+	if !x.CurrentHandle.AttrDir {
+		return OperationResponse(out, msg.OP4_LOOKUP, msg.NFS4ERR_NOTDIR)
+	}
+
+	fs := x.FS(x.Creds, x.SessionID)
+
+	defer fs.Close()
+
+	_, _, ok, err := x.namedAttrValue(fs, args.ObjName)
+	if err != nil {
+		DiscardOnServerFault(fs, err)
+
+		return OperationResponse(out, msg.OP4_LOOKUP, msg.Err2Status(err))
+	}
+
+	if !ok {
+		return OperationResponse(out, msg.OP4_LOOKUP, msg.NFS4ERR_NOENT)
+	}
+
+	handle := namedAttrHandle(x.CurrentHandle.base(), args.ObjName)
+
+	if _, ok := x.wrap(handle); !ok {
+		return OperationResponse(out, msg.OP4_LOOKUP, msg.NFS4ERR_NAMETOOLONG)
+	}
+
+	x.CurrentHandle = &FileHandle{
+		Handle:   handle,
+		Path:     x.CurrentHandle.Path,
+		AttrName: args.ObjName,
+	}
+
+	return OperationResponse(out, msg.OP4_LOOKUP, msg.NFS4_OK)
+}
+
+// namedAttrLookupParent returns from the attribute directory to the file,
+// or from a named attribute to the attribute directory.
+func (x *Compound) namedAttrLookupParent(out Bytes) (uint32, error) {
+	x.Logger.Trace("LOOKUPP (named attribute)")
+
+	base := x.CurrentHandle.base()
+
+	if x.CurrentHandle.AttrDir {
+		x.CurrentHandle = &FileHandle{
+			Handle: base,
+			Path:   x.CurrentHandle.Path,
+		}
+	} else {
+		x.CurrentHandle = &FileHandle{
+			Handle:  namedAttrDirHandle(base),
+			Path:    x.CurrentHandle.Path,
+			AttrDir: true,
+		}
+	}
+
+	return OperationResponse(out, msg.OP4_LOOKUPP, msg.NFS4_OK)
+}
+
+func (x *Compound) namedAttrReadDir(in, out Bytes) (uint32, error) { //nolint:funlen
+	args := msg.READDIR4args{}
+
+	if err := xdr.NewDecoder(in).Decode(&args); err != nil {
+		return 0, err
+	}
+
+	idxReq := bitmap4Decode(args.AttrRequest)
+
+	x.Logger.Tracef("READDIR %d %s (named attributes)", args.Cookie, bitmapString(idxReq))
+
+	if !x.CurrentHandle.AttrDir {
+		return OperationResponse(out, msg.OP4_READDIR, msg.NFS4ERR_NOTDIR)
+	}
+
+	// Cookies are the offset of the next entry, starting from 1000 as for directories
+	if args.Cookie > 0 && args.Cookie <= 1000 {
+		return OperationResponse(out, msg.OP4_READDIR, msg.NFS4ERR_BAD_COOKIE)
+	}
+
+	fs := x.FS(x.Creds, x.SessionID)
+
+	defer fs.Close()
+
+	fi, err := fs.Lstat(x.CurrentHandle.Path)
+	if err != nil {
+		DiscardOnServerFault(fs, err)
+
+		return OperationResponse(out, msg.OP4_READDIR, msg.Err2Status(err))
+	}
+
+	attrs, err := fi.Extended()
+	if err != nil {
+		return OperationResponse(out, msg.OP4_READDIR, msg.Err2Status(err))
+	}
+
+	var (
+		names       = x.Xattrs.names(fi)
+		base        = x.CurrentHandle.base()
+		offset      = uint64(0)
+		first, prev *msg.Entry4
+	)
+
+	if args.Cookie > 0 {
+		offset = args.Cookie - 1000
+	}
+
+	if offset > uint64(len(names)) {
+		return OperationResponse(out, msg.OP4_READDIR, msg.NFS4ERR_BAD_COOKIE)
+	}
+
+	next := offset
+
+	for _, name := range names[offset:] {
+		value, _ := x.Xattrs.get(attrs, name)
+
+		entry := &msg.Entry4{
+			Cookie: 1000 + next + 1,
+			Name:   name,
+			Attrs:  fileInfoToAttrs(namedAttrHandle(base, name), &namedAttrInfo{FileInfo: fi, name: name, size: int64(len(value))}, nil, idxReq, x.attrContext(fs)),
+		}
+
+		if size := SizeOf(xdr.Marshal(entry.Cookie, entry.Name)); args.DirCount < size {
+			break
+		} else {
+			args.DirCount -= size
+		}
+
+		if size := SizeOf(xdr.Marshal(entry.Cookie, entry.Name, entry.Attrs)) + 4; args.MaxCount < size+128 {
+			break
+		} else {
+			args.MaxCount -= size
+		}
+
+		if first == nil {
+			first = entry
+		} else {
+			prev.Next = entry
+		}
+
+		prev = entry
+		next++
+	}
+
+	if first == nil && next < uint64(len(names)) {
+		return OperationResponse(out, msg.OP4_READDIR, msg.NFS4ERR_TOOSMALL)
+	}
+
+	return OperationResponse(out,
+		msg.OP4_READDIR,
+		msg.NFS4_OK,
+		msg.READDIR4resok{
+			CookieVerf: args.CookieVerf,
+			Reply: msg.DirList4{
+				Entries: first,
+				Eof:     next == uint64(len(names)),
+			},
+		},
+	)
+}
+
+func (x *Compound) namedAttrOpen(in, out Bytes) (uint32, error) { //nolint:funlen,gocognit,gocyclo
+	var args msg.OPEN4args
+
+	if err := xdr.NewDecoder(in).Decode(&args); err != nil {
+		return 0, err
+	}
+
+	if x.MinorVer > 0 {
+		args.Owner.ClientId = clients.ClientIDFromSessionID(x.SessionID)
+		args.SeqID = x.Slot.SequenceID*clients.MaxSlotID + x.Slot.SlotID
+	}
+
+	x.Logger.Tracef("OPEN %d %+v (named attribute)", args.SeqID, args)
+
+	name := x.CurrentHandle.AttrName
+
+	switch args.OpenClaim.Claim {
+	case msg.CLAIM_NULL:
+		if !x.CurrentHandle.AttrDir {
+			return OperationResponse(out, msg.OP4_OPEN, msg.NFS4ERR_NOTDIR)
+		}
+
+		name = args.OpenClaim.File
+	case msg.CLAIM_FH:
+		if x.CurrentHandle.AttrDir {
+			return OperationResponse(out, msg.OP4_OPEN, msg.NFS4ERR_ISDIR)
+		}
+	default:
+		return OperationResponse(out, msg.OP4_OPEN, msg.NFS4ERR_NOTSUPP)
+	}
+
+	xattr, status := x.Xattrs.xattrName(name)
+
+	switch {
+	case status == msg.NFS4ERR_NOXATTR && args.OpenHow.How == msg.OPEN4_CREATE:
+		return OperationResponse(out, msg.OP4_OPEN, msg.NFS4ERR_PERM)
+	case status == msg.NFS4ERR_NOXATTR:
+		return OperationResponse(out, msg.OP4_OPEN, msg.NFS4ERR_NOENT)
+	case status != msg.NFS4_OK:
+		return OperationResponse(out, msg.OP4_OPEN, status)
+	case args.ShareAccess&msg.OPEN4_SHARE_ACCESS_WRITE != 0 && !x.Xattrs.writable(xattr):
+		return OperationResponse(out, msg.OP4_OPEN, msg.NFS4ERR_PERM)
+	}
+
+	if args.ShareDeny != 0 {
+		return OperationResponse(out, msg.OP4_OPEN, msg.NFS4ERR_SHARE_DENIED)
+	}
+
+	var (
+		truncate bool
+		attrSet  = map[int]bool{}
+	)
+
+	if args.OpenHow.How == msg.OPEN4_CREATE {
+		var createAttrs *msg.FAttr4
+
+		switch args.OpenHow.Claim.CreateMode {
+		case msg.UNCHECKED4:
+			createAttrs = &args.OpenHow.Claim.CreateAttrsUnchecked
+		case msg.GUARDED4:
+			createAttrs = &args.OpenHow.Claim.CreateAttrsGuarded
+		case msg.EXCLUSIVE4, msg.EXCLUSIVE4_1:
+		default:
+			return 0, fmt.Errorf("unsupported create mode: %d", args.OpenHow.Claim.CreateMode)
+		}
+
+		if createAttrs != nil {
+			decAttrs, err := decodeFAttrs4(*createAttrs)
+			if err != nil {
+				return 0, err
+			}
+
+			if decAttrs.Size != nil && *decAttrs.Size == 0 {
+				truncate = true
+				attrSet[A_size] = true
+			}
+		}
+	}
+
+	client, ok := x.Clients.Get(args.Owner.ClientId)
+	if !ok {
+		return OperationResponse(out, msg.OP4_OPEN, msg.NFS4ERR_STALE_CLIENTID)
+	}
+
+	fs := x.FS(x.Creds, x.SessionID)
+
+	defer fs.Close()
+
+	// Check whether seqId is already an open file
+	if fileID, ok := fs.GetFileByClientSeqID(client, args.SeqID); ok {
+		return OperationResponse(out,
+			msg.OP4_OPEN,
+			msg.NFS4_OK,
+			msg.OPEN4resok{
+				StateId: msg.StateId4{
+					SeqId: 1,
+					Other: FileOther(fileID, args.SeqID),
+				},
+				CInfo:   msg.ChangeInfo4{},
+				AttrSet: []uint32{},
+			},
+		)
+	}
+
+	_, _, exists, err := x.namedAttrValue(fs, name)
+	if err != nil {
+		DiscardOnServerFault(fs, err)
+
+		return OperationResponse(out, msg.OP4_OPEN, msg.Err2Status(err))
+	}
+
+	switch {
+	case args.OpenHow.How != msg.OPEN4_CREATE && !exists:
+		return OperationResponse(out, msg.OP4_OPEN, msg.NFS4ERR_NOENT)
+	case args.OpenHow.How == msg.OPEN4_CREATE && exists && args.OpenHow.Claim.CreateMode != msg.UNCHECKED4:
+		return OperationResponse(out, msg.OP4_OPEN, msg.NFS4ERR_EXIST)
+	}
+
+	base := x.CurrentHandle.base()
+	handle := namedAttrHandle(base, name)
+
+	if _, ok := x.wrap(handle); !ok {
+		return OperationResponse(out, msg.OP4_OPEN, msg.NFS4ERR_NAMETOOLONG)
+	}
+
+	f := &namedAttrFile{
+		fs:    fs,
+		path:  x.CurrentHandle.Path,
+		base:  base,
+		name:  name,
+		xattr: xattr,
+	}
+
+	if !exists || truncate {
+		if err := f.Truncate(0); err != nil {
+			DiscardOnServerFault(fs, err)
+
+			return OperationResponse(out, msg.OP4_OPEN, msg.Err2Status(err))
+		}
+	}
+
+	var file vfs.WriterAtReaderAt = f
+
+	switch args.ShareAccess & msg.OPEN4_SHARE_ACCESS_BOTH {
+	case msg.OPEN4_SHARE_ACCESS_READ:
+		file = NopWriterAt(f)
+	case msg.OPEN4_SHARE_ACCESS_WRITE:
+		file = NopReaderAt(f)
+	}
+
+	fileID := fs.AddFile(&worker.File{
+		File:        file,
+		Handle:      handle,
+		Client:      client,
+		ClientSeqID: args.SeqID,
+	})
+
+	x.CurrentHandle = &FileHandle{
+		Handle:   handle,
+		Path:     x.CurrentHandle.Path,
+		AttrName: name,
+	}
+
+	return OperationResponse(out,
+		msg.OP4_OPEN,
+		msg.NFS4_OK,
+		msg.OPEN4resok{
+			StateId: msg.StateId4{
+				SeqId: 1,
+				Other: FileOther(fileID, args.SeqID),
+			},
+			CInfo:   msg.ChangeInfo4{},
+			AttrSet: bitmap4Encode(attrSet),
+		},
+	)
+}
+
+func (x *Compound) namedAttrRemove(in, out Bytes) (uint32, error) {
+	var args msg.REMOVE4args
+
+	if err := xdr.NewDecoder(in).Decode(&args); err != nil {
+		return 0, err
+	}
+
+	x.Logger.Tracef("REMOVE %s (named attribute)", args.Target)
+
+	if args.Target == "" {
+		return OperationResponse(out, msg.OP4_REMOVE, msg.NFS4ERR_INVAL)
+	}
+
+	if !x.CurrentHandle.AttrDir {
+		return OperationResponse(out, msg.OP4_REMOVE, msg.NFS4ERR_NOTDIR)
+	}
+
+	xattr, status := x.Xattrs.xattrName(args.Target)
+
+	switch {
+	case status == msg.NFS4ERR_NOXATTR:
+		return OperationResponse(out, msg.OP4_REMOVE, msg.NFS4ERR_NOENT)
+	case status != msg.NFS4_OK:
+		return OperationResponse(out, msg.OP4_REMOVE, status)
+	}
+
+	fs := x.FS(x.Creds, x.SessionID)
+
+	defer fs.Close()
+
+	_, _, ok, err := x.namedAttrValue(fs, args.Target)
+	if err == nil && !ok {
+		err = os.ErrNotExist
+	}
+
+	if err == nil && !x.Xattrs.writable(xattr) {
+		return OperationResponse(out, msg.OP4_REMOVE, msg.NFS4ERR_PERM)
+	}
+
+	if err == nil {
+		err = fs.UnsetExtendedAttr(x.CurrentHandle.Path, xattr)
+	}
+
+	if err != nil {
+		DiscardOnServerFault(fs, err)
+
+		return OperationResponse(out, msg.OP4_REMOVE, msg.Err2Status(err))
+	}
+
+	// Make sure changeID is updated
+	touch(fs, x.CurrentHandle.Path) //nolint:errcheck
+
+	invalidateNamedAttr(fs, x.CurrentHandle.base(), args.Target)
+
+	return OperationResponse(out,
+		msg.OP4_REMOVE,
+		msg.NFS4_OK,
+		msg.REMOVE4resok{
+			CInfo: msg.ChangeInfo4{},
+		},
+	)
+}
+
+// namedAttrSetAttr changes the size of a named attribute. Other attributes are those
+// of the file the named attribute belongs to, and cannot be changed.
+func (x *Compound) namedAttrSetAttr(in, out Bytes) (uint32, error) {
+	var args msg.SETATTR4args
+
+	if err := xdr.NewDecoder(in).Decode(&args); err != nil {
+		return 0, err
+	}
+
+	x.Logger.Tracef("SETATTR %v (named attribute)", bitmapString(bitmap4Decode(args.Attrs.Mask)))
+
+	decAttrs, err := decodeFAttrs4(args.Attrs)
+	if err != nil {
+		return OperationResponse(out, msg.OP4_SETATTR, msg.Err2Status(err))
+	}
+
+	if decAttrs.Mode != nil || decAttrs.Owner != "" || decAttrs.OwnerGroup != "" || decAttrs.ACL != nil || decAttrs.PosixACL != nil || decAttrs.PosixDefaultACL != nil {
+		return OperationResponse(out, msg.OP4_SETATTR, msg.NFS4ERR_ATTRNOTSUPP)
+	}
+
+	if decAttrs.Size == nil {
+		return OperationResponse(out, msg.OP4_SETATTR, msg.NFS4_OK, []uint32{})
+	}
+
+	if x.CurrentHandle.AttrDir {
+		return OperationResponse(out, msg.OP4_SETATTR, msg.NFS4ERR_ISDIR)
+	}
+
+	xattr, status := x.Xattrs.xattrName(x.CurrentHandle.AttrName)
+
+	switch {
+	case status != msg.NFS4_OK:
+		return OperationResponse(out, msg.OP4_SETATTR, msg.NFS4ERR_STALE)
+	case !x.Xattrs.writable(xattr):
+		return OperationResponse(out, msg.OP4_SETATTR, msg.NFS4ERR_PERM)
+	}
+
+	fs := x.FS(x.Creds, x.SessionID)
+
+	defer fs.Close()
+
+	f := &namedAttrFile{
+		fs:    fs,
+		path:  x.CurrentHandle.Path,
+		base:  x.CurrentHandle.base(),
+		name:  x.CurrentHandle.AttrName,
+		xattr: xattr,
+	}
+
+	if err := f.Truncate(int64(*decAttrs.Size)); err != nil {
+		DiscardOnServerFault(fs, err)
+
+		return OperationResponse(out, msg.OP4_SETATTR, msg.Err2Status(err))
+	}
+
+	return OperationResponse(out, msg.OP4_SETATTR, msg.NFS4_OK, bitmap4Encode(map[int]bool{A_size: true}))
+}
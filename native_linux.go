@@ -0,0 +1,134 @@
+//go:build linux
+
+package nfs4go
+
+import (
+	"errors"
+	"io"
+
+	"github.com/kuleuven/vfs"
+	"golang.org/x/sys/unix"
+)
+
+// nativeFile implements the optional file interfaces using system calls
+// on the file descriptor of a NativeFile.
+type nativeFile struct {
+	fd int
+}
+
+// findNative returns a nativeFile for the file descriptor of f, if f opts in as NativeFile
+// and nativeFile implements T.
+func findNative[T any](f any) (T, bool) {
+	var zero T
+
+	nf, ok := findFile[NativeFile](f)
+	if !ok {
+		return zero, false
+	}
+
+	t, ok := any(&nativeFile{fd: int(nf.NativeFd())}).(T)
+
+	return t, ok
+}
+
+func (n *nativeFile) SeekData(offset int64) (int64, error) {
+	return n.seek(offset, unix.SEEK_DATA)
+}
+
+func (n *nativeFile) SeekHole(offset int64) (int64, error) {
+	return n.seek(offset, unix.SEEK_HOLE)
+}
+
+// seek uses lseek(2), which moves the offset of the file description. The offset is not
+// used otherwise, as all I/O happens with ReadAt and WriteAt.
+func (n *nativeFile) seek(offset int64, whence int) (int64, error) {
+	off, err := unix.Seek(n.fd, offset, whence)
+	if errors.Is(err, unix.ENXIO) {
+		return 0, io.EOF
+	}
+
+	return off, err
+}
+
+func (n *nativeFile) Allocate(offset, length int64) error {
+	return n.fallocate(0, offset, length)
+}
+
+func (n *nativeFile) Deallocate(offset, length int64) error {
+	return n.fallocate(unix.FALLOC_FL_PUNCH_HOLE|unix.FALLOC_FL_KEEP_SIZE, offset, length)
+}
+
+func (n *nativeFile) fallocate(mode uint32, offset, length int64) error {
+	err := unix.Fallocate(n.fd, mode, offset, length)
+	if errors.Is(err, unix.ENOSYS) {
+		return unix.EOPNOTSUPP
+	}
+
+	return err
+}
+
+// fadvise maps the advice to posix_fadvise(2).
+var fadvise = map[Advice]int{
+	AdviseNormal:     unix.FADV_NORMAL,
+	AdviseSequential: unix.FADV_SEQUENTIAL,
+	AdviseRandom:     unix.FADV_RANDOM,
+	AdviseWillNeed:   unix.FADV_WILLNEED,
+	AdviseDontNeed:   unix.FADV_DONTNEED,
+	AdviseNoReuse:    unix.FADV_NOREUSE,
+}
+
+func (n *nativeFile) Advise(offset, length int64, advice Advice) error {
+	a, ok := fadvise[advice]
+	if !ok {
+		return unix.EINVAL
+	}
+
+	return unix.Fadvise(n.fd, offset, length, a)
+}
+
+func (n *nativeFile) CopyRange(src vfs.WriterAtReaderAt, srcOffset, dstOffset, length int64) (int64, error) {
+	nf, ok := findFile[NativeFile](src)
+	if !ok {
+		return 0, unix.EXDEV
+	}
+
+	var copied int64
+
+	for copied < length {
+		m, err := unix.CopyFileRange(int(nf.NativeFd()), &srcOffset, n.fd, &dstOffset, int(length-copied), 0)
+		if errors.Is(err, unix.ENOSYS) || (errors.Is(err, unix.EINVAL) && copied == 0) {
+			return 0, unix.EOPNOTSUPP
+		}
+
+		if err != nil {
+			return copied, err
+		}
+
+		if m == 0 {
+			break
+		}
+
+		copied += int64(m)
+	}
+
+	return copied, nil
+}
+
+func (n *nativeFile) CloneRange(src vfs.WriterAtReaderAt, srcOffset, dstOffset, length int64) error {
+	nf, ok := findFile[NativeFile](src)
+	if !ok {
+		return unix.EXDEV
+	}
+
+	err := unix.IoctlFileCloneRange(n.fd, &unix.FileCloneRange{
+		Src_fd:      int64(nf.NativeFd()),
+		Src_offset:  uint64(srcOffset),
+		Src_length:  uint64(length),
+		Dest_offset: uint64(dstOffset),
+	})
+	if errors.Is(err, unix.ENOTTY) || errors.Is(err, unix.ENOSYS) {
+		return unix.EOPNOTSUPP
+	}
+
+	return err
+}
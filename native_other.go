@@ -0,0 +1,10 @@
+//go:build !linux
+
+package nfs4go
+
+// findNative is not supported on this platform, NativeFile is ignored.
+func findNative[T any](f any) (T, bool) {
+	var zero T
+
+	return zero, false
+}
@@ -0,0 +1,411 @@
+package nfs4go
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/kuleuven/nfs4go/bufpool"
+	"github.com/kuleuven/nfs4go/clock"
+	"github.com/kuleuven/nfs4go/logger"
+	"github.com/kuleuven/nfs4go/msg"
+	"github.com/kuleuven/nfs4go/xdr"
+)
+
+// ServePortmapper serves the portmapper version 2 and rpcbind versions 3 and 4 on the
+// given address, usually ":111", over TCP and UDP until the context is cancelled.
+// Clients that look up the port of NFS, MOUNT or NLM before mounting, such as NFSv3 clients,
+// then do not need a separate rpcbind daemon. Only the programs served by the Server are
+// registered, with the port of its listener; registrations of other programs are refused.
+func (s *Server) ServePortmapper(ctx context.Context, address string) error {
+	nfsAddr, ok := s.listener.Addr().(*net.TCPAddr)
+	if !ok {
+		return fmt.Errorf("portmapper requires a TCP listener, got %s", s.listener.Addr())
+	}
+
+	ln, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("net.Listen: %w", err)
+	}
+
+	pc, err := net.ListenPacket("udp", address)
+	if err != nil {
+		ln.Close()
+
+		return fmt.Errorf("net.ListenPacket: %w", err)
+	}
+
+	pm := &portmapper{
+		addr:     nfsAddr,
+		programs: s.registered(),
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		<-ctx.Done()
+
+		ln.Close()
+		pc.Close()
+	}()
+
+	logger.Logger.Infof("Serving portmapper at %s ...", ln.Addr())
+
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+
+		pm.ServeUDP(ctx, pc)
+	}()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			select {
+			case <-ctx.Done():
+				wg.Wait()
+
+				return nil
+			default:
+				logger.Logger.Errorf("portmapper accept error: %s", err)
+				time.Sleep(10 * time.Millisecond)
+
+				continue
+			}
+		}
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			pm.ServeConn(ctx, conn)
+		}()
+	}
+}
+
+type portmapper struct {
+	addr     *net.TCPAddr // Address of the NFS listener
+	programs []rpcProgram
+}
+
+// ServeConn answers the calls on a TCP connection until the client hangs up.
+func (pm *portmapper) ServeConn(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(NewCtxReader(ctx, conn))
+
+	for {
+		header, data, err := ReceiveCall(r)
+		if errors.Is(err, io.EOF) || errors.Is(err, context.Canceled) {
+			return
+		}
+
+		if err != nil {
+			logger.Logger.Errorf("portmapper: failed to read call from %s: %v", conn.RemoteAddr(), err)
+
+			return
+		}
+
+		reply, data, err := pm.HandleProc(header, data, conn.LocalAddr(), false)
+		if err == nil {
+			err = SendReply(conn, reply, data)
+		}
+
+		if err != nil {
+			logger.Logger.Errorf("portmapper: failed to reply to %s: %v", conn.RemoteAddr(), err)
+
+			return
+		}
+	}
+}
+
+// ServeUDP answers the calls received as datagrams, which are not record marked.
+func (pm *portmapper) ServeUDP(ctx context.Context, pc net.PacketConn) {
+	buf := make([]byte, 8192)
+
+	for ctx.Err() == nil {
+		n, addr, err := pc.ReadFrom(buf)
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+
+		if err != nil {
+			logger.Logger.Errorf("portmapper: failed to read datagram: %v", err)
+
+			continue
+		}
+
+		// Add a record mark, so that the datagram can be read as a single fragment
+		var call bytes.Buffer
+
+		if err = xdr.NewEncoder(&call).Uint32(uint32(n) | 1<<31); err != nil {
+			continue
+		}
+
+		call.Write(buf[:n])
+
+		header, data, err := ReceiveCall(&call)
+		if err != nil {
+			logger.Logger.Warnf("portmapper: ignoring datagram from %s: %v", addr, err)
+
+			continue
+		}
+
+		reply, data, err := pm.HandleProc(header, data, localAddrFor(pc, addr), true)
+		if err != nil {
+			logger.Logger.Errorf("portmapper: failed to handle call from %s: %v", addr, err)
+
+			continue
+		}
+
+		var out bytes.Buffer
+
+		if err = SendReply(&out, reply, data); err != nil {
+			continue
+		}
+
+		if _, err = pc.WriteTo(out.Bytes()[4:], addr); err != nil {
+			logger.Logger.Errorf("portmapper: failed to reply to %s: %v", addr, err)
+		}
+	}
+}
+
+// localAddrFor returns the local address on which a datagram from addr was presumably received.
+// If the socket is bound to all addresses, it is the address of the route to the client.
+func localAddrFor(pc net.PacketConn, addr net.Addr) net.Addr {
+	if local, ok := pc.LocalAddr().(*net.UDPAddr); ok && !local.IP.IsUnspecified() {
+		return local
+	}
+
+	conn, err := net.Dial("udp", addr.String())
+	if err != nil {
+		return pc.LocalAddr()
+	}
+
+	defer conn.Close()
+
+	return conn.LocalAddr()
+}
+
+func (pm *portmapper) HandleProc(header *msg.RPCMsgCall, data Bytes, local net.Addr, datagram bool) (*msg.RPCMsgReply, Bytes, error) {
+	defer data.Discard()
+
+	reply := &msg.RPCMsgReply{
+		Xid:       header.Xid,
+		MsgType:   msg.RPC_REPLY,
+		ReplyStat: msg.MSG_ACCEPTED,
+	}
+
+	resp := msg.Auth{
+		Flavor: msg.AUTH_FLAVOR_NULL,
+		Body:   []byte{},
+	}
+
+	dataOut := bufpool.Get()
+
+	switch {
+	case header.Prog != msg.PMAP_PROGRAM:
+		return reply, dataOut, xdr.NewEncoder(dataOut).EncodeAll(resp, msg.ACCEPT_PROG_UNAVAIL)
+	case header.Vers < msg.PMAP_V2 || header.Vers > msg.RPCBIND_V4:
+		return reply, dataOut, xdr.NewEncoder(dataOut).EncodeAll(resp, msg.ACCEPT_PROG_MISMATCH, msg.PMAP_V2, msg.RPCBIND_V4)
+	case datagram && !datagramProc(header.Vers, header.Proc):
+		// The source address of datagrams can be spoofed, so long replies such as DUMP are only sent over TCP
+		return reply, dataOut, xdr.NewEncoder(dataOut).EncodeAll(resp, msg.ACCEPT_PROC_UNAVAIL)
+	}
+
+	logger.Logger.Tracef("PORTMAP v%d %s", header.Vers, msg.PmapProcName(header.Vers, header.Proc))
+
+	var (
+		result []interface{}
+		err    error
+	)
+
+	if header.Vers == msg.PMAP_V2 {
+		result, err = pm.pmap(header.Proc, data)
+	} else {
+		result, err = pm.rpcbind(header.Vers, header.Proc, data, local)
+	}
+
+	if xdr.IsDecodeError(err) {
+		return garbageArgs(reply, resp, dataOut)
+	}
+
+	if err != nil {
+		dataOut.Discard()
+
+		return nil, nil, err
+	}
+
+	if result == nil {
+		return reply, dataOut, xdr.NewEncoder(dataOut).EncodeAll(resp, msg.ACCEPT_PROC_UNAVAIL)
+	}
+
+	return reply, dataOut, xdr.NewEncoder(dataOut).EncodeAll(append([]interface{}{resp, msg.ACCEPT_SUCCESS}, result...)...)
+}
+
+// datagramProc returns whether the procedure is answered over UDP: only the lookups
+// of a port or address, whose replies are short.
+func datagramProc(vers, proc uint32) bool {
+	if vers == msg.PMAP_V2 {
+		return proc == msg.PMAPPROC_NULL || proc == msg.PMAPPROC_GETPORT
+	}
+
+	return proc == msg.RPCBPROC_NULL || proc == msg.RPCBPROC_GETADDR || proc == msg.RPCBPROC_GETVERSADDR
+}
+
+// pmap returns the result of a procedure of the portmapper version 2, or nil if the procedure is not available.
+func (pm *portmapper) pmap(proc uint32, data Bytes) ([]interface{}, error) {
+	var args msg.Mapping
+
+	switch proc {
+	case msg.PMAPPROC_NULL:
+		return []interface{}{}, nil
+	case msg.PMAPPROC_SET, msg.PMAPPROC_UNSET:
+		if err := xdr.NewDecoder(data).Decode(&args); err != nil {
+			return nil, err
+		}
+
+		return []interface{}{false}, nil
+	case msg.PMAPPROC_GETPORT:
+		if err := xdr.NewDecoder(data).Decode(&args); err != nil {
+			return nil, err
+		}
+
+		// As rpcbind, return the port of another version if the version is not served,
+		// so that the client learns about the supported versions from the server
+		if args.Prot != msg.IPPROTO_TCP || !pm.serves(args.Prog, 0) {
+			return []interface{}{uint32(0)}, nil
+		}
+
+		return []interface{}{uint32(pm.addr.Port)}, nil
+	case msg.PMAPPROC_DUMP:
+		var list msg.PmapList
+
+		for i := len(pm.programs) - 1; i >= 0; i-- {
+			list.Entries = &msg.PmapNode{
+				Map: msg.Mapping{
+					Prog: pm.programs[i].Prog,
+					Vers: pm.programs[i].Vers,
+					Prot: msg.IPPROTO_TCP,
+					Port: uint32(pm.addr.Port),
+				},
+				Next: list.Entries,
+			}
+		}
+
+		return []interface{}{list}, nil
+	default:
+		return nil, nil
+	}
+}
+
+// rpcbind returns the result of a procedure of rpcbind versions 3 and 4, or nil if the procedure is not available.
+func (pm *portmapper) rpcbind(vers, proc uint32, data Bytes, local net.Addr) ([]interface{}, error) {
+	var args msg.Rpcb
+
+	switch proc {
+	case msg.RPCBPROC_NULL:
+		return []interface{}{}, nil
+	case msg.RPCBPROC_SET, msg.RPCBPROC_UNSET:
+		if err := xdr.NewDecoder(data).Decode(&args); err != nil {
+			return nil, err
+		}
+
+		return []interface{}{false}, nil
+	case msg.RPCBPROC_GETADDR, msg.RPCBPROC_GETVERSADDR:
+		if proc == msg.RPCBPROC_GETVERSADDR && vers < msg.RPCBIND_V4 {
+			return nil, nil
+		}
+
+		if err := xdr.NewDecoder(data).Decode(&args); err != nil {
+			return nil, err
+		}
+
+		// GETVERSADDR only returns the address of the requested version
+		vers := args.Vers
+
+		if proc == msg.RPCBPROC_GETADDR {
+			vers = 0
+		}
+
+		netid, uaddr := pm.uaddr(local)
+
+		if !pm.serves(args.Prog, vers) || (args.Netid != "" && args.Netid != netid) {
+			uaddr = ""
+		}
+
+		return []interface{}{uaddr}, nil
+	case msg.RPCBPROC_DUMP:
+		var list msg.RpcbList
+
+		netid, uaddr := pm.uaddr(local)
+
+		for i := len(pm.programs) - 1; i >= 0; i-- {
+			list.Entries = &msg.RpcbNode{
+				Map: msg.Rpcb{
+					Prog:  pm.programs[i].Prog,
+					Vers:  pm.programs[i].Vers,
+					Netid: netid,
+					Addr:  uaddr,
+					Owner: "superuser",
+				},
+				Next: list.Entries,
+			}
+		}
+
+		return []interface{}{list}, nil
+	case msg.RPCBPROC_GETTIME:
+		return []interface{}{uint32(clock.Now().Unix())}, nil
+	default:
+		return nil, nil
+	}
+}
+
+// serves returns whether the program is served, in the given version if not zero.
+func (pm *portmapper) serves(prog, vers uint32) bool {
+	for _, p := range pm.programs {
+		if p.Prog == prog && (vers == 0 || p.Vers == vers) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// uaddr returns the netid and universal address of the NFS listener, as seen by a client
+// that connected to the local address. If the listener is bound to all addresses,
+// the local address of the client is used.
+func (pm *portmapper) uaddr(local net.Addr) (string, string) {
+	addr := &net.TCPAddr{
+		IP:   pm.addr.IP,
+		Port: pm.addr.Port,
+	}
+
+	if addr.IP == nil || addr.IP.IsUnspecified() {
+		switch l := local.(type) {
+		case *net.TCPAddr:
+			addr.IP = l.IP
+		case *net.UDPAddr:
+			addr.IP = l.IP
+		}
+	}
+
+	if ip4 := addr.IP.To4(); ip4 != nil {
+		addr.IP = ip4
+	}
+
+	loc, _ := universalAddress(addr)
+
+	return loc.NetAddr.Netid, loc.NetAddr.Addr
+}
@@ -0,0 +1,566 @@
+package nfs4go
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/kuleuven/nfs4go/auth"
+	"github.com/kuleuven/nfs4go/bufpool"
+	"github.com/kuleuven/nfs4go/clock"
+	"github.com/kuleuven/nfs4go/logger"
+	"github.com/kuleuven/nfs4go/msg"
+	"github.com/kuleuven/nfs4go/xdr"
+)
+
+// RemoteDialTimeout is the time to wait for the source server of an inter-server copy.
+var RemoteDialTimeout = 10 * time.Second
+
+// errOffloadDenied is returned if the source file of an inter-server copy cannot be read,
+// so that the client falls back to a normal copy.
+var errOffloadDenied = msg.Error(msg.NFS4ERR_OFFLOAD_DENIED)
+
+// remoteReadSize is the amount of data requested in a single READ from the source server.
+const remoteReadSize = 1024 * 1024
+
+// remoteClient is a minimal NFSv4.2 client, used by the destination server
+// of an inter-server copy to read the source file from the source server.
+type remoteClient struct {
+	conn      net.Conn
+	r         *bufio.Reader
+	cred      msg.Auth
+	xid       uint32
+	sessionID [16]byte
+	seqID     uint32
+	clientID  uint64
+	lock      sync.Mutex
+}
+
+// dialRemote connects to the first reachable server in the list, and creates a session
+// on behalf of the given credentials. If reserved is set, it connects from a privileged port.
+func dialRemote(ctx context.Context, addresses []string, creds *auth.Creds, reserved bool) (*remoteClient, error) {
+	var errs []error
+
+	for _, address := range addresses {
+		c, err := dialRemoteAddress(ctx, address, creds, reserved)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", address, err))
+
+			continue
+		}
+
+		return c, nil
+	}
+
+	if len(errs) == 0 {
+		return nil, errors.New("no source server")
+	}
+
+	return nil, errors.Join(errs...)
+}
+
+func dialRemoteAddress(ctx context.Context, address string, creds *auth.Creds, reserved bool) (*remoteClient, error) {
+	ctx, cancel := context.WithTimeout(ctx, RemoteDialTimeout)
+	defer cancel()
+
+	var (
+		conn net.Conn
+		err  error
+	)
+
+	if reserved {
+		conn, err = dialReserved(ctx, address)
+	} else {
+		var dialer net.Dialer
+
+		conn, err = dialer.DialContext(ctx, "tcp", address)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	hostname, _ := os.Hostname() //nolint:errcheck
+
+	var body bytes.Buffer
+
+	err = xdr.NewEncoder(&body).Encode(auth.Creds{
+		ExpirationValue:  uint32(clock.Now().Unix()),
+		Hostname:         hostname,
+		UID:              creds.UID,
+		GID:              creds.GID,
+		AdditionalGroups: creds.AdditionalGroups,
+	})
+	if err != nil {
+		conn.Close()
+
+		return nil, err
+	}
+
+	c := &remoteClient{
+		conn: conn,
+		r:    bufio.NewReader(conn),
+		cred: msg.Auth{
+			Flavor: msg.AUTH_FLAVOR_UNIX,
+			Body:   body.Bytes(),
+		},
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline) //nolint:errcheck
+	}
+
+	if err = c.createSession(hostname); err != nil {
+		conn.Close()
+
+		return nil, err
+	}
+
+	conn.SetDeadline(time.Time{}) //nolint:errcheck
+
+	return c, nil
+}
+
+// dialReserved connects from a privileged source port if possible,
+// as servers refuse other ports by default.
+func dialReserved(ctx context.Context, address string) (net.Conn, error) {
+	for port := 1023; port >= 665; port-- {
+		dialer := net.Dialer{
+			LocalAddr: &net.TCPAddr{Port: port},
+		}
+
+		conn, err := dialer.DialContext(ctx, "tcp", address)
+		if err == nil {
+			return conn, nil
+		}
+
+		if errors.Is(err, syscall.EADDRINUSE) || errors.Is(err, syscall.EADDRNOTAVAIL) {
+			continue
+		}
+
+		if errors.Is(err, syscall.EACCES) || errors.Is(err, syscall.EPERM) {
+			break
+		}
+
+		return nil, err
+	}
+
+	var dialer net.Dialer
+
+	return dialer.DialContext(ctx, "tcp", address)
+}
+
+func (c *remoteClient) createSession(hostname string) error {
+	var res msg.EXCHANGE_ID4resok
+
+	// Each copy uses its own client, so that it can be destroyed afterwards
+	other := randOther()
+
+	err := c.compound([]interface{}{
+		msg.OP4_EXCHANGE_ID,
+		msg.EXCHANGE_ID4args{
+			ClientOwner: msg.ClientOwner4{
+				Verifier: uint64(other[0])<<32 | uint64(other[1]),
+				OwnerId:  []byte(fmt.Sprintf("nfs4go-copy-%s-%08x%08x%08x", hostname, other[0], other[1], other[2])),
+			},
+			StateProtect: msg.StateProtect4{
+				How: msg.SP4_NONE,
+			},
+		},
+	}, &res)
+	if err != nil {
+		return fmt.Errorf("EXCHANGE_ID: %w", err)
+	}
+
+	c.clientID = res.ClientID
+
+	attrs := msg.ChannelAttrs4{
+		MaxRequestSize:        remoteReadSize,
+		MaxResponseSize:       remoteReadSize + 1024,
+		MaxResponseSizeCached: 1024,
+		MaxOperations:         8,
+		MaxRequests:           1,
+	}
+
+	var session msg.CREATE_SESSION4resok
+
+	err = c.compound([]interface{}{
+		msg.OP4_CREATE_SESSION,
+		msg.CREATE_SESSION4args{
+			ClientID:      res.ClientID,
+			SequenceID:    res.SequenceID,
+			ForeChanAttrs: attrs,
+			BackChanAttrs: attrs,
+			SecParms:      []byte{},
+		},
+	}, &session)
+	if err != nil {
+		return fmt.Errorf("CREATE_SESSION: %w", err)
+	}
+
+	c.sessionID = session.SessionID
+
+	return nil
+}
+
+// Read reads from the file with the given handle on the source server, using the
+// stateid that was returned by COPY_NOTIFY.
+func (c *remoteClient) Read(fh []byte, stateID msg.StateId4, p []byte, offset int64) (int, bool, error) {
+	var (
+		seq msg.SEQUENCE4resok
+		res msg.READ4resok
+	)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.seqID++
+
+	err := c.compound([]interface{}{
+		msg.OP4_SEQUENCE,
+		msg.SEQUENCE4args{
+			SessionID:  c.sessionID,
+			SequenceID: c.seqID,
+		},
+		msg.OP4_PUTFH,
+		msg.PUTFH4args{
+			Fh: fh,
+		},
+		msg.OP4_READ,
+		msg.READ4args{
+			StateId: stateID,
+			Offset:  uint64(offset),
+			Count:   uint32(min(len(p), remoteReadSize)),
+		},
+	}, &seq, nil, &res)
+	if err != nil {
+		return 0, false, err
+	}
+
+	return copy(p, res.Data), res.Eof, nil
+}
+
+// Close destroys the session and the client id, and closes the connection.
+func (c *remoteClient) Close() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.conn.SetDeadline(clock.Now().Add(RemoteDialTimeout)) //nolint:errcheck
+
+	err := c.compound([]interface{}{msg.OP4_DESTROY_SESSION, c.sessionID}, nil)
+	if err == nil {
+		err = c.compound([]interface{}{msg.OP4_DESTROY_CLIENTID, c.clientID}, nil)
+	}
+
+	return errors.Join(err, c.conn.Close())
+}
+
+// compound sends a COMPOUND with the given operations and their arguments, and decodes
+// the results of the operations in results. A nil result is used for operations without one.
+func (c *remoteClient) compound(ops []interface{}, results ...interface{}) error {
+	buf := bufpool.Get()
+
+	err := xdr.NewEncoder(buf).EncodeAll(
+		"",        // tag
+		uint32(2), // minorversion
+		uint32(len(results)),
+	)
+	if err == nil {
+		err = xdr.NewEncoder(buf).EncodeAll(ops...)
+	}
+
+	if err != nil {
+		buf.Discard()
+
+		return err
+	}
+
+	c.xid++
+
+	header := &msg.RPCMsgCall{
+		Xid:     c.xid,
+		MsgType: msg.RPC_CALL,
+		RPCVer:  2,
+		Prog:    msg.NFS4_PROGRAM,
+		Vers:    4,
+		Proc:    msg.PROC4_COMPOUND,
+		Cred:    c.cred,
+		Verf:    msg.Auth{Flavor: msg.AUTH_FLAVOR_NULL, Body: []byte{}},
+	}
+
+	if err = SendCall(c.conn, header, buf); err != nil {
+		return err
+	}
+
+	_, reply, data, err := ReceiveMessage(c.r)
+	if data != nil {
+		defer data.Discard()
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if reply == nil || reply.Xid != header.Xid {
+		return errors.New("unexpected message from source server")
+	}
+
+	if reply.ReplyStat != msg.MSG_ACCEPTED {
+		return errors.New("call denied by source server")
+	}
+
+	return decodeCompoundResults(data, results)
+}
+
+func decodeCompoundResults(r io.Reader, results []interface{}) error {
+	var (
+		verf       msg.Auth
+		acceptStat uint32
+		status     uint32
+		tag        string
+		count      uint32
+	)
+
+	decoder := xdr.NewDecoder(r)
+
+	if err := decoder.DecodeAll(&verf, &acceptStat); err != nil {
+		return err
+	}
+
+	if acceptStat != msg.ACCEPT_SUCCESS {
+		return fmt.Errorf("call not accepted by source server: %d", acceptStat)
+	}
+
+	if err := decoder.DecodeAll(&status, &tag, &count); err != nil {
+		return err
+	}
+
+	for _, result := range results[:min(int(count), len(results))] {
+		var op, opStatus uint32
+
+		if err := decoder.DecodeAll(&op, &opStatus); err != nil {
+			return err
+		}
+
+		if opStatus != msg.NFS4_OK {
+			return msg.Error(opStatus)
+		}
+
+		if result == nil {
+			continue
+		}
+
+		if err := decoder.Decode(result); err != nil {
+			return err
+		}
+	}
+
+	if status != msg.NFS4_OK {
+		return msg.Error(status)
+	}
+
+	return nil
+}
+
+// remoteFile reads a file from the source server of an inter-server copy.
+type remoteFile struct {
+	client  *remoteClient
+	handle  []byte
+	stateID msg.StateId4
+}
+
+func (f *remoteFile) ReadAt(p []byte, offset int64) (int, error) {
+	var read int
+
+	for read < len(p) {
+		n, eof, err := f.client.Read(f.handle, f.stateID, p[read:], offset+int64(read))
+
+		read += n
+
+		if err != nil {
+			logger.Logger.Warnf("failed to read from source server: %v", err)
+
+			return read, errOffloadDenied
+		}
+
+		if eof || n == 0 {
+			return read, io.EOF
+		}
+	}
+
+	return read, nil
+}
+
+func (f *remoteFile) Close() error {
+	return f.client.Close()
+}
+
+// universalAddress returns the netloc of a TCP address, as used in COPY_NOTIFY.
+func universalAddress(addr net.Addr) (msg.Netloc4, bool) {
+	tcp, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return msg.Netloc4{}, false
+	}
+
+	netid := "tcp"
+
+	if tcp.IP.To4() == nil {
+		netid = "tcp6"
+	}
+
+	return msg.Netloc4{
+		Type: msg.NL4_NETADDR,
+		NetAddr: msg.NetAddr4{
+			Netid: netid,
+			Addr:  fmt.Sprintf("%s.%d.%d", tcp.IP.String(), tcp.Port>>8, tcp.Port&0xff),
+		},
+	}, true
+}
+
+// netlocAddress returns the address to dial for a netloc.
+func netlocAddress(loc msg.Netloc4) (string, error) {
+	switch loc.Type {
+	case msg.NL4_NAME:
+		return net.JoinHostPort(loc.Name, "2049"), nil
+
+	case msg.NL4_URL:
+		u, err := url.Parse(loc.URL)
+		if err != nil {
+			return "", err
+		}
+
+		if u.Port() == "" {
+			return net.JoinHostPort(u.Hostname(), "2049"), nil
+		}
+
+		return u.Host, nil
+
+	case msg.NL4_NETADDR:
+		// The universal address is the ip address followed by two port octets
+		parts := strings.Split(loc.NetAddr.Addr, ".")
+		if len(parts) < 3 {
+			return "", fmt.Errorf("invalid universal address: %s", loc.NetAddr.Addr)
+		}
+
+		hi, err1 := strconv.ParseUint(parts[len(parts)-2], 10, 8)
+		lo, err2 := strconv.ParseUint(parts[len(parts)-1], 10, 8)
+
+		if err := errors.Join(err1, err2); err != nil {
+			return "", fmt.Errorf("invalid universal address: %s", loc.NetAddr.Addr)
+		}
+
+		ip := strings.Join(parts[:len(parts)-2], ".")
+
+		return net.JoinHostPort(ip, strconv.FormatUint(hi<<8|lo, 10)), nil
+
+	default:
+		return "", fmt.Errorf("unsupported netloc type %d", loc.Type)
+	}
+}
+
+// interServerCopySource checks whether the remaining operations of a compound start with
+// SAVEFH, PUTFH and a COPY from another server, and returns the source server of the COPY.
+// In that case, the preceding PUTFH refers to a file on the source server.
+func interServerCopySource(rest []byte) []msg.Netloc4 {
+	var (
+		op   uint32
+		fh   msg.PUTFH4args
+		args msg.COPY4args
+	)
+
+	decoder := xdr.NewDecoder(bytes.NewReader(rest))
+
+	if err := decoder.Decode(&op); err != nil || op != msg.OP4_SAVEFH {
+		return nil
+	}
+
+	if err := decoder.DecodeAll(&op, &fh); err != nil || op != msg.OP4_PUTFH {
+		return nil
+	}
+
+	if err := decoder.DecodeAll(&op, &args); err != nil || op != msg.OP4_COPY {
+		return nil
+	}
+
+	return args.SourceServer
+}
+
+// copySources returns the addresses of the given source servers of an inter-server copy
+// that are configured in CopySources. Other servers are never connected to.
+func (x *Compound) copySources(servers []msg.Netloc4) []string {
+	var addresses []string
+
+	for _, loc := range servers {
+		address, err := netlocAddress(loc)
+		if err != nil {
+			continue
+		}
+
+		if slices.ContainsFunc(x.CopySources, func(source string) bool { return sameAddress(source, address) }) {
+			addresses = append(addresses, address)
+		}
+	}
+
+	return addresses
+}
+
+// sameAddress returns whether two addresses of the form host:port are equal.
+func sameAddress(a, b string) bool {
+	hostA, portA, errA := net.SplitHostPort(a)
+	hostB, portB, errB := net.SplitHostPort(b)
+
+	if errA != nil || errB != nil || portA != portB {
+		return false
+	}
+
+	if ipA, ipB := net.ParseIP(hostA), net.ParseIP(hostB); ipA != nil && ipB != nil {
+		return ipA.Equal(ipB)
+	}
+
+	return strings.EqualFold(hostA, hostB)
+}
+
+// resolveNetloc returns the ip addresses of the host of a netloc.
+func resolveNetloc(ctx context.Context, loc msg.Netloc4) []net.IP {
+	address, err := netlocAddress(loc)
+	if err != nil {
+		return nil
+	}
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, RemoteDialTimeout)
+	defer cancel()
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil
+	}
+
+	ips := make([]net.IP, 0, len(addrs))
+
+	for _, addr := range addrs {
+		ips = append(ips, addr.IP)
+	}
+
+	return ips
+}
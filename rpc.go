@@ -1,6 +1,7 @@
 package nfs4go
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
@@ -11,62 +12,230 @@ import (
 )
 
 func ReceiveCall(r io.Reader) (*msg.RPCMsgCall, Bytes, error) {
-	decoder := xdr.NewDecoder(r)
+	header, reply, data, err := ReceiveMessage(r)
+	if err == nil && reply != nil {
+		data.Discard()
 
-	frag, err := decoder.Uint32()
-	if err != nil {
-		return nil, nil, err
+		return nil, nil, errors.New("expecting a rpc call message")
 	}
 
-	if frag&(1<<31) == 0 {
-		return nil, nil, errors.New("(!)ignored: fragmented request")
+	return header, data, err
+}
+
+// MaxRecordSize is the maximum size of a received RPC message, which can consist of
+// multiple fragments. Larger messages are refused, which closes the connection.
+var MaxRecordSize = 16 * 1024 * 1024
+
+// MaxFragmentSize is the maximum size of the fragments of the messages that are sent.
+// Larger messages are split in multiple fragments.
+var MaxFragmentSize = 1<<31 - 1
+
+// lastFragment marks the last fragment of a record in the record marking standard (rfc5531 section 11).
+const lastFragment = uint32(1 << 31)
+
+// ReceiveMessage reads the next RPC message. Clients send calls, but also replies to
+// the calls that the server sent over the backchannel. Either the call header or the
+// reply header is returned, together with the remaining data of the message.
+// Messages that consist of multiple fragments are reassembled.
+func ReceiveMessage(r io.Reader) (*msg.RPCMsgCall, *msg.RPCMsgReply, Bytes, error) {
+	record := &recordReader{r: r}
+
+	if err := record.next(); err != nil {
+		return nil, nil, nil, err
 	}
 
-	headerSize := (frag << 1) >> 1
-	restSize := int(headerSize)
+	decoder := xdr.NewDecoder(record)
+
+	var xid, msgType uint32
+
+	if err := decoder.DecodeAll(&xid, &msgType); err != nil {
+		return nil, nil, nil, err
+	}
+
+	switch msgType {
+	case msg.RPC_CALL:
+		header := &msg.RPCMsgCall{
+			Xid:     xid,
+			MsgType: msgType,
+		}
+
+		err := decoder.DecodeAll(&header.RPCVer, &header.Prog, &header.Vers, &header.Proc, &header.Cred, &header.Verf)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("ReadAs(%T): %v", header, err)
+		}
+
+		data, err := record.readAll()
+
+		return header, nil, data, err
+
+	case msg.RPC_REPLY:
+		reply := &msg.RPCMsgReply{
+			Xid:     xid,
+			MsgType: msgType,
+		}
+
+		var err error
 
-	header := &msg.RPCMsgCall{}
+		if reply.ReplyStat, err = decoder.Uint32(); err != nil {
+			return nil, nil, nil, err
+		}
 
-	size, err := decoder.Read(header)
+		data, err := record.readAll()
+
+		return nil, reply, data, err
+
+	default:
+		return nil, nil, nil, fmt.Errorf("unknown rpc message type %d", msgType)
+	}
+}
+
+// recordReader reads the fragments of a single record as one stream.
+type recordReader struct {
+	r      io.Reader
+	remain int  // Remaining bytes of the current fragment
+	last   bool // Whether the current fragment is the last one
+	size   int  // Size of the record so far
+}
+
+// next reads the header of the next fragment.
+func (rr *recordReader) next() error {
+	frag, err := xdr.NewDecoder(rr.r).Uint32()
 	if err != nil {
-		return nil, nil, fmt.Errorf("ReadAs(%T): %v", header, err)
+		return err
 	}
 
-	restSize -= size
+	rr.last = frag&lastFragment != 0
+	rr.remain = int(frag &^ lastFragment)
+	rr.size += rr.remain
 
-	if header.MsgType != msg.RPC_CALL {
-		return nil, nil, errors.New("expecting a rpc call message")
+	if rr.size > MaxRecordSize {
+		return fmt.Errorf("record exceeds maximum size of %d bytes", MaxRecordSize)
+	}
+
+	return nil
+}
+
+func (rr *recordReader) Read(p []byte) (int, error) {
+	for rr.remain == 0 {
+		if rr.last {
+			return 0, io.EOF
+		}
+
+		if err := rr.next(); err != nil {
+			return 0, err
+		}
+	}
+
+	if len(p) > rr.remain {
+		p = p[:rr.remain]
+	}
+
+	n, err := rr.r.Read(p)
+
+	rr.remain -= n
+
+	if errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
 	}
 
+	return n, err
+}
+
+// readAll reads the remainder of the record into a pooled buffer.
+func (rr *recordReader) readAll() (Bytes, error) {
 	buf := bufpool.Get()
 
-	data := buf.Allocate(restSize)
-	n, err := io.ReadFull(r, data)
+	for {
+		if rr.remain > 0 {
+			data := buf.Allocate(rr.remain)
+			n, err := io.ReadFull(rr.r, data)
+
+			buf.Commit(n)
+
+			rr.remain -= n
 
-	buf.Commit(n)
+			if err != nil {
+				return buf, err
+			}
+		}
 
-	return header, buf, err
+		if rr.last {
+			return buf, nil
+		}
+
+		if err := rr.next(); err != nil {
+			return buf, err
+		}
+	}
 }
 
 func SendReply(w io.Writer, reply *msg.RPCMsgReply, data Bytes) error {
 	defer data.Discard()
 
-	payload := data.Bytes()
-	encoder := xdr.NewEncoder(w)
-	length := 12 + len(payload)
-	frag := uint32(length) | uint32(1<<31)
+	var header bytes.Buffer
 
-	if err := encoder.Uint32(frag); err != nil {
+	if err := xdr.NewEncoder(&header).Encode(reply); err != nil {
 		return err
 	}
 
-	if err := encoder.Encode(reply); err != nil {
+	return sendRecord(w, header.Bytes(), data.Bytes())
+}
+
+// SendCall sends a call to the client, used for callbacks over the backchannel.
+func SendCall(w io.Writer, call *msg.RPCMsgCall, data Bytes) error {
+	defer data.Discard()
+
+	var header bytes.Buffer
+
+	if err := xdr.NewEncoder(&header).Encode(call); err != nil {
 		return err
 	}
 
-	if _, err := w.Write(payload); err != nil {
-		return err
+	return sendRecord(w, header.Bytes(), data.Bytes())
+}
+
+// sendRecord writes a record that consists of the given parts,
+// split in fragments of at most MaxFragmentSize bytes.
+func sendRecord(w io.Writer, parts ...[]byte) error {
+	var remaining int
+
+	for _, part := range parts {
+		remaining += len(part)
 	}
 
-	return nil
+	encoder := xdr.NewEncoder(w)
+
+	for {
+		size := min(remaining, MaxFragmentSize)
+		remaining -= size
+
+		frag := uint32(size)
+
+		if remaining == 0 {
+			frag |= lastFragment
+		}
+
+		if err := encoder.Uint32(frag); err != nil {
+			return err
+		}
+
+		for size > 0 {
+			n := min(size, len(parts[0]))
+
+			if _, err := w.Write(parts[0][:n]); err != nil {
+				return err
+			}
+
+			parts[0] = parts[0][n:]
+			size -= n
+
+			if len(parts[0]) == 0 {
+				parts = parts[1:]
+			}
+		}
+
+		if remaining == 0 {
+			return nil
+		}
+	}
 }
@@ -1,6 +1,7 @@
 package nfs4go
 
 import (
+	"bufio"
 	"context"
 	"crypto/md5" //nolint:gosec
 	"errors"
@@ -13,9 +14,12 @@ import (
 	"github.com/kuleuven/nfs4go/auth"
 	"github.com/kuleuven/nfs4go/bufpool"
 	"github.com/kuleuven/nfs4go/clients"
+	"github.com/kuleuven/nfs4go/idmap"
+	"github.com/kuleuven/nfs4go/locks"
 	"github.com/kuleuven/nfs4go/logger"
 	"github.com/kuleuven/nfs4go/msg"
 	"github.com/kuleuven/nfs4go/worker"
+	"github.com/kuleuven/nfs4go/xdr"
 	"github.com/kuleuven/vfs"
 	"github.com/kuleuven/vfs/fs/errorfs"
 	"github.com/sirupsen/logrus"
@@ -23,13 +27,30 @@ import (
 
 // A Server represents the NFS server. It should be created using Listen or New.
 type Server struct {
+	// Export contains the policy applied to connecting clients.
+	// It should not be modified after Serve is called.
+	Export Export
+
+	// Metrics contains counters about the operation of the server.
+	Metrics Metrics
+
+	// IDMapper translates the owner and owner_group attributes.
+	// If nil, numeric strings are used.
+	IDMapper *idmap.Mapper
+
 	listener net.Listener
 	loader   RootLoader
 
-	clients *clients.Clients
-	workers map[[16]byte]map[uint32]*worker.Worker
-	wg      sync.WaitGroup
-	lock    sync.Mutex
+	clients  *clients.Clients
+	copies   *CopyEngine
+	layouts  *Layouts
+	mounts   *Mounts
+	locks    *locks.Manager
+	monitor  *Monitor
+	programs map[rpcProgram]Program
+	workers  map[[16]byte]map[uint32]*worker.Worker
+	wg       sync.WaitGroup
+	lock     sync.Mutex
 }
 
 // Listen creates a new Server listening on the specified address and using the provided RootLoader.
@@ -48,9 +69,16 @@ type RootLoader func(ctx context.Context, conn net.Conn, creds *auth.Creds) (vfs
 // New returns a new server with the given listener (e.g. net.Listen, tls.Listen, etc.)
 func New(l net.Listener, loader RootLoader) (*Server, error) {
 	return &Server{
+		Export:   DefaultExport,
 		listener: l,
 		loader:   loader,
 		clients:  clients.New(),
+		copies:   NewCopyEngine(),
+		layouts:  NewLayouts(),
+		mounts:   NewMounts(),
+		locks:    locks.New(),
+		monitor:  NewMonitor(),
+		programs: defaultPrograms(),
 		workers:  make(map[[16]byte]map[uint32]*worker.Worker),
 	}, nil
 }
@@ -87,13 +115,14 @@ func (s *Server) Serve(ctx context.Context) error {
 			}
 		}
 
-		// Disallow unprivileged ports
+		// Disallow unprivileged ports, unless the export policy allows them
 		if tcp, ok := conn.RemoteAddr().(*net.TCPAddr); !ok {
 			logrus.Error("only TCP connections are allowed, but got: ", conn.RemoteAddr())
 			conn.Close()
 
 			continue
-		} else if tcp.Port >= 1024 {
+		} else if !s.Export.AllowPort(tcp) {
+			s.Metrics.RejectedConnections.Add(1)
 			s.wg.Add(1)
 
 			go s.HandleTrap(ctx, conn)
@@ -107,25 +136,54 @@ func (s *Server) Serve(ctx context.Context) error {
 	}
 }
 
+// HandleTrap handles a connection that is refused by the export policy.
+// Every RPC call on the connection is answered with MSG_DENIED and AUTH_TOOWEAK,
+// so that the client reports a meaningful error, until the client hangs up.
 func (s *Server) HandleTrap(ctx context.Context, conn net.Conn) {
 	defer s.wg.Done()
 
 	defer conn.Close()
 
-	buf := make([]byte, 128)
+	logger.Logger.Warnf("refusing connection from unprivileged port: %s", conn.RemoteAddr())
 
-	n, err := NewCtxReader(ctx, conn).Read(buf)
-	if n == 0 && errors.Is(err, io.EOF) {
-		return
-	}
+	r := bufio.NewReader(NewCtxReader(ctx, conn))
 
-	if err != nil {
-		logger.Logger.Errorf("failed to read trap: %v", err)
+	for {
+		header, data, err := ReceiveCall(r)
+		if errors.Is(err, io.EOF) || errors.Is(err, context.Canceled) {
+			return
+		}
 
-		return
-	}
+		if err != nil {
+			logger.Logger.Errorf("failed to read call on refused connection from %s: %v", conn.RemoteAddr(), err)
+
+			return
+		}
+
+		s.Metrics.RejectedCalls.Add(1)
 
-	logrus.Errorf("received data from unprivileged port: %d bytes from %s", n, conn.RemoteAddr())
+		data.Reset()
+
+		if err = xdr.NewEncoder(data).EncodeAll(msg.REJECT_AUTH_ERROR, msg.AUTH_TOOWEAK); err != nil {
+			data.Discard()
+
+			logger.Logger.Errorf("failed to encode reply: %v", err)
+
+			return
+		}
+
+		reply := &msg.RPCMsgReply{
+			Xid:       header.Xid,
+			MsgType:   msg.RPC_REPLY,
+			ReplyStat: msg.MSG_DENIED,
+		}
+
+		if err = SendReply(conn, reply, data); err != nil {
+			logger.Logger.Errorf("failed to send reply on refused connection from %s: %v", conn.RemoteAddr(), err)
+
+			return
+		}
+	}
 }
 
 type Request struct {
@@ -135,6 +193,7 @@ type Request struct {
 
 type Response struct {
 	Reply *msg.RPCMsgReply
+	Call  *msg.RPCMsgCall // If set, Data is sent as a call to the client over the backchannel
 	Data  Bytes
 	Error error
 }
@@ -150,8 +209,26 @@ func (s *Server) HandleConn(ctx context.Context, conn net.Conn) {
 	defer conn.Close()
 
 	sess := &Conn{
-		Conn:    conn,
-		Clients: s.clients,
+		Conn:     conn,
+		Clients:  s.clients,
+		IDMapper: s.IDMapper,
+		Signer:   s.Export.HandleSigner,
+		Copies:   s.copies,
+		Layout:   s.Export.Layout,
+		Layouts:  s.layouts,
+
+		CopySources:      s.Export.CopySources,
+		CopyReservedPort: s.Export.CopyReservedPort,
+
+		DataServer: s.Export.DataServer,
+		Xattrs:     s.Export.Xattrs,
+		Mounts:     s.mounts,
+		ExportPath: s.Export.MountPath(),
+		Locks:      s.locks,
+		Monitor:    s.monitor,
+
+		programs: s.getPrograms(),
+
 		FS: func(creds *auth.Creds, sessionID [16]byte) *worker.Worker {
 			return s.GetWorker(ctx, conn, creds, sessionID)
 		},
@@ -0,0 +1,136 @@
+package nfs4go
+
+import (
+	"errors"
+	"io"
+
+	"github.com/kuleuven/nfs4go/msg"
+	"github.com/kuleuven/vfs"
+)
+
+// SparseFile can be implemented by open files to report the holes in sparse files.
+// The semantics are those of lseek(2) with SEEK_DATA and SEEK_HOLE: SeekData returns the
+// start of the next data region at or after offset, SeekHole the start of the next hole,
+// where the end of the file counts as a hole. Both return io.EOF if offset is beyond the
+// end of the file, and SeekData also if there is no more data.
+type SparseFile interface {
+	SeekData(offset int64) (int64, error)
+	SeekHole(offset int64) (int64, error)
+}
+
+// sparseFile returns the SparseFile implementation of an open file, if any.
+// On linux, files that opt in as NativeFile use SEEK_DATA and SEEK_HOLE.
+func sparseFile(f vfs.WriterAtReaderAt) (SparseFile, bool) {
+	if s, ok := findFile[SparseFile](f); ok {
+		return s, true
+	}
+
+	return findNative[SparseFile](f)
+}
+
+// seekFile returns the offset of the next data region or hole at or after offset,
+// depending on what. If the file cannot report holes, the whole file is treated as data.
+func seekFile(f vfs.WriterAtReaderAt, offset int64, what uint32, size int64) (int64, error) {
+	if s, ok := sparseFile(f); ok {
+		if what == msg.NFS4_CONTENT_HOLE {
+			return s.SeekHole(offset)
+		}
+
+		return s.SeekData(offset)
+	}
+
+	if offset >= size {
+		return 0, io.EOF
+	}
+
+	if what == msg.NFS4_CONTENT_HOLE {
+		return size, nil
+	}
+
+	return offset, nil
+}
+
+// readPlus reads count bytes at offset into buf, and returns the content as a list of
+// data and hole segments. If the file cannot report holes, a single data segment is returned.
+// The returned bool indicates whether the end of the file was reached.
+func readPlus(f vfs.WriterAtReaderAt, buf []byte, offset int64, size int64) ([]msg.ReadPlusContent4, bool, error) {
+	s, ok := sparseFile(f)
+	if !ok {
+		n, err := f.ReadAt(buf, offset)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, false, err
+		}
+
+		return []msg.ReadPlusContent4{dataSegment(offset, buf[:n])}, errors.Is(err, io.EOF) || offset+int64(n) >= size, nil
+	}
+
+	var (
+		contents []msg.ReadPlusContent4
+		pos      = offset
+		end      = min(offset+int64(len(buf)), size)
+	)
+
+	for pos < end {
+		data, err := s.SeekData(pos)
+		if errors.Is(err, io.EOF) {
+			data = end
+		} else if err != nil {
+			return nil, false, err
+		}
+
+		if data > pos {
+			hole := min(data, end)
+
+			contents = append(contents, msg.ReadPlusContent4{
+				What: msg.NFS4_CONTENT_HOLE,
+				Hole: msg.DataInfo4{
+					Offset: uint64(pos),
+					Length: uint64(hole - pos),
+				},
+			})
+
+			pos = hole
+
+			continue
+		}
+
+		hole, err := s.SeekHole(pos)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, false, err
+		}
+
+		hole = min(max(hole, pos), end)
+
+		if hole == pos {
+			hole = end
+		}
+
+		b := buf[pos-offset : hole-offset]
+
+		n, err := f.ReadAt(b, pos)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, false, err
+		}
+
+		contents = append(contents, dataSegment(pos, b[:n]))
+
+		pos += int64(n)
+
+		if n < len(b) {
+			// The file was truncated while reading
+			break
+		}
+	}
+
+	return contents, pos >= size, nil
+}
+
+func dataSegment(offset int64, data []byte) msg.ReadPlusContent4 {
+	return msg.ReadPlusContent4{
+		What: msg.NFS4_CONTENT_DATA,
+		Data: msg.Data4{
+			Offset: uint64(offset),
+			Data:   data,
+		},
+	}
+}
@@ -1,7 +1,10 @@
 package worker
 
 import (
+	"bytes"
+
 	"github.com/kuleuven/nfs4go/clients"
+	"github.com/kuleuven/nfs4go/locks"
 	"github.com/kuleuven/vfs"
 )
 
@@ -10,6 +13,7 @@ type File struct {
 	Handle      []byte
 	Client      *clients.Client
 	ClientSeqID uint32
+	LockOwners  []locks.Owner // Lock owners that locked the file through this open file
 }
 
 func (w *Worker) AddFile(file *File) uint64 {
@@ -51,6 +55,22 @@ func (w *Worker) GetFileByClientSeqID(client *clients.Client, clientSeqID uint32
 	return 0, false
 }
 
+// GetFilesByHandle returns the open files of the client for the given handle.
+func (w *Worker) GetFilesByHandle(client *clients.Client, handle []byte) []*File {
+	w.Lock()
+	defer w.Unlock()
+
+	var files []*File
+
+	for _, f := range w.Files {
+		if f.Client == client && bytes.Equal(f.Handle, handle) {
+			files = append(files, f)
+		}
+	}
+
+	return files
+}
+
 func (w *Worker) RemoveFile(index uint64) (*File, bool) {
 	w.Lock()
 	defer w.Unlock()
@@ -77,3 +97,30 @@ func (w *Worker) IsRemovedFile(index uint64) bool {
 
 	return ok
 }
+
+// AddLockOwner returns the index of a lock owner of the open file, which is added if needed.
+func (w *Worker) AddLockOwner(f *File, owner locks.Owner) uint32 {
+	w.Lock()
+	defer w.Unlock()
+
+	for i, o := range f.LockOwners {
+		if o == owner {
+			return uint32(i)
+		}
+	}
+
+	f.LockOwners = append(f.LockOwners, owner)
+
+	return uint32(len(f.LockOwners) - 1)
+}
+
+func (w *Worker) GetLockOwner(f *File, index uint32) (locks.Owner, bool) {
+	w.Lock()
+	defer w.Unlock()
+
+	if int(index) >= len(f.LockOwners) {
+		return locks.Owner{}, false
+	}
+
+	return f.LockOwners[index], true
+}
@@ -0,0 +1,116 @@
+package nfs4go
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+
+	"github.com/kuleuven/nfs4go/msg"
+)
+
+// writeSameChunk is the maximum amount of data generated at once by WRITE_SAME.
+const writeSameChunk = 4 * 1024 * 1024
+
+// WriteSameLimit is the maximum number of bytes written by a single WRITE_SAME, which runs
+// while the client waits. Larger requests are shortened to whole blocks, and the client
+// writes the remaining blocks with another request. Larger blocks are refused.
+var WriteSameLimit = uint64(64 * 1024 * 1024)
+
+// checkAppData validates an app_data_block4 of WRITE_SAME. The pattern must fit within
+// a block of at most WriteSameLimit bytes. The block number is written as a 32-bit big endian integer at its relative
+// offset, if it fits within the block; it may not overlap with the pattern.
+func checkAppData(adb *msg.AppDataBlock4) uint32 {
+	if adb.BlockSize == 0 || adb.BlockSize > WriteSameLimit || adb.RelOffPattern > adb.BlockSize || uint64(len(adb.Pattern)) > adb.BlockSize-adb.RelOffPattern {
+		return msg.NFS4ERR_INVAL
+	}
+
+	if hasBlockNum(adb) && adb.RelOffBlockNum < adb.RelOffPattern+uint64(len(adb.Pattern)) && adb.RelOffPattern < adb.RelOffBlockNum+4 {
+		return msg.NFS4ERR_INVAL
+	}
+
+	if adb.BlockCount > math.MaxInt64/adb.BlockSize || adb.Offset > math.MaxInt64-adb.BlockSize*adb.BlockCount {
+		return msg.NFS4ERR_FBIG
+	}
+
+	return msg.NFS4_OK
+}
+
+func hasBlockNum(adb *msg.AppDataBlock4) bool {
+	return adb.BlockSize >= 4 && adb.RelOffBlockNum <= adb.BlockSize-4
+}
+
+// writeSame writes the blocks described by a validated app_data_block4, generating
+// at most writeSameChunk bytes at once. It returns the number of bytes written.
+func writeSame(w io.WriterAt, adb *msg.AppDataBlock4) (int64, error) {
+	total := adb.BlockSize * adb.BlockCount
+
+	// Without block numbers, chunks that consist of whole blocks are all the same
+	size := min(total, writeSameChunk)
+
+	if !hasBlockNum(adb) && size > adb.BlockSize {
+		size -= size % adb.BlockSize
+	}
+
+	var (
+		buf      = make([]byte, size)
+		periodic = !hasBlockNum(adb) && size%adb.BlockSize == 0
+		written  uint64
+	)
+
+	for written < total {
+		chunk := buf[:min(size, total-written)]
+
+		if !periodic || written == 0 {
+			fillAppData(adb, chunk, written)
+		}
+
+		n, err := w.WriteAt(chunk, int64(adb.Offset+written))
+
+		written += uint64(n)
+
+		if err != nil {
+			return int64(written), err
+		}
+	}
+
+	return int64(written), nil
+}
+
+// fillAppData fills buf with the blocks of an app_data_block4, starting at the given
+// offset relative to the first block.
+func fillAppData(adb *msg.AppDataBlock4, buf []byte, offset uint64) {
+	var blockNum [4]byte
+
+	for len(buf) > 0 {
+		block, rel := offset/adb.BlockSize, offset%adb.BlockSize
+		part := buf[:min(uint64(len(buf)), adb.BlockSize-rel)]
+
+		clear(part)
+		overlay(part, rel, adb.RelOffPattern, adb.Pattern)
+
+		if hasBlockNum(adb) {
+			binary.BigEndian.PutUint32(blockNum[:], adb.BlockNum+uint32(block))
+
+			overlay(part, rel, adb.RelOffBlockNum, blockNum[:])
+		}
+
+		buf = buf[len(part):]
+		offset += uint64(len(part))
+	}
+}
+
+// overlay copies data, located at the given offset within a block, into part,
+// which holds the block starting at offset rel.
+func overlay(part []byte, rel, at uint64, data []byte) {
+	end := at + uint64(len(data))
+
+	if end <= rel || at >= rel+uint64(len(part)) {
+		return
+	}
+
+	if at >= rel {
+		copy(part[at-rel:], data)
+	} else {
+		copy(part, data[rel-at:])
+	}
+}
@@ -0,0 +1,170 @@
+package nfs4go
+
+import (
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/kuleuven/nfs4go/msg"
+	"github.com/kuleuven/vfs"
+)
+
+// XattrMapping determines which extended attributes of the file system are exposed to clients,
+// and under which names, by GETXATTR, SETXATTR, LISTXATTRS, REMOVEXATTR and as named attributes.
+// A nil XattrMapping is equivalent to DefaultXattrMapping.
+type XattrMapping struct {
+	// Prefix is the namespace of the extended attributes of the file system that are exposed,
+	// e.g. "user." or "trusted.". It is stripped from the names seen by clients.
+	// If empty, all extended attributes are exposed with their full name.
+	Prefix string
+
+	// Allow lists patterns, as in path.Match, of the names seen by clients that are exposed.
+	// If empty, all names are exposed.
+	Allow []string
+
+	// Deny lists patterns of the names seen by clients that are hidden, even if they are allowed.
+	Deny []string
+
+	// ReadOnly lists namespaces of the file system, e.g. "security.", whose extended attributes
+	// clients can read but not set or remove. Names are matched after translation.
+	ReadOnly []string
+
+	// Translate translates the names of the file system to the names seen by clients and back,
+	// e.g. to expose metadata of a backend under other names. If set, Prefix is not used.
+	Translate XattrTranslator
+}
+
+// XattrTranslator translates the names of extended attributes of the file system to
+// the names seen by clients and vice versa.
+type XattrTranslator interface {
+	// ClientName returns the name seen by clients of an extended attribute of the file system,
+	// or false if it is not exposed.
+	ClientName(name string) (string, bool)
+
+	// FSName returns the name in the file system of an extended attribute seen by clients,
+	// or false if there is none.
+	FSName(name string) (string, bool)
+}
+
+// DefaultXattrMapping exposes the extended attributes in the user namespace, like the linux kernel server.
+var DefaultXattrMapping = XattrMapping{
+	Prefix: "user.",
+}
+
+func (m *XattrMapping) orDefault() *XattrMapping {
+	if m == nil {
+		return &DefaultXattrMapping
+	}
+
+	return m
+}
+
+// exposed returns whether a name seen by clients passes the allow and deny lists.
+func (m *XattrMapping) exposed(name string) bool {
+	match := func(pattern string) bool {
+		ok, _ := path.Match(pattern, name) //nolint:errcheck
+
+		return ok
+	}
+
+	if len(m.Allow) > 0 && !slices.ContainsFunc(m.Allow, match) {
+		return false
+	}
+
+	return !slices.ContainsFunc(m.Deny, match)
+}
+
+// fsName returns the name in the file system of an extended attribute seen by clients.
+func (m *XattrMapping) fsName(name string) (string, bool) {
+	m = m.orDefault()
+
+	if name == "" || !m.exposed(name) {
+		return "", false
+	}
+
+	if m.Translate != nil {
+		return m.Translate.FSName(name)
+	}
+
+	return m.Prefix + name, true
+}
+
+// clientName returns the name seen by clients of an extended attribute of the file system.
+func (m *XattrMapping) clientName(name string) (string, bool) {
+	m = m.orDefault()
+
+	var (
+		client string
+		ok     bool
+	)
+
+	if m.Translate != nil {
+		client, ok = m.Translate.ClientName(name)
+	} else {
+		client, ok = strings.CutPrefix(name, m.Prefix)
+	}
+
+	if !ok || client == "" || !m.exposed(client) {
+		return "", false
+	}
+
+	return client, true
+}
+
+// writable returns whether clients may set or remove the extended attribute of the file system.
+func (m *XattrMapping) writable(name string) bool {
+	m = m.orDefault()
+
+	return !slices.ContainsFunc(m.ReadOnly, func(ns string) bool {
+		return strings.HasPrefix(name, ns)
+	})
+}
+
+// xattrName returns the name in the file system of an extended attribute seen by clients.
+// It returns NFS4ERR_NOXATTR if the name is not exposed.
+func (m *XattrMapping) xattrName(name string) (string, uint32) {
+	if name == "" {
+		return "", msg.NFS4ERR_INVAL
+	}
+
+	fsName, ok := m.fsName(name)
+
+	switch {
+	case !ok:
+		return "", msg.NFS4ERR_NOXATTR
+	case len(fsName) > xattrMaxName:
+		return "", msg.NFS4ERR_NAMETOOLONG
+	default:
+		return fsName, msg.NFS4_OK
+	}
+}
+
+// get returns the value of an extended attribute seen by clients.
+func (m *XattrMapping) get(attrs vfs.Attributes, name string) ([]byte, bool) {
+	fsName, ok := m.fsName(name)
+	if !ok {
+		return nil, false
+	}
+
+	return attrs.Get(fsName)
+}
+
+// names returns the sorted names seen by clients of the extended attributes of a file.
+func (m *XattrMapping) names(fi vfs.FileInfo) []string {
+	attrs, err := fi.Extended()
+	if err != nil {
+		return nil
+	}
+
+	var names []string
+
+	for name := range attrs {
+		if client, ok := m.clientName(name); ok {
+			names = append(names, client)
+		}
+	}
+
+	slices.Sort(names)
+
+	return names
+}
@@ -7,10 +7,25 @@ import (
 	"io"
 	"math"
 	"reflect"
+	"slices"
 
 	"github.com/sirupsen/logrus"
 )
 
+// ErrInvalid is returned for data that cannot be decoded, e.g. an invalid union mode
+// or a length prefix that exceeds the remaining data.
+var ErrInvalid = errors.New("invalid xdr data")
+
+// IsDecodeError returns whether err is caused by malformed or truncated data,
+// rather than by a failure of the underlying reader.
+func IsDecodeError(err error) bool {
+	return errors.Is(err, ErrInvalid) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// chunkSize is the size of the chunks in which variable-length data is read
+// if the size of the remaining data is unknown.
+const chunkSize = 64 * 1024
+
 func NewDecoder(r io.Reader) *Decoder {
 	return &Decoder{r: r}
 }
@@ -38,6 +53,14 @@ func (d *Decoder) Bytes() ([]byte, error) {
 
 	n := int(size)
 
+	if err = d.checkLength(n+Pad(n), 1); err != nil {
+		return nil, err
+	}
+
+	if _, ok := d.r.(lener); !ok && n > chunkSize {
+		return d.readChunked(n)
+	}
+
 	b := make([]byte, n+Pad(n))
 
 	_, err = io.ReadFull(d.r, b)
@@ -48,6 +71,43 @@ func (d *Decoder) Bytes() ([]byte, error) {
 	return b[:n], nil
 }
 
+// lener is implemented by readers that know the size of the remaining data,
+// such as bufpool.Buf and bytes.Reader.
+type lener interface {
+	Len() int
+}
+
+// checkLength returns an error if n items of at least size bytes each cannot fit
+// in the remaining data, to avoid allocating memory for a bogus length prefix.
+func (d *Decoder) checkLength(n, size int) error {
+	if r, ok := d.r.(lener); ok && n*size > r.Len() {
+		return fmt.Errorf("%w: length %d exceeds remaining data", ErrInvalid, n)
+	}
+
+	return nil
+}
+
+// readChunked reads n bytes and their padding in chunks, so that the allocated memory
+// is bounded by the data that is actually received.
+func (d *Decoder) readChunked(n int) ([]byte, error) {
+	var b []byte
+
+	for remaining := n + Pad(n); remaining > 0; {
+		chunk := min(remaining, chunkSize)
+
+		b = slices.Grow(b, chunk)
+
+		if _, err := io.ReadFull(d.r, b[len(b):len(b)+chunk]); err != nil {
+			return nil, err
+		}
+
+		b = b[:len(b)+chunk]
+		remaining -= chunk
+	}
+
+	return b[:n], nil
+}
+
 func (d *Decoder) ByteArray(buf []byte) error {
 	_, err := io.ReadFull(d.r, buf)
 	if err != nil {
@@ -121,13 +181,19 @@ func (d *Decoder) Uint32s() ([]uint32, error) {
 
 	n := int(size)
 
-	b := make([]uint32, n)
+	if err = d.checkLength(n, 4); err != nil {
+		return nil, err
+	}
+
+	b := make([]uint32, 0, min(n, 1024))
 
-	for i := range n {
-		b[i], err = d.Uint32()
+	for range n {
+		v, err := d.Uint32()
 		if err != nil {
 			return nil, err
 		}
+
+		b = append(b, v)
 	}
 
 	return b, nil
@@ -141,13 +207,19 @@ func (d *Decoder) Strings() ([]string, error) {
 
 	n := int(size)
 
-	b := make([]string, n)
+	if err = d.checkLength(n, 4); err != nil {
+		return nil, err
+	}
 
-	for i := range n {
-		b[i], err = d.String()
+	b := make([]string, 0, min(n, 1024))
+
+	for range n {
+		v, err := d.String()
 		if err != nil {
 			return nil, err
 		}
+
+		b = append(b, v)
 	}
 
 	return b, nil
@@ -236,7 +308,7 @@ func (d *Decoder) Union(mode *uint32, args ...interface{}) error {
 	fieldCount := len(args)
 
 	if int(*mode) >= fieldCount {
-		return fmt.Errorf("invalid union mode: %d", *mode)
+		return fmt.Errorf("%w: invalid union mode %d", ErrInvalid, *mode)
 	}
 
 	return d.Decode(args[int(*mode)])
@@ -395,9 +467,13 @@ func (d *Decoder) decodeReflect(v reflect.Value) error { //nolint:funlen,gocogni
 
 		arrLen := int(arrLen32)
 
-		varr := reflect.MakeSlice(vtyp, arrLen, arrLen)
+		if err := d.checkLength(arrLen, 4); err != nil {
+			return err
+		}
 
-		for i := range arrLen {
+		varr := reflect.MakeSlice(vtyp, 0, min(arrLen, 1024))
+
+		for range arrLen {
 			// read an element
 			item := reflect.New(vtyp.Elem())
 
@@ -405,8 +481,7 @@ func (d *Decoder) decodeReflect(v reflect.Value) error { //nolint:funlen,gocogni
 				return err
 			}
 
-			v1 := varr.Index(i)
-			v1.Set(item.Elem())
+			varr = reflect.Append(varr, item.Elem())
 		}
 
 		v.Elem().Set(varr)
@@ -442,7 +517,7 @@ func (d *Decoder) decodeReflect(v reflect.Value) error { //nolint:funlen,gocogni
 			}
 
 			if err := d.decodeReflect(pToFv); err != nil {
-				return fmt.Errorf("ReadValue(field:%s): %v", field.Name, err)
+				return fmt.Errorf("ReadValue(field:%s): %w", field.Name, err)
 			}
 
 			fv.Set(pToFv.Elem())
@@ -466,7 +541,7 @@ func (d *Decoder) readUnion(v reflect.Value) error {
 	fieldCount := v.Elem().Type().NumField()
 
 	if int(mode)+1 >= fieldCount {
-		return fmt.Errorf("invalid union mode: %d", mode)
+		return fmt.Errorf("%w: invalid union mode %d", ErrInvalid, mode)
 	}
 
 	fv := v.Elem().Field(int(mode) + 1).Addr()